@@ -1,7 +1,12 @@
 package api
 
 import (
+	"mime"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/arianfiftyone/src/kademlia"
 	"github.com/arianfiftyone/src/logger"
@@ -20,10 +25,96 @@ func NewKademliaAPI(kademlia kademlia.Kademlia) *KademliaAPI {
 
 type ValueDTO struct {
 	Value string `json:"value"`
+	TTL   int64  `json:"ttl,omitempty"` // Requested lifetime in seconds, the default is used if it is left out
+}
+
+// ObjectDTO is a text value together with its metadata, as returned by GetObject.
+type ObjectDTO struct {
+	Value        string    `json:"value"`
+	ContentType  string    `json:"contentType"`
+	Size         int       `json:"size"`
+	CreationTime time.Time `json:"creationTime"`
+}
+
+// RecordDTO is a signed record, together with the requested lifetime when it is stored.
+type RecordDTO struct {
+	kademlia.Record
+	TTL int64 `json:"ttl,omitempty"` // Requested lifetime in seconds, the default is used if it is left out
+}
+
+// ReplicaReportDTO tells whether the k closest replicas of an object agree, as returned by GetReplicas.
+type ReplicaReportDTO struct {
+	kademlia.ReplicaReport
+	Found      bool `json:"found"`
+	Consistent bool `json:"consistent"`
+}
+
+// BatchStoreDTO is a batch of text values stored with the same lifetime.
+type BatchStoreDTO struct {
+	Values []string `json:"values"`
+	TTL    int64    `json:"ttl,omitempty"` // Requested lifetime in seconds, the default is used if it is left out
+}
+
+// BatchStoreResultDTO is the result of storing one value of a batch, the hash is empty if it could not be hashed.
+type BatchStoreResultDTO struct {
+	HashDTO
+	Error string `json:"error,omitempty"`
+}
+
+// BatchLookupDTO is a batch of hashes to look up.
+type BatchLookupDTO struct {
+	Hashes []string `json:"hashes"`
+}
+
+// BatchLookupResultDTO is the result of looking up one hash of a batch. A text value is returned as text, any other
+// value as base64 encoded data.
+type BatchLookupResultDTO struct {
+	Hash        string `json:"hash"`
+	Found       bool   `json:"found"`
+	Value       string `json:"value,omitempty"`
+	Data        []byte `json:"data,omitempty"`
+	ContentType string `json:"contentType,omitempty"`
+	Size        int    `json:"size,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// ProviderDTO is a node that announced it provides an object.
+type ProviderDTO struct {
+	ID   string `json:"id"`
+	Ip   string `json:"ip"`
+	Port int    `json:"port"`
+}
+
+// RepairDTO is the number of lost shards of an erasure coded object that were regenerated.
+type RepairDTO struct {
+	Hash     string `json:"hash"`
+	Repaired int    `json:"repaired"`
+}
+
+// TopicMessageDTO is a message published to a topic, as streamed by GetTopicMessages. Text is returned as text, any
+// other data as base64 encoded data.
+type TopicMessageDTO struct {
+	ID        string      `json:"id"`
+	Topic     string      `json:"topic"`
+	Value     string      `json:"value,omitempty"`
+	Data      []byte      `json:"data,omitempty"`
+	Publisher ProviderDTO `json:"publisher"`
+	Time      time.Time   `json:"time"`
 }
 
 type HashDTO struct {
-	Hash string `json:"hash"`
+	Hash        string                        `json:"hash"`
+	DeleteToken string                        `json:"deleteToken,omitempty"` // The secret DeleteObject must be given to delete the object
+	Version     uint64                        `json:"version,omitempty"`     // The version of a value put under an application key
+	Replicas    []kademlia.StoreReplicaResult `json:"replicas,omitempty"`    // What each contact answered the STORE or DELETE with
+}
+
+// PublicationDTO describes a value this node maintains in the network.
+type PublicationDTO struct {
+	Hash            string    `json:"hash"`
+	TTL             string    `json:"ttl"`
+	Replicas        int       `json:"replicas"` // How many contacts held the value after it was last republished
+	LastRepublished time.Time `json:"lastRepublished"`
 }
 
 // StartAPI initializes and starts the REST API using Gin.
@@ -34,6 +125,28 @@ func (kademliaAPI KademliaAPI) StartAPI() {
 
 	router.GET("/objects/:hash", kademliaAPI.GetObject)
 	router.POST("/objects", kademliaAPI.PostObject)
+	router.DELETE("/objects/:hash", kademliaAPI.DeleteObject)
+	router.POST("/objects/batch", kademliaAPI.PostObjects)
+	router.POST("/objects/lookup", kademliaAPI.LookupObjects)
+	router.GET("/published", kademliaAPI.GetPublished)
+	router.GET("/providers/:hash", kademliaAPI.GetProviders)
+	router.PUT("/providers/:hash", kademliaAPI.PutProvider)
+	router.DELETE("/providers/:hash", kademliaAPI.DeleteProvider)
+	router.POST("/files", kademliaAPI.PostFile)
+	router.GET("/files/:hash", kademliaAPI.GetFile)
+	router.POST("/erasure", kademliaAPI.PostErasureCoded)
+	router.GET("/erasure/:hash", kademliaAPI.GetErasureCoded)
+	router.POST("/erasure/:hash/repair", kademliaAPI.RepairErasureCoded)
+	router.POST("/topics/:topic", kademliaAPI.PostTopicMessage)
+	router.GET("/topics/:topic/messages", kademliaAPI.GetTopicMessages)
+	router.GET("/pins", kademliaAPI.GetPins)
+	router.PUT("/pins/:hash", kademliaAPI.PutPin)
+	router.DELETE("/pins/:hash", kademliaAPI.DeletePin)
+	router.GET("/objects/:hash/replicas", kademliaAPI.GetReplicas)
+	router.GET("/records/:key", kademliaAPI.GetRecord)
+	router.PUT("/records", kademliaAPI.PutRecord)
+	router.GET("/kv/:key", kademliaAPI.GetKeyValue)
+	router.PUT("/kv/:key", kademliaAPI.PutKeyValue)
 
 	err := router.Run(":50000")
 	if err != nil {
@@ -41,52 +154,632 @@ func (kademliaAPI KademliaAPI) StartAPI() {
 	}
 }
 
-// GetObject handles GET requests for object retrieval.
+// GetObject handles GET requests for object retrieval. Text values are returned as JSON together with their
+// metadata unless the request accepts application/octet-stream, other values are returned unchanged as the body.
 func (kademliaAPI KademliaAPI) GetObject(ctx *gin.Context) {
 	// Extract the hash from the URL parameter
 	hashParam := ctx.Param("hash")
 
-	if len(hashParam) != 40 {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid hash length"})
+	// The hash is either a multihash or a 40 character SHA-1 hash
+	key, err := kademlia.ParseKey(hashParam)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid hash"})
 	} else {
 
-		newKademliaID, err := kademlia.NewKademliaID(hashParam)
+		// Attempt to find the value associated with the key in the Kademlia network
+		_, value, err := kademliaAPI.kademlia.LookupData(key)
+
+		if err != nil || value == nil {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "404 page not found"})
+		} else {
+			respondValue(ctx, value)
+		}
+	}
+}
+
+// respondValue responds with a text value as JSON together with its metadata unless the request accepts
+// application/octet-stream, and with any other value unchanged as the body.
+func respondValue(ctx *gin.Context, value *kademlia.Value) {
+	if value.ContentType == kademlia.TextContentType && ctx.NegotiateFormat(gin.MIMEJSON, kademlia.BinaryContentType) != kademlia.BinaryContentType {
+		res := ObjectDTO{
+			Value:        string(value.Data),
+			ContentType:  value.ContentType,
+			Size:         value.Size,
+			CreationTime: value.CreationTime,
+		}
+		ctx.JSON(http.StatusOK, res)
+	} else {
+		contentType := value.ContentType
+		if contentType == "" {
+			contentType = kademlia.BinaryContentType
+		}
+		ctx.Header("Last-Modified", value.CreationTime.UTC().Format(http.TimeFormat))
+		ctx.Data(http.StatusOK, contentType, value.Data)
+	}
+}
+
+// PostObject handles POST requests for object storage. A JSON body holds a text value, any other body is stored
+// unchanged as a binary value with the content type of the request and the time to live in the ttl query parameter.
+func (kademliaAPI KademliaAPI) PostObject(ctx *gin.Context) {
+	value, ttl, ok := bindValue(ctx)
+	if !ok {
+		return
+	}
+
+	// Store the value in the Kademlia network and get the associated key
+	result, err := kademliaAPI.kademlia.Store(value, ttl)
+
+	if err != nil {
+		respondStoreError(ctx, result, "Error storing object")
+		return
+	}
+
+	res := HashDTO{Hash: result.Key.GetHashString(), DeleteToken: result.DeleteToken, Replicas: result.Replicas}
+
+	ctx.Header("Location", "/objects/"+result.Key.GetHashString())
+	ctx.IndentedJSON(http.StatusCreated, res)
+}
+
+// PostObjects handles POST requests to store a batch of text values, and responds with a result for each value in the
+// same order. Values whose keys are close together share a lookup.
+func (kademliaAPI KademliaAPI) PostObjects(ctx *gin.Context) {
+	var batchDTO BatchStoreDTO
+
+	if err := ctx.ShouldBindJSON(&batchDTO); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid batch"})
+		return
+	}
+	if batchDTO.TTL < 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ttl"})
+		return
+	}
+
+	values := []kademlia.Value{}
+	for _, value := range batchDTO.Values {
+		values = append(values, kademlia.NewTextValue(value))
+	}
+
+	res := []BatchStoreResultDTO{}
+	for _, result := range kademliaAPI.kademlia.StoreMany(values, time.Duration(batchDTO.TTL)*time.Second) {
+		resultDTO := BatchStoreResultDTO{}
+		if result.Key != nil {
+			resultDTO.Hash = result.Key.GetHashString()
+		}
+		if result.Result != nil {
+			resultDTO.Replicas = result.Result.Replicas
+		}
+		if result.Err != nil {
+			resultDTO.Error = result.Err.Error()
+		} else {
+			resultDTO.DeleteToken = result.Result.DeleteToken
+		}
+		res = append(res, resultDTO)
+	}
+	ctx.IndentedJSON(http.StatusOK, res)
+}
+
+// LookupObjects handles POST requests to look up a batch of hashes, and responds with a result for each hash in the
+// same order. An invalid hash only fails its own result.
+func (kademliaAPI KademliaAPI) LookupObjects(ctx *gin.Context) {
+	var batchDTO BatchLookupDTO
+
+	if err := ctx.ShouldBindJSON(&batchDTO); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid batch"})
+		return
+	}
+
+	res := make([]BatchLookupResultDTO, len(batchDTO.Hashes))
+	keys := []*kademlia.Key{}
+	indices := []int{}
+	for i, hash := range batchDTO.Hashes {
+		res[i].Hash = hash
+		key, err := kademlia.ParseKey(hash)
 		if err != nil {
-			logger.Log("error when creating a new KademliaID: " + err.Error())
-			return
+			res[i].Error = "Invalid hash"
+			continue
 		}
+		keys = append(keys, key)
+		indices = append(indices, i)
+	}
+
+	for j, result := range kademliaAPI.kademlia.LookupMany(keys) {
+		resultDTO := &res[indices[j]]
+		if result.Err != nil {
+			resultDTO.Error = result.Err.Error()
+		} else if result.Value != nil {
+			resultDTO.Found = true
+			resultDTO.ContentType = result.Value.ContentType
+			resultDTO.Size = result.Value.Size
+			if result.Value.ContentType == kademlia.TextContentType {
+				resultDTO.Value = string(result.Value.Data)
+			} else {
+				resultDTO.Data = result.Value.Data
+			}
+		}
+	}
+	ctx.IndentedJSON(http.StatusOK, res)
+}
 
-		// Attempt to find the value associated with the `KademliaID` in the Kademlia network
-		_, value, err := kademliaAPI.kademlia.LookupData(kademlia.GetKeyRepresentationOfKademliaId(newKademliaID))
+// bindValue reads the value and time to live of a request, as described for PostObject. It responds with an error and
+// returns false if the request is invalid.
+func bindValue(ctx *gin.Context) (kademlia.Value, time.Duration, bool) {
+	var value kademlia.Value
+	var ttl int64
 
+	if ctx.ContentType() == gin.MIMEJSON {
+		var valueDTO ValueDTO
+
+		if err := ctx.ShouldBindJSON(&valueDTO); err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			return value, 0, false
+		}
+		value = kademlia.NewTextValue(valueDTO.Value)
+		ttl = valueDTO.TTL
+	} else {
+		data, err := ctx.GetRawData()
 		if err != nil {
-			ctx.JSON(http.StatusNotFound, gin.H{"error": "404 page not found"})
-		} else {
-			res := ValueDTO{Value: value}
-			ctx.JSON(http.StatusOK, res)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			return value, 0, false
+		}
+		if ttlParam := ctx.Query("ttl"); ttlParam != "" {
+			ttl, err = strconv.ParseInt(ttlParam, 10, 64)
+			if err != nil {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ttl"})
+				return value, 0, false
+			}
+		}
+		contentType := ctx.GetHeader("Content-Type")
+		if contentType == "" {
+			contentType = kademlia.BinaryContentType
 		}
+		value = kademlia.NewValue(data, contentType)
+	}
+
+	if ttl < 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ttl"})
+		return value, 0, false
 	}
+	return value, time.Duration(ttl) * time.Second, true
 }
 
-// PostObject handles POST requests for object storage.
-func (kademliaAPI KademliaAPI) PostObject(ctx *gin.Context) {
-	var valueDTO ValueDTO
+// GetKeyValue handles GET requests for the latest version of the value stored under an application key. The value is
+// returned as GetObject returns objects, with its version as the ETag.
+func (kademliaAPI KademliaAPI) GetKeyValue(ctx *gin.Context) {
+	keyValue, err := kademliaAPI.kademlia.Get(ctx.Param("key"))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "404 page not found"})
+		return
+	}
+	ctx.Header("ETag", strconv.Quote(strconv.FormatUint(keyValue.Version, 10)))
+	respondValue(ctx, &keyValue.Value)
+}
 
-	if err := ctx.ShouldBindJSON(&valueDTO); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+// PutKeyValue handles PUT requests to store a value under an application key, replacing the value stored under it.
+// The body is read as PostObject reads it.
+func (kademliaAPI KademliaAPI) PutKeyValue(ctx *gin.Context) {
+	value, ttl, ok := bindValue(ctx)
+	if !ok {
 		return
 	}
 
-	// Store the value in the Kademlia network and get the associated key
-	key, err := kademliaAPI.kademlia.Store(valueDTO.Value)
+	result, err := kademliaAPI.kademlia.Put(ctx.Param("key"), value, ttl)
+	if err != nil {
+		respondStoreError(ctx, result, "Error storing value")
+		return
+	}
+
+	res := HashDTO{Hash: result.Key.GetHashString(), DeleteToken: result.DeleteToken, Version: result.Version, Replicas: result.Replicas}
+
+	ctx.Header("Location", "/kv/"+ctx.Param("key"))
+	ctx.IndentedJSON(http.StatusOK, res)
+}
+
+// DeleteObject handles DELETE requests for an object, which only its publisher can issue by sending the delete token
+// it got when the object was stored as a bearer token in the Authorization header.
+func (kademliaAPI KademliaAPI) DeleteObject(ctx *gin.Context) {
+	key, err := kademlia.ParseKey(ctx.Param("hash"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid hash"})
+		return
+	}
+	token, found := strings.CutPrefix(ctx.GetHeader("Authorization"), "Bearer ")
+	if !found || token == "" {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Missing delete token"})
+		return
+	}
+
+	result, err := kademliaAPI.kademlia.Delete(key, token)
+	if err != nil {
+		respondStoreError(ctx, result, "Error deleting object")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, HashDTO{Hash: key.GetHashString(), Replicas: result.Replicas})
+}
+
+// GetPublished handles GET requests for the values this node publishes and keeps republishing.
+func (kademliaAPI KademliaAPI) GetPublished(ctx *gin.Context) {
+	publications := []PublicationDTO{}
+	for _, publication := range kademliaAPI.kademlia.GetPublications() {
+		publications = append(publications, PublicationDTO{
+			Hash:            publication.Key.GetHashString(),
+			TTL:             publication.TTL.String(),
+			Replicas:        publication.Replicas,
+			LastRepublished: publication.LastRepublished,
+		})
+	}
+	ctx.JSON(http.StatusOK, publications)
+}
+
+// GetProviders handles GET requests for the nodes that announced they provide an object.
+func (kademliaAPI KademliaAPI) GetProviders(ctx *gin.Context) {
+	key, err := kademlia.ParseKey(ctx.Param("hash"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid hash"})
+		return
+	}
 
+	contacts, err := kademliaAPI.kademlia.FindProviders(key)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error storing object"})
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
 		return
 	}
+	providers := []ProviderDTO{}
+	for _, contact := range contacts {
+		providers = append(providers, ProviderDTO{ID: contact.ID.String(), Ip: contact.Ip, Port: contact.Port})
+	}
+	ctx.JSON(http.StatusOK, providers)
+}
 
-	res := HashDTO{Hash: key.GetHashString()}
+// PutProvider handles PUT requests to announce this node as a provider of an object, without storing the object in
+// the network. The announcement is repeated until it is deleted, with the time to live in the ttl query parameter.
+func (kademliaAPI KademliaAPI) PutProvider(ctx *gin.Context) {
+	key, err := kademlia.ParseKey(ctx.Param("hash"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid hash"})
+		return
+	}
+	var ttl int64
+	if ttlParam := ctx.Query("ttl"); ttlParam != "" {
+		ttl, err = strconv.ParseInt(ttlParam, 10, 64)
+		if err != nil || ttl < 0 {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ttl"})
+			return
+		}
+	}
+
+	result, err := kademliaAPI.kademlia.Provide(key, time.Duration(ttl)*time.Second)
+	if err != nil {
+		respondStoreError(ctx, result, "Error announcing provider")
+		return
+	}
+	ctx.JSON(http.StatusOK, HashDTO{Hash: key.GetHashString(), Replicas: result.Replicas})
+}
+
+// DeleteProvider handles DELETE requests to stop announcing this node as a provider of an object.
+func (kademliaAPI KademliaAPI) DeleteProvider(ctx *gin.Context) {
+	key, err := kademlia.ParseKey(ctx.Param("hash"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid hash"})
+		return
+	}
+
+	err = kademliaAPI.kademlia.StopProviding(key)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "404 page not found"})
+		return
+	}
+	ctx.JSON(http.StatusOK, HashDTO{Hash: key.GetHashString()})
+}
+
+// PostFile handles POST requests to store a file as chunks. The body is read as PostObject reads it, and the name of
+// the file is taken from the name query parameter. The returned hash is the hash of the manifest of the file.
+func (kademliaAPI KademliaAPI) PostFile(ctx *gin.Context) {
+	value, ttl, ok := bindValue(ctx)
+	if !ok {
+		return
+	}
 
-	ctx.Header("Location", "/objects/"+key.GetHashString())
+	result, err := kademliaAPI.kademlia.StoreFile(ctx.Query("name"), value.Data, value.ContentType, ttl)
+	if err != nil {
+		respondStoreError(ctx, result, "Error storing file")
+		return
+	}
+
+	res := HashDTO{Hash: result.Key.GetHashString(), DeleteToken: result.DeleteToken, Replicas: result.Replicas}
+
+	ctx.Header("Location", "/files/"+res.Hash)
 	ctx.IndentedJSON(http.StatusCreated, res)
 }
+
+// GetFile handles GET requests for a file stored as chunks, given the hash of its manifest. The file is returned as
+// the body, with the content type and name it was stored with.
+func (kademliaAPI KademliaAPI) GetFile(ctx *gin.Context) {
+	key, err := kademlia.ParseKey(ctx.Param("hash"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid hash"})
+		return
+	}
+
+	manifest, data, err := kademliaAPI.kademlia.GetFile(key)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	contentType := manifest.ContentType
+	if contentType == "" {
+		contentType = kademlia.BinaryContentType
+	}
+	if manifest.Name != "" {
+		ctx.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": manifest.Name}))
+	}
+	ctx.Data(http.StatusOK, contentType, data)
+}
+
+// PostErasureCoded handles POST requests to store an object erasure coded instead of replicated. The body is read as
+// PostObject reads it, and the numbers of shards are taken from the data and parity query parameters, which default to
+// those of kademlia.DefaultErasureCoding.
+func (kademliaAPI KademliaAPI) PostErasureCoded(ctx *gin.Context) {
+	value, ttl, ok := bindValue(ctx)
+	if !ok {
+		return
+	}
+	coding := kademlia.DefaultErasureCoding
+	var err error
+	if dataParam := ctx.Query("data"); dataParam != "" {
+		coding.DataShards, err = strconv.Atoi(dataParam)
+	}
+	if parityParam := ctx.Query("parity"); parityParam != "" && err == nil {
+		coding.ParityShards, err = strconv.Atoi(parityParam)
+	}
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid number of shards"})
+		return
+	}
+	if err = coding.Validate(); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := kademliaAPI.kademlia.StoreErasureCoded(value, coding, ttl)
+	if err != nil {
+		respondStoreError(ctx, result, "Error storing object")
+		return
+	}
+
+	res := HashDTO{Hash: result.Key.GetHashString(), Replicas: result.Replicas}
+
+	ctx.Header("Location", "/erasure/"+res.Hash)
+	ctx.IndentedJSON(http.StatusCreated, res)
+}
+
+// GetErasureCoded handles GET requests for an erasure coded object, which is rebuilt from its shards and returned as
+// GetObject returns it.
+func (kademliaAPI KademliaAPI) GetErasureCoded(ctx *gin.Context) {
+	key, err := kademlia.ParseKey(ctx.Param("hash"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid hash"})
+		return
+	}
+
+	value, err := kademliaAPI.kademlia.LookupErasureCoded(key)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	respondValue(ctx, value)
+}
+
+// RepairErasureCoded handles POST requests to regenerate the lost shards of an erasure coded object, which are stored
+// again with the time to live in the ttl query parameter.
+func (kademliaAPI KademliaAPI) RepairErasureCoded(ctx *gin.Context) {
+	key, err := kademlia.ParseKey(ctx.Param("hash"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid hash"})
+		return
+	}
+	var ttl int64
+	if ttlParam := ctx.Query("ttl"); ttlParam != "" {
+		ttl, err = strconv.ParseInt(ttlParam, 10, 64)
+		if err != nil || ttl < 0 {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ttl"})
+			return
+		}
+	}
+
+	repaired, err := kademliaAPI.kademlia.RepairErasureCoded(key, time.Duration(ttl)*time.Second)
+	if err != nil {
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, RepairDTO{Hash: key.GetHashString(), Repaired: repaired})
+}
+
+// PostTopicMessage handles POST requests to publish a message to a topic. The body is read as PostObject reads it,
+// and only its data is sent.
+func (kademliaAPI KademliaAPI) PostTopicMessage(ctx *gin.Context) {
+	value, _, ok := bindValue(ctx)
+	if !ok {
+		return
+	}
+
+	result, err := kademliaAPI.kademlia.Publish(ctx.Param("topic"), value.Data)
+	if err != nil {
+		respondStoreError(ctx, result, "Error publishing message")
+		return
+	}
+	ctx.JSON(http.StatusOK, HashDTO{Hash: result.Key.GetHashString(), Replicas: result.Replicas})
+}
+
+// GetTopicMessages handles GET requests to subscribe to a topic. The messages published to it are streamed as
+// server-sent events until the client disconnects.
+func (kademliaAPI KademliaAPI) GetTopicMessages(ctx *gin.Context) {
+	subscription, err := kademliaAPI.kademlia.Subscribe(ctx.Param("topic"))
+	if err != nil {
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+	defer subscription.Close()
+
+	ctx.Status(http.StatusOK)
+	ctx.Writer.Flush()
+	for {
+		select {
+		case <-ctx.Request.Context().Done():
+			return
+		case message, ok := <-subscription.Messages:
+			if !ok {
+				return
+			}
+			dto := TopicMessageDTO{
+				ID:        message.ID,
+				Topic:     message.Topic,
+				Publisher: ProviderDTO{ID: message.Publisher.ID.String(), Ip: message.Publisher.Ip, Port: message.Publisher.Port},
+				Time:      message.Time,
+			}
+			if utf8.Valid(message.Data) {
+				dto.Value = string(message.Data)
+			} else {
+				dto.Data = message.Data
+			}
+			ctx.SSEvent("message", dto)
+			ctx.Writer.Flush()
+		}
+	}
+}
+
+// GetPins handles GET requests for the hashes of the objects pinned on this node.
+func (kademliaAPI KademliaAPI) GetPins(ctx *gin.Context) {
+	pins := []HashDTO{}
+	for _, key := range kademliaAPI.kademlia.GetPins() {
+		pins = append(pins, HashDTO{Hash: key.GetHashString()})
+	}
+	ctx.JSON(http.StatusOK, pins)
+}
+
+// PutPin handles PUT requests to pin an object on this node, so it is kept and republished until it is unpinned.
+func (kademliaAPI KademliaAPI) PutPin(ctx *gin.Context) {
+	key, err := kademlia.ParseKey(ctx.Param("hash"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid hash"})
+		return
+	}
+
+	err = kademliaAPI.kademlia.Pin(key)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "404 page not found"})
+		return
+	}
+	ctx.JSON(http.StatusOK, HashDTO{Hash: key.GetHashString()})
+}
+
+// DeletePin handles DELETE requests to unpin an object, which then expires on this node like any other.
+func (kademliaAPI KademliaAPI) DeletePin(ctx *gin.Context) {
+	key, err := kademlia.ParseKey(ctx.Param("hash"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid hash"})
+		return
+	}
+
+	err = kademliaAPI.kademlia.Unpin(key)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "404 page not found"})
+		return
+	}
+	ctx.JSON(http.StatusOK, HashDTO{Hash: key.GetHashString()})
+}
+
+// GetRecord handles GET requests for a mutable record, and returns the version with the highest sequence number found.
+func (kademliaAPI KademliaAPI) GetRecord(ctx *gin.Context) {
+	key, err := kademlia.ParseKey(ctx.Param("key"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid key"})
+		return
+	}
+
+	_, value, err := kademliaAPI.kademlia.LookupDataInNamespace(key, kademlia.RecordNamespace)
+	if err != nil || value == nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "404 page not found"})
+		return
+	}
+
+	record, err := kademlia.RecordFromValue(*value)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "404 page not found"})
+		return
+	}
+	ctx.JSON(http.StatusOK, RecordDTO{Record: record})
+}
+
+// PutRecord handles PUT requests for a mutable record, which must be signed by its publisher.
+func (kademliaAPI KademliaAPI) PutRecord(ctx *gin.Context) {
+	var recordDTO RecordDTO
+
+	if err := ctx.ShouldBindJSON(&recordDTO); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid record"})
+		return
+	}
+	if recordDTO.TTL < 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ttl"})
+		return
+	}
+
+	record := recordDTO.Record
+	record.Value.Size = len(record.Value.Data)
+	if err := record.Verify(); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid record"})
+		return
+	}
+
+	result, err := kademliaAPI.kademlia.PutRecord(record, time.Duration(recordDTO.TTL)*time.Second)
+	if err != nil {
+		respondStoreError(ctx, result, "Error storing record")
+		return
+	}
+
+	res := HashDTO{Hash: result.Key.GetHashString(), DeleteToken: result.DeleteToken, Replicas: result.Replicas}
+
+	ctx.Header("Location", "/records/"+result.Key.GetHashString())
+	ctx.IndentedJSON(http.StatusCreated, res)
+}
+
+// GetReplicas handles GET requests for a consistency report of the replicas of an object. The quorum query parameter
+// is how many replicas must answer, and with repair=true the replicas lacking the object get it again.
+func (kademliaAPI KademliaAPI) GetReplicas(ctx *gin.Context) {
+	key, err := kademlia.ParseKey(ctx.Param("hash"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid hash"})
+		return
+	}
+	quorum := kademlia.DefaultReadQuorum
+	if quorumParam := ctx.Query("quorum"); quorumParam != "" {
+		quorum, err = strconv.Atoi(quorumParam)
+		if err != nil || quorum < 1 {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid quorum"})
+			return
+		}
+	}
+	repair := ctx.Query("repair") == "true"
+
+	value, report, err := kademliaAPI.kademlia.LookupDataWithQuorum(key, quorum, repair)
+	if err != nil {
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, ReplicaReportDTO{
+		ReplicaReport: report,
+		Found:         value != nil,
+		Consistent:    report.IsConsistent(),
+	})
+}
+
+// respondStoreError responds to a failed store or delete. If too few replicas stored or deleted the value, the response
+// lists what each contact answered, otherwise the request failed before any message was sent.
+func respondStoreError(ctx *gin.Context, result *kademlia.StoreResult, message string) {
+	if result == nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": message})
+		return
+	}
+	ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": message, "replicas": result.Replicas})
+}
@@ -1,11 +1,17 @@
 package api
 
 import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/arianfiftyone/src/kademlia"
 	"github.com/gin-gonic/gin"
@@ -20,15 +26,41 @@ const (
 // KademliaMock is a mock implementation of the kademlia.Kademlia interface.
 type KademliaMock struct {
 	mock.Mock
-	DataStore *kademlia.DataStore
+	DataStore    kademlia.DataStore
+	Publications []kademlia.Publication
+	Provided     []*kademlia.Key
+	Topics       map[string]chan kademlia.TopicMessage
 }
 
 func (KademliaMock *KademliaMock) Start() {}
 
 func (KademliaMock *KademliaMock) Join() {}
 
-func (KademliaMock *KademliaMock) Store(content string) (*kademlia.Key, error) {
-	return kademlia.NewKey(content), nil
+func (KademliaMock *KademliaMock) Store(value kademlia.Value, ttl time.Duration) (*kademlia.StoreResult, error) {
+	return &kademlia.StoreResult{Key: value.GetKey(), DeleteToken: "token"}, nil
+}
+
+func (KademliaMock *KademliaMock) PutRecord(record kademlia.Record, ttl time.Duration) (*kademlia.StoreResult, error) {
+	return &kademlia.StoreResult{Key: record.GetKey()}, nil
+}
+
+func (KademliaMock *KademliaMock) Put(key string, value kademlia.Value, ttl time.Duration) (*kademlia.StoreResult, error) {
+	keyValue := kademlia.KeyValue{Key: key, Version: 1, Value: value}
+	if current, err := KademliaMock.Get(key); err == nil {
+		keyValue.Version = current.Version + 1
+	}
+	stored, _ := keyValue.ToValue()
+	KademliaMock.DataStore.Insert(keyValue.GetKey(), stored, kademlia.DefaultTTL)
+	return &kademlia.StoreResult{Key: keyValue.GetKey(), DeleteToken: "token", Version: keyValue.Version}, nil
+}
+
+func (KademliaMock *KademliaMock) Get(key string) (*kademlia.KeyValue, error) {
+	value, err := KademliaMock.DataStore.Get(kademlia.NewKeyValueKey(key))
+	if err != nil {
+		return nil, err
+	}
+	keyValue, err := kademlia.KeyValueFromValue(value)
+	return &keyValue, err
 }
 
 func (KademliaMock *KademliaMock) GetKademliaNode() *kademlia.KademliaNode {
@@ -43,27 +75,198 @@ func (KademliaMock *KademliaMock) LookupContact(targetId *kademlia.KademliaID) (
 	return nil, nil
 }
 
-func (KademliaMock *KademliaMock) LookupData(key *kademlia.Key) ([]kademlia.Contact, string, error) {
-	content, err := KademliaMock.DataStore.Get(key)
-	return nil, content, err
+func (KademliaMock *KademliaMock) LookupData(key *kademlia.Key) ([]kademlia.Contact, *kademlia.Value, error) {
+	value, err := KademliaMock.DataStore.Get(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return nil, &value, nil
+}
+
+func (KademliaMock *KademliaMock) LookupDataInNamespace(key *kademlia.Key, namespace string) ([]kademlia.Contact, *kademlia.Value, error) {
+	_, value, err := KademliaMock.LookupData(key)
+	if err != nil || value.Namespace != namespace {
+		return nil, nil, err
+	}
+	return nil, value, nil
+}
+
+func (KademliaMock *KademliaMock) LookupDataWithQuorum(key *kademlia.Key, quorum int, repair bool) (*kademlia.Value, kademlia.ReplicaReport, error) {
+	_, value, err := KademliaMock.LookupData(key)
+	return value, kademlia.ReplicaReport{}, err
+}
+
+func (KademliaMock *KademliaMock) StoreMany(values []kademlia.Value, ttl time.Duration) []kademlia.BatchStoreResult {
+	results := []kademlia.BatchStoreResult{}
+	for _, value := range values {
+		if len(value.Data) == 0 {
+			results = append(results, kademlia.BatchStoreResult{Key: value.GetKey(), Err: errors.New("found no node to store the value in")})
+			continue
+		}
+		KademliaMock.DataStore.Insert(value.GetKey(), value, kademlia.DefaultTTL)
+		result, _ := KademliaMock.Store(value, ttl)
+		results = append(results, kademlia.BatchStoreResult{Key: result.Key, Result: result})
+	}
+	return results
+}
+
+func (KademliaMock *KademliaMock) LookupMany(keys []*kademlia.Key) []kademlia.BatchLookupResult {
+	results := []kademlia.BatchLookupResult{}
+	for _, key := range keys {
+		value, err := KademliaMock.DataStore.Get(key)
+		if err != nil {
+			results = append(results, kademlia.BatchLookupResult{Key: key})
+		} else {
+			results = append(results, kademlia.BatchLookupResult{Key: key, Value: &value})
+		}
+	}
+	return results
+}
+
+func (KademliaMock *KademliaMock) StoreFile(name string, data []byte, contentType string, ttl time.Duration) (*kademlia.StoreResult, error) {
+	chunk := kademlia.NewValue(data, kademlia.BinaryContentType)
+	KademliaMock.DataStore.Insert(chunk.GetKey(), chunk, kademlia.DefaultTTL)
+	manifest := kademlia.Manifest{Name: name, Size: len(data), ContentType: contentType, ChunkSize: kademlia.ChunkSize, Chunks: []string{chunk.GetKey().GetHashString()}}
+	value, _ := manifest.ToValue()
+	KademliaMock.DataStore.Insert(value.GetKey(), value, kademlia.DefaultTTL)
+	return &kademlia.StoreResult{Key: value.GetKey(), DeleteToken: "token"}, nil
+}
+
+func (KademliaMock *KademliaMock) GetFile(key *kademlia.Key) (*kademlia.Manifest, []byte, error) {
+	value, err := KademliaMock.DataStore.Get(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	manifest, err := kademlia.ManifestFromValue(value)
+	if err != nil {
+		return nil, nil, err
+	}
+	data := []byte{}
+	for _, hash := range manifest.Chunks {
+		chunkKey, _ := kademlia.ParseKey(hash)
+		chunk, err := KademliaMock.DataStore.Get(chunkKey)
+		if err != nil {
+			return nil, nil, err
+		}
+		data = append(data, chunk.Data...)
+	}
+	return &manifest, data, nil
+}
+
+func (KademliaMock *KademliaMock) StoreErasureCoded(value kademlia.Value, coding kademlia.ErasureCoding, ttl time.Duration) (*kademlia.StoreResult, error) {
+	if err := coding.Validate(); err != nil {
+		return nil, err
+	}
+	KademliaMock.DataStore.Insert(value.GetKey(), value, kademlia.DefaultTTL)
+	return &kademlia.StoreResult{Key: value.GetKey()}, nil
+}
+
+func (KademliaMock *KademliaMock) LookupErasureCoded(key *kademlia.Key) (*kademlia.Value, error) {
+	value, err := KademliaMock.DataStore.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	return &value, nil
+}
+
+func (KademliaMock *KademliaMock) RepairErasureCoded(key *kademlia.Key, ttl time.Duration) (int, error) {
+	if _, err := KademliaMock.DataStore.Get(key); err != nil {
+		return 0, errors.New("no shard of the value was found")
+	}
+	return 2, nil
 }
 
 func (KademliaMock *KademliaMock) Forget(key *kademlia.Key) error {
 	return nil
 }
 
+func (KademliaMock *KademliaMock) Pin(key *kademlia.Key) error {
+	return KademliaMock.DataStore.Pin(key)
+}
+
+func (KademliaMock *KademliaMock) Unpin(key *kademlia.Key) error {
+	return KademliaMock.DataStore.Unpin(key)
+}
+
+func (KademliaMock *KademliaMock) GetPins() []*kademlia.Key {
+	keys := []*kademlia.Key{}
+	for _, item := range KademliaMock.DataStore.Items() {
+		if item.Pinned {
+			keys = append(keys, item.Key)
+		}
+	}
+	return keys
+}
+
+func (KademliaMock *KademliaMock) GetPublications() []kademlia.Publication {
+	return KademliaMock.Publications
+}
+
+func (KademliaMock *KademliaMock) Provide(key *kademlia.Key, ttl time.Duration) (*kademlia.StoreResult, error) {
+	KademliaMock.Provided = append(KademliaMock.Provided, key)
+	return &kademlia.StoreResult{Key: key}, nil
+}
+
+func (KademliaMock *KademliaMock) StopProviding(key *kademlia.Key) error {
+	for i, provided := range KademliaMock.Provided {
+		if provided.Equals(key) {
+			KademliaMock.Provided = append(KademliaMock.Provided[:i], KademliaMock.Provided[i+1:]...)
+			return nil
+		}
+	}
+	return errors.New("key not found")
+}
+
+func (KademliaMock *KademliaMock) FindProviders(key *kademlia.Key) ([]kademlia.Contact, error) {
+	providers := []kademlia.Contact{}
+	for _, provided := range KademliaMock.Provided {
+		if provided.Equals(key) {
+			providers = append(providers, kademlia.NewContact(kademlia.GenerateNewKademliaID("0000000000000000000000000000000000000001"), "10.0.0.1", 3000))
+		}
+	}
+	return providers, nil
+}
+
+func (KademliaMock *KademliaMock) topic(topic string) chan kademlia.TopicMessage {
+	if KademliaMock.Topics == nil {
+		KademliaMock.Topics = make(map[string]chan kademlia.TopicMessage)
+	}
+	if _, ok := KademliaMock.Topics[topic]; !ok {
+		KademliaMock.Topics[topic] = make(chan kademlia.TopicMessage, 16)
+	}
+	return KademliaMock.Topics[topic]
+}
+
+func (KademliaMock *KademliaMock) Subscribe(topic string) (*kademlia.Subscription, error) {
+	return kademlia.NewSubscription(topic, KademliaMock.topic(topic), func() {}), nil
+}
+
+func (KademliaMock *KademliaMock) Publish(topic string, data []byte) (*kademlia.StoreResult, error) {
+	publisher := kademlia.NewContact(kademlia.GenerateNewKademliaID("0000000000000000000000000000000000000001"), "10.0.0.1", 3000)
+	KademliaMock.topic(topic) <- kademlia.TopicMessage{ID: "1", Topic: topic, Data: data, Publisher: publisher, Time: time.Unix(0, 0).UTC()}
+	return &kademlia.StoreResult{Key: kademlia.NewTopicKey(topic)}, nil
+}
+
+func (KademliaMock *KademliaMock) Delete(key *kademlia.Key, token string) (*kademlia.StoreResult, error) {
+	if token != "token" {
+		return &kademlia.StoreResult{Key: key}, errors.New("no replica deleted the value")
+	}
+	return &kademlia.StoreResult{Key: key}, nil
+}
+
 func TestGetObjectValidHash(t *testing.T) {
 
 	kademliaMock := new(KademliaMock)
 
 	// Create a new instance of DataStore
-	dataStore := kademlia.NewDataStore()
+	dataStore := kademlia.NewInMemoryDataStore()
 	value := "kademlia"
 	key := kademlia.NewKey(value)
 	hash := key.GetHashString()
-	dataStore.Insert(key, value)
+	textValue := kademlia.NewTextValue(value)
+	dataStore.Insert(key, textValue, kademlia.DefaultTTL)
 
-	kademliaMock.DataStore = &dataStore
+	kademliaMock.DataStore = dataStore
 	api := NewKademliaAPI(kademliaMock)
 
 	// Set up the Gin context for testing
@@ -80,14 +283,42 @@ func TestGetObjectValidHash(t *testing.T) {
 	// Call the GetObject handler
 	api.GetObject(c)
 
-	expectedJSON := `{"value": "%s"}`
-	expectedJSON = fmt.Sprintf(expectedJSON, value)
+	expectedJSON := `{"value": "%s", "contentType": "%s", "size": %d, "creationTime": "%s"}`
+	expectedJSON = fmt.Sprintf(expectedJSON, value, kademlia.TextContentType, len(value), textValue.CreationTime.Format(time.RFC3339Nano))
 
 	// Verify the response
 	assert.Equal(t, http.StatusOK, w.Code)
 	assert.JSONEq(t, w.Body.String(), expectedJSON)
 }
 
+func TestGetObjectLegacySHA1Hash(t *testing.T) {
+
+	kademliaMock := new(KademliaMock)
+
+	dataStore := kademlia.NewInMemoryDataStore()
+	value := "kademlia"
+	key, _ := kademlia.NewKeyWithHashFunction([]byte(value), kademlia.SHA1)
+	dataStore.Insert(key, kademlia.NewTextValue(value), kademlia.DefaultTTL)
+
+	kademliaMock.DataStore = dataStore
+	api := NewKademliaAPI(kademliaMock)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/objects/", nil)
+	c, _ := gin.CreateTestContext(w)
+	c.Params = append(c.Params, gin.Param{
+		Key:   "hash",
+		Value: key.GetHashString(),
+	})
+	c.Request = req
+
+	api.GetObject(c)
+
+	assert.Len(t, key.GetHashString(), 40)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), value)
+}
+
 func TestGetObjectInvalidHash(t *testing.T) {
 
 	kademliaMock := new(KademliaMock)
@@ -109,7 +340,7 @@ func TestGetObjectInvalidHash(t *testing.T) {
 
 	// Verify the response for an invalid hash
 	assert.Equal(t, http.StatusBadRequest, w.Code)
-	assert.JSONEq(t, `{"error":"Invalid hash length"}`, w.Body.String())
+	assert.JSONEq(t, `{"error":"Invalid hash"}`, w.Body.String())
 
 }
 
@@ -135,7 +366,7 @@ func TestPostObjectValidInput(t *testing.T) {
 	value := "kademlia"
 	key := kademlia.NewKey(value)
 	hash := key.GetHashString()
-	expectedJSON := `{"hash": "%s"}`
+	expectedJSON := `{"hash": "%s", "deleteToken": "token"}`
 	expectedJSON = fmt.Sprintf(expectedJSON, hash)
 
 	// Verify the response
@@ -143,6 +374,23 @@ func TestPostObjectValidInput(t *testing.T) {
 	assert.JSONEq(t, w.Body.String(), expectedJSON)
 }
 
+func TestPostObjectNegativeTTL(t *testing.T) {
+
+	kademliaMock := new(KademliaMock)
+	api := NewKademliaAPI(kademliaMock)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/objects", strings.NewReader(`{"value":"kademlia","ttl":-1}`))
+	req.Header.Set("Content-Type", "application/json")
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+
+	api.PostObject(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"error":"Invalid ttl"}`, w.Body.String())
+}
+
 func TestPostObjectInvalidInput(t *testing.T) {
 
 	kademliaMock := new(KademliaMock)
@@ -162,3 +410,535 @@ func TestPostObjectInvalidInput(t *testing.T) {
 	assert.Equal(t, http.StatusInternalServerError, w.Code)
 	assert.JSONEq(t, `{"error":"Internal server error"}`, w.Body.String())
 }
+
+func TestPostObjectBinary(t *testing.T) {
+
+	kademliaMock := new(KademliaMock)
+	api := NewKademliaAPI(kademliaMock)
+
+	data := []byte{0, 1, 2, 255}
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/objects?ttl=30", bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/octet-stream")
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+
+	api.PostObject(c)
+
+	expectedJSON := fmt.Sprintf(`{"hash": "%s", "deleteToken": "token"}`, kademlia.NewKey(string(data)).GetHashString())
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.JSONEq(t, expectedJSON, w.Body.String())
+}
+
+func TestGetObjectBinary(t *testing.T) {
+
+	kademliaMock := new(KademliaMock)
+	dataStore := kademlia.NewInMemoryDataStore()
+	value := kademlia.NewValue([]byte{0, 1, 2, 255}, "image/png")
+	dataStore.Insert(value.GetKey(), value, kademlia.DefaultTTL)
+	kademliaMock.DataStore = dataStore
+	api := NewKademliaAPI(kademliaMock)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/objects/", nil)
+	c, _ := gin.CreateTestContext(w)
+	c.Params = append(c.Params, gin.Param{
+		Key:   "hash",
+		Value: value.GetKey().GetHashString(),
+	})
+	c.Request = req
+
+	api.GetObject(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "image/png", w.Header().Get("Content-Type"))
+	assert.Equal(t, value.Data, w.Body.Bytes())
+}
+
+func TestGetObjectEmptyValue(t *testing.T) {
+
+	kademliaMock := new(KademliaMock)
+	dataStore := kademlia.NewInMemoryDataStore()
+	value := kademlia.NewValue([]byte{}, kademlia.BinaryContentType)
+	dataStore.Insert(value.GetKey(), value, kademlia.DefaultTTL)
+	kademliaMock.DataStore = dataStore
+	api := NewKademliaAPI(kademliaMock)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/objects/", nil)
+	c, _ := gin.CreateTestContext(w)
+	c.Params = append(c.Params, gin.Param{
+		Key:   "hash",
+		Value: value.GetKey().GetHashString(),
+	})
+	c.Request = req
+
+	api.GetObject(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Body.Bytes())
+}
+
+func TestPutRecord(t *testing.T) {
+
+	kademliaMock := new(KademliaMock)
+	api := NewKademliaAPI(kademliaMock)
+
+	_, privateKey, _ := ed25519.GenerateKey(nil)
+	record := kademlia.NewRecord(privateKey, []byte("salt"), 1, kademlia.NewTextValue("kademlia"))
+	body, _ := json.Marshal(RecordDTO{Record: record, TTL: 60})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/records", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+
+	api.PutRecord(c)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.JSONEq(t, fmt.Sprintf(`{"hash": "%s"}`, record.GetKey().GetHashString()), w.Body.String())
+}
+
+func TestPutRecordInvalidSignature(t *testing.T) {
+
+	kademliaMock := new(KademliaMock)
+	api := NewKademliaAPI(kademliaMock)
+
+	_, privateKey, _ := ed25519.GenerateKey(nil)
+	record := kademlia.NewRecord(privateKey, nil, 1, kademlia.NewTextValue("kademlia"))
+	record.Sequence = 2
+	body, _ := json.Marshal(RecordDTO{Record: record})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/records", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+
+	api.PutRecord(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"error":"Invalid record"}`, w.Body.String())
+}
+
+func TestGetRecord(t *testing.T) {
+
+	kademliaMock := new(KademliaMock)
+	dataStore := kademlia.NewInMemoryDataStore()
+	_, privateKey, _ := ed25519.GenerateKey(nil)
+	record := kademlia.NewRecord(privateKey, nil, 7, kademlia.NewTextValue("kademlia"))
+	value, _ := record.ToValue()
+	dataStore.Insert(record.GetKey(), value, kademlia.DefaultTTL)
+	kademliaMock.DataStore = dataStore
+	api := NewKademliaAPI(kademliaMock)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/records/", nil)
+	c, _ := gin.CreateTestContext(w)
+	c.Params = append(c.Params, gin.Param{
+		Key:   "key",
+		Value: record.GetKey().GetHashString(),
+	})
+	c.Request = req
+
+	api.GetRecord(c)
+
+	var recordDTO RecordDTO
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &recordDTO))
+	assert.Equal(t, uint64(7), recordDTO.Sequence)
+	assert.Equal(t, "kademlia", string(recordDTO.Value.Data))
+	assert.NoError(t, recordDTO.Record.Verify())
+}
+
+func TestGetReplicas(t *testing.T) {
+
+	kademliaMock := new(KademliaMock)
+	dataStore := kademlia.NewInMemoryDataStore()
+	value := kademlia.NewTextValue("kademlia")
+	dataStore.Insert(value.GetKey(), value, kademlia.DefaultTTL)
+	kademliaMock.DataStore = dataStore
+	api := NewKademliaAPI(kademliaMock)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/objects/"+value.GetKey().GetHashString()+"/replicas?quorum=2&repair=true", nil)
+	c, _ := gin.CreateTestContext(w)
+	c.Params = append(c.Params, gin.Param{
+		Key:   "hash",
+		Value: value.GetKey().GetHashString(),
+	})
+	c.Request = req
+
+	api.GetReplicas(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"replicas": null, "answered": 0, "found": true, "consistent": true}`, w.Body.String())
+}
+
+func TestGetReplicasInvalidQuorum(t *testing.T) {
+
+	kademliaMock := new(KademliaMock)
+	api := NewKademliaAPI(kademliaMock)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/objects/"+kademlia.NewKey("kademlia").GetHashString()+"/replicas?quorum=0", nil)
+	c, _ := gin.CreateTestContext(w)
+	c.Params = append(c.Params, gin.Param{
+		Key:   "hash",
+		Value: kademlia.NewKey("kademlia").GetHashString(),
+	})
+	c.Request = req
+
+	api.GetReplicas(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"error":"Invalid quorum"}`, w.Body.String())
+}
+
+func TestDeleteObject(t *testing.T) {
+	api := NewKademliaAPI(new(KademliaMock))
+	hash := kademlia.NewKey("kademlia").GetHashString()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/objects/"+hash, nil)
+	req.Header.Set("Authorization", "Bearer token")
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+	c.Params = append(c.Params, gin.Param{Key: "hash", Value: hash})
+
+	api.DeleteObject(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, fmt.Sprintf(`{"hash": "%s"}`, hash), w.Body.String())
+}
+
+func TestDeleteObjectWrongToken(t *testing.T) {
+	api := NewKademliaAPI(new(KademliaMock))
+	hash := kademlia.NewKey("kademlia").GetHashString()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/objects/"+hash, nil)
+	req.Header.Set("Authorization", "Bearer wrong token")
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+	c.Params = append(c.Params, gin.Param{Key: "hash", Value: hash})
+
+	api.DeleteObject(c)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
+func TestDeleteObjectMissingToken(t *testing.T) {
+	api := NewKademliaAPI(new(KademliaMock))
+	hash := kademlia.NewKey("kademlia").GetHashString()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/objects/"+hash, nil)
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+	c.Params = append(c.Params, gin.Param{Key: "hash", Value: hash})
+
+	api.DeleteObject(c)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.JSONEq(t, `{"error": "Missing delete token"}`, w.Body.String())
+}
+
+func TestPinObject(t *testing.T) {
+	value := kademlia.NewTextValue("kademlia")
+	key := value.GetKey()
+	dataStore := kademlia.NewInMemoryDataStore()
+	dataStore.Insert(key, value, kademlia.DefaultTTL)
+	api := NewKademliaAPI(&KademliaMock{DataStore: dataStore})
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest("PUT", "/pins/"+key.GetHashString(), nil)
+	c.Params = append(c.Params, gin.Param{Key: "hash", Value: key.GetHashString()})
+	api.PutPin(c)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest("GET", "/pins", nil)
+	api.GetPins(c)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, fmt.Sprintf(`[{"hash": "%s"}]`, key.GetHashString()), w.Body.String())
+
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest("DELETE", "/pins/"+key.GetHashString(), nil)
+	c.Params = append(c.Params, gin.Param{Key: "hash", Value: key.GetHashString()})
+	api.DeletePin(c)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, api.kademlia.GetPins())
+}
+
+func TestPinMissingObject(t *testing.T) {
+	api := NewKademliaAPI(&KademliaMock{DataStore: kademlia.NewInMemoryDataStore()})
+	hash := kademlia.NewKey("kademlia").GetHashString()
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest("PUT", "/pins/"+hash, nil)
+	c.Params = append(c.Params, gin.Param{Key: "hash", Value: hash})
+	api.PutPin(c)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestGetPublished(t *testing.T) {
+	key := kademlia.NewKey("kademlia")
+	lastRepublished := time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC)
+	api := NewKademliaAPI(&KademliaMock{Publications: []kademlia.Publication{
+		{Key: key, TTL: time.Hour, LastRepublished: lastRepublished, Replicas: 3},
+	}})
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest("GET", "/published", nil)
+	api.GetPublished(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	expected := fmt.Sprintf(`[{"hash": "%s", "ttl": "1h0m0s", "replicas": 3, "lastRepublished": "2023-10-01T12:00:00Z"}]`, key.GetHashString())
+	assert.JSONEq(t, expected, w.Body.String())
+}
+
+func TestPutAndGetKeyValue(t *testing.T) {
+	api := NewKademliaAPI(&KademliaMock{DataStore: kademlia.NewInMemoryDataStore()})
+	hash := kademlia.NewKeyValueKey("user").GetHashString()
+
+	for version := 1; version <= 2; version++ {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest("PUT", "/kv/user", strings.NewReader(fmt.Sprintf(`{"value": "version %d"}`, version)))
+		c.Request.Header.Set("Content-Type", "application/json")
+		c.Params = append(c.Params, gin.Param{Key: "key", Value: "user"})
+		api.PutKeyValue(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		expected := fmt.Sprintf(`{"hash": "%s", "deleteToken": "token", "version": %d}`, hash, version)
+		assert.JSONEq(t, expected, w.Body.String())
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest("GET", "/kv/user", nil)
+	c.Params = append(c.Params, gin.Param{Key: "key", Value: "user"})
+	api.GetKeyValue(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"2"`, w.Header().Get("ETag"))
+	assert.Contains(t, w.Body.String(), `"value":"version 2"`)
+}
+
+func TestGetMissingKeyValue(t *testing.T) {
+	api := NewKademliaAPI(&KademliaMock{DataStore: kademlia.NewInMemoryDataStore()})
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest("GET", "/kv/user", nil)
+	c.Params = append(c.Params, gin.Param{Key: "key", Value: "user"})
+	api.GetKeyValue(c)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestPostObjects(t *testing.T) {
+	api := NewKademliaAPI(&KademliaMock{DataStore: kademlia.NewInMemoryDataStore()})
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest("POST", "/objects/batch", strings.NewReader(`{"values": ["first", ""]}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	api.PostObjects(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	expected := fmt.Sprintf(`[
+		{"hash": "%s", "deleteToken": "token"},
+		{"hash": "%s", "error": "found no node to store the value in"}
+	]`, kademlia.NewKey("first").GetHashString(), kademlia.NewKey("").GetHashString())
+	assert.JSONEq(t, expected, w.Body.String())
+}
+
+func TestLookupObjects(t *testing.T) {
+	dataStore := kademlia.NewInMemoryDataStore()
+	text := kademlia.NewTextValue("text")
+	binary := kademlia.NewValue([]byte{1, 2}, kademlia.BinaryContentType)
+	dataStore.Insert(text.GetKey(), text, kademlia.DefaultTTL)
+	dataStore.Insert(binary.GetKey(), binary, kademlia.DefaultTTL)
+	api := NewKademliaAPI(&KademliaMock{DataStore: dataStore})
+	missing := kademlia.NewKey("missing").GetHashString()
+
+	body := fmt.Sprintf(`{"hashes": ["%s", "%s", "%s", "invalid"]}`, text.GetKey().GetHashString(), binary.GetKey().GetHashString(), missing)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest("POST", "/objects/lookup", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	api.LookupObjects(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	expected := fmt.Sprintf(`[
+		{"hash": "%s", "found": true, "value": "text", "contentType": "%s", "size": 4},
+		{"hash": "%s", "found": true, "data": "AQI=", "contentType": "%s", "size": 2},
+		{"hash": "%s", "found": false},
+		{"hash": "invalid", "found": false, "error": "Invalid hash"}
+	]`, text.GetKey().GetHashString(), kademlia.TextContentType, binary.GetKey().GetHashString(), kademlia.BinaryContentType, missing)
+	assert.JSONEq(t, expected, w.Body.String())
+}
+
+func TestProviders(t *testing.T) {
+	api := NewKademliaAPI(&KademliaMock{})
+	hash := kademlia.NewKey("kademlia").GetHashString()
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest("PUT", "/providers/"+hash, nil)
+	c.Params = append(c.Params, gin.Param{Key: "hash", Value: hash})
+	api.PutProvider(c)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest("GET", "/providers/"+hash, nil)
+	c.Params = append(c.Params, gin.Param{Key: "hash", Value: hash})
+	api.GetProviders(c)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[{"id": "0000000000000000000000000000000000000001", "ip": "10.0.0.1", "port": 3000}]`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest("DELETE", "/providers/"+hash, nil)
+	c.Params = append(c.Params, gin.Param{Key: "hash", Value: hash})
+	api.DeleteProvider(c)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest("DELETE", "/providers/"+hash, nil)
+	c.Params = append(c.Params, gin.Param{Key: "hash", Value: hash})
+	api.DeleteProvider(c)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestPutProviderInvalidTTL(t *testing.T) {
+	api := NewKademliaAPI(&KademliaMock{})
+	hash := kademlia.NewKey("kademlia").GetHashString()
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest("PUT", "/providers/"+hash+"?ttl=-1", nil)
+	c.Params = append(c.Params, gin.Param{Key: "hash", Value: hash})
+	api.PutProvider(c)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestTopics(t *testing.T) {
+	api := NewKademliaAPI(&KademliaMock{})
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest("POST", "/topics/news", strings.NewReader(`{"value": "hello"}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Params = append(c.Params, gin.Param{Key: "topic", Value: "news"})
+	api.PostTopicMessage(c)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, fmt.Sprintf(`{"hash": "%s"}`, kademlia.NewTopicKey("news").GetHashString()), w.Body.String())
+
+	// The stream ends when the client disconnects
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequestWithContext(ctx, "GET", "/topics/news/messages", nil)
+	c.Params = append(c.Params, gin.Param{Key: "topic", Value: "news"})
+	api.GetTopicMessages(c)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/event-stream", w.Header().Get("Content-Type"))
+	expected := `event:message
+data:{"id":"1","topic":"news","value":"hello","publisher":{"id":"0000000000000000000000000000000000000001","ip":"10.0.0.1","port":3000},"time":"1970-01-01T00:00:00Z"}
+
+`
+	assert.Equal(t, expected, w.Body.String())
+}
+
+func TestPostAndGetFile(t *testing.T) {
+	api := NewKademliaAPI(&KademliaMock{DataStore: kademlia.NewInMemoryDataStore()})
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest("POST", "/files?name=photo.png", bytes.NewReader([]byte{1, 2, 3}))
+	c.Request.Header.Set("Content-Type", "image/png")
+	api.PostFile(c)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var hash HashDTO
+	json.Unmarshal(w.Body.Bytes(), &hash)
+	assert.Equal(t, "/files/"+hash.Hash, w.Header().Get("Location"))
+
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest("GET", "/files/"+hash.Hash, nil)
+	c.Params = append(c.Params, gin.Param{Key: "hash", Value: hash.Hash})
+	api.GetFile(c)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "image/png", w.Header().Get("Content-Type"))
+	assert.Equal(t, "attachment; filename=photo.png", w.Header().Get("Content-Disposition"))
+	assert.Equal(t, []byte{1, 2, 3}, w.Body.Bytes())
+}
+
+func TestGetMissingFile(t *testing.T) {
+	api := NewKademliaAPI(&KademliaMock{DataStore: kademlia.NewInMemoryDataStore()})
+	hash := kademlia.NewKey("missing").GetHashString()
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest("GET", "/files/"+hash, nil)
+	c.Params = append(c.Params, gin.Param{Key: "hash", Value: hash})
+	api.GetFile(c)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestErasureCoded(t *testing.T) {
+	api := NewKademliaAPI(&KademliaMock{DataStore: kademlia.NewInMemoryDataStore()})
+	hash := kademlia.NewKey("sharded").GetHashString()
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest("POST", "/erasure?data=3&parity=2", strings.NewReader(`{"value": "sharded"}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	api.PostErasureCoded(c)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "/erasure/"+hash, w.Header().Get("Location"))
+
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest("GET", "/erasure/"+hash, nil)
+	c.Params = append(c.Params, gin.Param{Key: "hash", Value: hash})
+	api.GetErasureCoded(c)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var object ObjectDTO
+	json.Unmarshal(w.Body.Bytes(), &object)
+	assert.Equal(t, "sharded", object.Value)
+
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest("POST", "/erasure/"+hash+"/repair", nil)
+	c.Params = append(c.Params, gin.Param{Key: "hash", Value: hash})
+	api.RepairErasureCoded(c)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, fmt.Sprintf(`{"hash": "%s", "repaired": 2}`, hash), w.Body.String())
+}
+
+func TestPostErasureCodedInvalidShards(t *testing.T) {
+	api := NewKademliaAPI(&KademliaMock{DataStore: kademlia.NewInMemoryDataStore()})
+
+	for _, query := range []string{"data=many", "parity=0", "data=1000"} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest("POST", "/erasure?"+query, strings.NewReader(`{"value": "sharded"}`))
+		c.Request.Header.Set("Content-Type", "application/json")
+		api.PostErasureCoded(c)
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
@@ -3,8 +3,11 @@ package cli
 import (
 	"fmt"
 	"io"
+	"mime"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/arianfiftyone/src/kademlia"
 )
@@ -13,6 +16,10 @@ const (
 	noArgsError       = "Please provide a correct ARGUMENT!"
 	commandError      = "Please provide a correct COMMAND!"
 	fileNotFoundError = "Could find not and open the file: "
+	ttlError          = "Please provide a correct TTL, for example 30s or 5m!"
+	durationError     = "Please provide a correct DURATION, for example 30s or 5m!"
+
+	defaultSubscribeDuration = time.Minute // How long `subscribe` prints messages if no duration is given
 )
 
 var (
@@ -31,15 +38,46 @@ func (cli *Cli) HandleCommands(output io.Writer, kademliaInstance kademlia.Kadem
 	switch command {
 
 	case "put", "p":
-		if numArgs == 2 {
-			key, err := Put(kademliaInstance, commands[1])
+		if (numArgs == 3 || numArgs == 4) && commands[1] == "-f" {
+			ttl, ok := parseTTL(output, commands[3:])
+			if !ok {
+				return
+			}
+			file, err := os.ReadFile(commands[2])
+			if err != nil {
+				fmt.Fprintln(output, fileNotFoundError+commands[2])
+				return
+			}
+
+			contents := []string{}
+			for _, line := range strings.Split(string(file), "\n") {
+				if line = strings.TrimSuffix(line, "\r"); line != "" {
+					contents = append(contents, line)
+				}
+			}
+			for i, result := range PutMany(kademliaInstance, contents, ttl) {
+				if result.Err != nil {
+					fmt.Fprintf(output, "%s: error when storing content: %s\n", contents[i], result.Err.Error())
+				} else {
+					fmt.Fprintf(output, "%s: got hash %s, delete token %s\n", contents[i], result.Key.GetHashString(), result.Result.DeleteToken)
+				}
+			}
+
+		} else if numArgs == 2 || numArgs == 3 {
+			ttl, ok := parseTTL(output, commands[2:])
+			if !ok {
+				return
+			}
+			result, err := Put(kademliaInstance, commands[1], ttl)
 			if err != nil {
 				customErr := fmt.Errorf("error when storing content: %s", err.Error())
 				fmt.Fprintln(output, customErr)
 			} else {
-				fmt.Fprintln(output, "Got hash: "+key)
+				fmt.Fprintln(output, "Got hash: "+result.Key.GetHashString())
+				fmt.Fprintln(output, "Delete token: "+result.DeleteToken)
 
 			}
+			printStoreResult(output, result)
 
 		} else {
 			fmt.Fprintln(output, noArgsError)
@@ -47,24 +85,170 @@ func (cli *Cli) HandleCommands(output io.Writer, kademliaInstance kademlia.Kadem
 
 	case "get", "g":
 		if numArgs == 2 {
-			kademliaId, err := kademlia.NewKademliaID(commands[1])
+			key, err := kademlia.ParseKey(commands[1])
 			if err != nil {
 				customErr := fmt.Errorf("error when looking up data %s", err.Error())
 				fmt.Fprintln(output, customErr)
 				return
 			}
 
-			content, err := Get(kademliaInstance, kademlia.GetKeyRepresentationOfKademliaId(kademliaId))
+			value, err := Get(kademliaInstance, key)
 			if err != nil {
 				customErr := fmt.Errorf("error when looking up data %s", err.Error())
 				fmt.Fprintln(output, customErr)
 			} else {
-				if content != "" {
-					fmt.Fprintln(output, "Got content: "+content)
-				} else {
-					fmt.Fprintln(output, "Does not exist.")
-				}
+				printValue(output, value)
+			}
+		} else {
+			fmt.Fprintln(output, noArgsError)
+		}
+
+	case "putfile":
+		if numArgs == 2 || numArgs == 3 {
+			ttl, ok := parseTTL(output, commands[2:])
+			if !ok {
+				return
+			}
+			data, err := os.ReadFile(commands[1])
+			if err != nil {
+				fmt.Fprintln(output, fileNotFoundError+commands[1])
+				return
+			}
+
+			contentType := mime.TypeByExtension(filepath.Ext(commands[1]))
+			result, err := kademliaInstance.StoreFile(filepath.Base(commands[1]), data, contentType, ttl)
+			if err != nil {
+				customErr := fmt.Errorf("error when storing file: %s", err.Error())
+				fmt.Fprintln(output, customErr)
+			} else {
+				fmt.Fprintf(output, "Stored %d bytes, the manifest got hash: %s\n", len(data), result.Key.GetHashString())
+				fmt.Fprintln(output, "Delete token: "+result.DeleteToken)
+			}
+			printStoreResult(output, result)
+
+		} else {
+			fmt.Fprintln(output, noArgsError)
+		}
 
+	case "getfile":
+		if numArgs == 3 {
+			key, err := kademlia.ParseKey(commands[1])
+			if err != nil {
+				customErr := fmt.Errorf("error when looking up file %s", err.Error())
+				fmt.Fprintln(output, customErr)
+				return
+			}
+
+			manifest, data, err := kademliaInstance.GetFile(key)
+			if err != nil {
+				customErr := fmt.Errorf("error when looking up file %s", err.Error())
+				fmt.Fprintln(output, customErr)
+				return
+			}
+			err = os.WriteFile(commands[2], data, 0644)
+			if err != nil {
+				customErr := fmt.Errorf("error when writing file %s", err.Error())
+				fmt.Fprintln(output, customErr)
+				return
+			}
+			fmt.Fprintf(output, "Wrote %s (%d bytes) to %s\n", manifest.Name, len(data), commands[2])
+
+		} else {
+			fmt.Fprintln(output, noArgsError)
+		}
+
+	case "putec":
+		if numArgs == 2 || numArgs == 3 {
+			ttl, ok := parseTTL(output, commands[2:])
+			if !ok {
+				return
+			}
+			result, err := kademliaInstance.StoreErasureCoded(kademlia.NewTextValue(commands[1]), kademlia.DefaultErasureCoding, ttl)
+			if err != nil {
+				customErr := fmt.Errorf("error when storing content: %s", err.Error())
+				fmt.Fprintln(output, customErr)
+			} else {
+				fmt.Fprintf(output, "Stored %d shards, got hash: %s\n", kademlia.DefaultErasureCoding.TotalShards(), result.Key.GetHashString())
+			}
+			printStoreResult(output, result)
+
+		} else {
+			fmt.Fprintln(output, noArgsError)
+		}
+
+	case "getec":
+		if numArgs == 2 {
+			key, err := kademlia.ParseKey(commands[1])
+			if err != nil {
+				customErr := fmt.Errorf("error when looking up data %s", err.Error())
+				fmt.Fprintln(output, customErr)
+				return
+			}
+
+			value, err := kademliaInstance.LookupErasureCoded(key)
+			if err != nil {
+				customErr := fmt.Errorf("error when looking up data %s", err.Error())
+				fmt.Fprintln(output, customErr)
+			} else {
+				printValue(output, value)
+			}
+		} else {
+			fmt.Fprintln(output, noArgsError)
+		}
+
+	case "repair":
+		if numArgs == 2 || numArgs == 3 {
+			key, err := kademlia.ParseKey(commands[1])
+			if err != nil {
+				customErr := fmt.Errorf("error when parsing the hash %s", err.Error())
+				fmt.Fprintln(output, customErr)
+				return
+			}
+			ttl, ok := parseTTL(output, commands[2:])
+			if !ok {
+				return
+			}
+
+			repaired, err := kademliaInstance.RepairErasureCoded(key, ttl)
+			if err != nil {
+				customErr := fmt.Errorf("error when repairing data %s", err.Error())
+				fmt.Fprintln(output, customErr)
+			} else {
+				fmt.Fprintf(output, "Regenerated %d lost shards.\n", repaired)
+			}
+		} else {
+			fmt.Fprintln(output, noArgsError)
+		}
+
+	case "kvput":
+		if numArgs == 3 || numArgs == 4 {
+			ttl, ok := parseTTL(output, commands[3:])
+			if !ok {
+				return
+			}
+			result, err := kademliaInstance.Put(commands[1], kademlia.NewTextValue(commands[2]), ttl)
+			if err != nil {
+				customErr := fmt.Errorf("error when storing content: %s", err.Error())
+				fmt.Fprintln(output, customErr)
+			} else {
+				fmt.Fprintf(output, "Stored version %d under hash: %s\n", result.Version, result.Key.GetHashString())
+				fmt.Fprintln(output, "Delete token: "+result.DeleteToken)
+			}
+			printStoreResult(output, result)
+
+		} else {
+			fmt.Fprintln(output, noArgsError)
+		}
+
+	case "kvget":
+		if numArgs == 2 {
+			keyValue, err := kademliaInstance.Get(commands[1])
+			if err != nil {
+				customErr := fmt.Errorf("error when looking up data %s", err.Error())
+				fmt.Fprintln(output, customErr)
+			} else {
+				fmt.Fprintf(output, "Version %d\n", keyValue.Version)
+				printValue(output, &keyValue.Value)
 			}
 		} else {
 			fmt.Fprintln(output, noArgsError)
@@ -105,14 +289,14 @@ func (cli *Cli) HandleCommands(output io.Writer, kademliaInstance kademlia.Kadem
 
 	case "forget":
 		if numArgs == 2 {
-			kademliaId, err := kademlia.NewKademliaID(commands[1])
+			key, err := kademlia.ParseKey(commands[1])
 			if err != nil {
-				customErr := fmt.Errorf("error when creating new kademliaID %s", err.Error())
+				customErr := fmt.Errorf("error when parsing the hash %s", err.Error())
 				fmt.Fprintln(output, customErr)
 				return
 			}
 
-			err = Forget(kademliaInstance, kademlia.GetKeyRepresentationOfKademliaId(kademliaId))
+			err = Forget(kademliaInstance, key)
 
 			if err != nil {
 				customErr := fmt.Errorf("error when forgetting data %s", err.Error())
@@ -125,35 +309,252 @@ func (cli *Cli) HandleCommands(output io.Writer, kademliaInstance kademlia.Kadem
 			fmt.Fprintln(output, noArgsError)
 		}
 
+	case "pin", "unpin":
+		if numArgs == 2 {
+			key, err := kademlia.ParseKey(commands[1])
+			if err != nil {
+				customErr := fmt.Errorf("error when parsing the hash %s", err.Error())
+				fmt.Fprintln(output, customErr)
+				return
+			}
+
+			if command == "pin" {
+				err = kademliaInstance.Pin(key)
+			} else {
+				err = kademliaInstance.Unpin(key)
+			}
+			if err != nil {
+				customErr := fmt.Errorf("error when %sning data %s", command, err.Error())
+				fmt.Fprintln(output, customErr)
+			} else {
+				fmt.Fprintf(output, "The data object has been %sned.\n", command)
+			}
+
+		} else {
+			fmt.Fprintln(output, noArgsError)
+		}
+
+	case "pins":
+		if numArgs == 1 {
+			for _, key := range kademliaInstance.GetPins() {
+				fmt.Fprintln(output, key.GetHashString())
+			}
+		} else {
+			fmt.Fprintln(output, noArgsError)
+		}
+
+	case "provide", "unprovide":
+		if numArgs == 2 {
+			key, err := kademlia.ParseKey(commands[1])
+			if err != nil {
+				customErr := fmt.Errorf("error when parsing the hash %s", err.Error())
+				fmt.Fprintln(output, customErr)
+				return
+			}
+
+			if command == "provide" {
+				result, err := kademliaInstance.Provide(key, 0)
+				if err != nil {
+					customErr := fmt.Errorf("error when announcing provider %s", err.Error())
+					fmt.Fprintln(output, customErr)
+				} else {
+					fmt.Fprintln(output, "This node is announced as a provider of the data object.")
+				}
+				printStoreResult(output, result)
+			} else if err = kademliaInstance.StopProviding(key); err != nil {
+				customErr := fmt.Errorf("error when announcing provider %s", err.Error())
+				fmt.Fprintln(output, customErr)
+			} else {
+				fmt.Fprintln(output, "This node has stopped being announced as a provider of the data object.")
+			}
+
+		} else {
+			fmt.Fprintln(output, noArgsError)
+		}
+
+	case "providers":
+		if numArgs == 2 {
+			key, err := kademlia.ParseKey(commands[1])
+			if err != nil {
+				customErr := fmt.Errorf("error when parsing the hash %s", err.Error())
+				fmt.Fprintln(output, customErr)
+				return
+			}
+
+			providers, err := kademliaInstance.FindProviders(key)
+			if err != nil {
+				customErr := fmt.Errorf("error when looking up providers %s", err.Error())
+				fmt.Fprintln(output, customErr)
+				return
+			}
+			for _, provider := range providers {
+				fmt.Fprintf(output, "%s %s:%d\n", provider.ID.String(), provider.Ip, provider.Port)
+			}
+
+		} else {
+			fmt.Fprintln(output, noArgsError)
+		}
+
+	case "subscribe":
+		if numArgs == 2 || numArgs == 3 {
+			duration := defaultSubscribeDuration
+			if numArgs == 3 {
+				var err error
+				duration, err = time.ParseDuration(commands[2])
+				if err != nil || duration <= 0 {
+					fmt.Fprintln(output, durationError)
+					return
+				}
+			}
+
+			subscription, err := kademliaInstance.Subscribe(commands[1])
+			if err != nil {
+				customErr := fmt.Errorf("error when subscribing %s", err.Error())
+				fmt.Fprintln(output, customErr)
+				return
+			}
+			defer subscription.Close()
+
+			fmt.Fprintf(output, "Subscribed to %s for %s.\n", commands[1], duration)
+			timeout := time.After(duration)
+			for {
+				select {
+				case message, ok := <-subscription.Messages:
+					if !ok {
+						return
+					}
+					fmt.Fprintf(output, "%s:%d: %s\n", message.Publisher.Ip, message.Publisher.Port, string(message.Data))
+				case <-timeout:
+					return
+				}
+			}
+
+		} else {
+			fmt.Fprintln(output, noArgsError)
+		}
+
+	case "publish":
+		if numArgs == 3 {
+			result, err := kademliaInstance.Publish(commands[1], []byte(commands[2]))
+			if err != nil {
+				customErr := fmt.Errorf("error when publishing %s", err.Error())
+				fmt.Fprintln(output, customErr)
+			} else {
+				fmt.Fprintln(output, "The message has been published.")
+			}
+			printStoreResult(output, result)
+
+		} else {
+			fmt.Fprintln(output, noArgsError)
+		}
+
+	case "published":
+		if numArgs == 1 {
+			for _, publication := range kademliaInstance.GetPublications() {
+				fmt.Fprintf(output, "%s %d replicas, last republished %s\n", publication.Key.GetHashString(), publication.Replicas, publication.LastRepublished.Format(time.RFC3339))
+			}
+		} else {
+			fmt.Fprintln(output, noArgsError)
+		}
+
+	case "delete", "d":
+		if numArgs == 3 {
+			key, err := kademlia.ParseKey(commands[1])
+			if err != nil {
+				customErr := fmt.Errorf("error when parsing the hash %s", err.Error())
+				fmt.Fprintln(output, customErr)
+				return
+			}
+
+			result, err := Delete(kademliaInstance, key, commands[2])
+			if err != nil {
+				customErr := fmt.Errorf("error when deleting data %s", err.Error())
+				fmt.Fprintln(output, customErr)
+			} else {
+				fmt.Fprintln(output, "The data object has been deleted.")
+			}
+			printStoreResult(output, result)
+
+		} else {
+			fmt.Fprintln(output, noArgsError)
+		}
+
 	default:
 		fmt.Fprintln(output, commandError)
 	}
 
 }
 
-func Put(kademlia kademlia.Kademlia, content string) (string, error) {
-	key, err := kademlia.Store(content)
+// Put stores the content as text for the given time to live, zero gives the default time to live.
+func Put(kademliaInstance kademlia.Kademlia, content string, ttl time.Duration) (*kademlia.StoreResult, error) {
+	return kademliaInstance.Store(kademlia.NewTextValue(content), ttl)
+}
 
-	if err != nil {
-		return "", err
+// parseTTL parses the optional time to live argument, which is zero if it is left out. It prints an error and returns
+// false if the argument is invalid.
+func parseTTL(output io.Writer, args []string) (time.Duration, bool) {
+	if len(args) == 0 {
+		return 0, true
+	}
+	ttl, err := time.ParseDuration(args[0])
+	if err != nil || ttl < 0 {
+		fmt.Fprintln(output, ttlError)
+		return 0, false
+	}
+	return ttl, true
+}
+
+// printValue prints a text value, what file a manifest describes, or the size and content type of a binary value. The
+// value may be nil.
+func printValue(output io.Writer, value *kademlia.Value) {
+	if value == nil {
+		fmt.Fprintln(output, "Does not exist.")
+	} else if value.ContentType == kademlia.TextContentType {
+		fmt.Fprintln(output, "Got content: "+string(value.Data))
+	} else if manifest, err := kademlia.ManifestFromValue(*value); err == nil {
+		fmt.Fprintf(output, "Got the manifest of the file %s (%d bytes in %d chunks), download it with getfile\n", manifest.Name, manifest.Size, len(manifest.Chunks))
 	} else {
-		return key.GetHashString(), nil
+		fmt.Fprintf(output, "Got %d bytes of binary content (%s)\n", value.Size, value.ContentType)
 	}
+}
 
+// PutMany stores each content as a text value, and returns a result for each content in the same order.
+func PutMany(kademliaInstance kademlia.Kademlia, contents []string, ttl time.Duration) []kademlia.BatchStoreResult {
+	values := []kademlia.Value{}
+	for _, content := range contents {
+		values = append(values, kademlia.NewTextValue(content))
+	}
+	return kademliaInstance.StoreMany(values, ttl)
 }
 
-func Get(kademlia kademlia.Kademlia, key *kademlia.Key) (string, error) {
-	_, value, err := kademlia.LookupData(key)
+// Delete removes the value of the key from the network, the token is the delete token returned when it was stored.
+func Delete(kademliaInstance kademlia.Kademlia, key *kademlia.Key, token string) (*kademlia.StoreResult, error) {
+	return kademliaInstance.Delete(key, token)
+}
 
-	if err != nil {
-		return "", err
+// printStoreResult prints what each replica answered a store with, the result may be nil.
+func printStoreResult(output io.Writer, result *kademlia.StoreResult) {
+	if result == nil {
+		return
+	}
+	for _, replica := range result.Replicas {
+		line := fmt.Sprintf("%s:%d %s", replica.Contact.Ip, replica.Contact.Port, strings.ToLower(strings.ReplaceAll(string(replica.State), "_", " ")))
+		if replica.Reason != "" {
+			line += ": " + replica.Reason
+		}
+		fmt.Fprintln(output, line)
 	}
+}
 
-	if value != "" {
-		return value, nil
+// Get returns the value of the key, or nil if it does not exist.
+func Get(kademlia kademlia.Kademlia, key *kademlia.Key) (*kademlia.Value, error) {
+	_, value, err := kademlia.LookupData(key)
+
+	if err != nil {
+		return nil, err
 	}
 
-	return "", nil
+	return value, nil
 }
 
 func Kill() {
@@ -2,24 +2,52 @@ package cli
 
 import (
 	"bytes"
+	"errors"
 	"io"
 	"log"
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/arianfiftyone/src/kademlia"
 	"github.com/stretchr/testify/assert"
 )
 
 type KademliaMock struct {
-	DataStore *kademlia.DataStore
+	DataStore    kademlia.DataStore
+	Publications []kademlia.Publication
+	Provided     []*kademlia.Key
+	Topics       map[string]chan kademlia.TopicMessage
 }
 
 func (KademliaMock *KademliaMock) Start() {}
 func (KademliaMock *KademliaMock) Join()  {}
-func (KademliaMock *KademliaMock) Store(content string) (*kademlia.Key, error) {
-	return kademlia.NewKey(content), nil
+func (KademliaMock *KademliaMock) Store(value kademlia.Value, ttl time.Duration) (*kademlia.StoreResult, error) {
+	return &kademlia.StoreResult{Key: value.GetKey(), DeleteToken: "token"}, nil
+}
+
+func (KademliaMock *KademliaMock) PutRecord(record kademlia.Record, ttl time.Duration) (*kademlia.StoreResult, error) {
+	return &kademlia.StoreResult{Key: record.GetKey()}, nil
+}
+
+func (KademliaMock *KademliaMock) Put(key string, value kademlia.Value, ttl time.Duration) (*kademlia.StoreResult, error) {
+	keyValue := kademlia.KeyValue{Key: key, Version: 1, Value: value}
+	if current, err := KademliaMock.Get(key); err == nil {
+		keyValue.Version = current.Version + 1
+	}
+	stored, _ := keyValue.ToValue()
+	KademliaMock.DataStore.Insert(keyValue.GetKey(), stored, kademlia.DefaultTTL)
+	return &kademlia.StoreResult{Key: keyValue.GetKey(), DeleteToken: "token", Version: keyValue.Version}, nil
+}
+
+func (KademliaMock *KademliaMock) Get(key string) (*kademlia.KeyValue, error) {
+	value, err := KademliaMock.DataStore.Get(kademlia.NewKeyValueKey(key))
+	if err != nil {
+		return nil, err
+	}
+	keyValue, err := kademlia.KeyValueFromValue(value)
+	return &keyValue, err
 }
 
 func (KademliaMock *KademliaMock) GetKademliaNode() *kademlia.KademliaNode {
@@ -34,15 +62,185 @@ func (KademliaMock *KademliaMock) LookupContact(targetId *kademlia.KademliaID) (
 	return nil, nil
 }
 
-func (KademliaMock *KademliaMock) LookupData(key *kademlia.Key) ([]kademlia.Contact, string, error) {
-	content, err := KademliaMock.DataStore.Get(key)
-	return nil, content, err
+func (KademliaMock *KademliaMock) LookupData(key *kademlia.Key) ([]kademlia.Contact, *kademlia.Value, error) {
+	value, err := KademliaMock.DataStore.Get(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return nil, &value, nil
+}
+
+func (KademliaMock *KademliaMock) LookupDataInNamespace(key *kademlia.Key, namespace string) ([]kademlia.Contact, *kademlia.Value, error) {
+	_, value, err := KademliaMock.LookupData(key)
+	if err != nil || value.Namespace != namespace {
+		return nil, nil, err
+	}
+	return nil, value, nil
+}
+
+func (KademliaMock *KademliaMock) LookupDataWithQuorum(key *kademlia.Key, quorum int, repair bool) (*kademlia.Value, kademlia.ReplicaReport, error) {
+	_, value, err := KademliaMock.LookupData(key)
+	return value, kademlia.ReplicaReport{}, err
+}
+
+func (KademliaMock *KademliaMock) StoreMany(values []kademlia.Value, ttl time.Duration) []kademlia.BatchStoreResult {
+	results := []kademlia.BatchStoreResult{}
+	for _, value := range values {
+		if len(value.Data) == 0 {
+			results = append(results, kademlia.BatchStoreResult{Key: value.GetKey(), Err: errors.New("found no node to store the value in")})
+			continue
+		}
+		KademliaMock.DataStore.Insert(value.GetKey(), value, kademlia.DefaultTTL)
+		result, _ := KademliaMock.Store(value, ttl)
+		results = append(results, kademlia.BatchStoreResult{Key: result.Key, Result: result})
+	}
+	return results
+}
+
+func (KademliaMock *KademliaMock) LookupMany(keys []*kademlia.Key) []kademlia.BatchLookupResult {
+	results := []kademlia.BatchLookupResult{}
+	for _, key := range keys {
+		value, err := KademliaMock.DataStore.Get(key)
+		if err != nil {
+			results = append(results, kademlia.BatchLookupResult{Key: key})
+		} else {
+			results = append(results, kademlia.BatchLookupResult{Key: key, Value: &value})
+		}
+	}
+	return results
+}
+
+func (KademliaMock *KademliaMock) StoreFile(name string, data []byte, contentType string, ttl time.Duration) (*kademlia.StoreResult, error) {
+	chunk := kademlia.NewValue(data, kademlia.BinaryContentType)
+	KademliaMock.DataStore.Insert(chunk.GetKey(), chunk, kademlia.DefaultTTL)
+	manifest := kademlia.Manifest{Name: name, Size: len(data), ContentType: contentType, ChunkSize: kademlia.ChunkSize, Chunks: []string{chunk.GetKey().GetHashString()}}
+	value, _ := manifest.ToValue()
+	KademliaMock.DataStore.Insert(value.GetKey(), value, kademlia.DefaultTTL)
+	return &kademlia.StoreResult{Key: value.GetKey(), DeleteToken: "token"}, nil
+}
+
+func (KademliaMock *KademliaMock) GetFile(key *kademlia.Key) (*kademlia.Manifest, []byte, error) {
+	value, err := KademliaMock.DataStore.Get(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	manifest, err := kademlia.ManifestFromValue(value)
+	if err != nil {
+		return nil, nil, err
+	}
+	data := []byte{}
+	for _, hash := range manifest.Chunks {
+		chunkKey, _ := kademlia.ParseKey(hash)
+		chunk, err := KademliaMock.DataStore.Get(chunkKey)
+		if err != nil {
+			return nil, nil, err
+		}
+		data = append(data, chunk.Data...)
+	}
+	return &manifest, data, nil
+}
+
+func (KademliaMock *KademliaMock) StoreErasureCoded(value kademlia.Value, coding kademlia.ErasureCoding, ttl time.Duration) (*kademlia.StoreResult, error) {
+	if err := coding.Validate(); err != nil {
+		return nil, err
+	}
+	KademliaMock.DataStore.Insert(value.GetKey(), value, kademlia.DefaultTTL)
+	return &kademlia.StoreResult{Key: value.GetKey()}, nil
+}
+
+func (KademliaMock *KademliaMock) LookupErasureCoded(key *kademlia.Key) (*kademlia.Value, error) {
+	value, err := KademliaMock.DataStore.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	return &value, nil
+}
+
+func (KademliaMock *KademliaMock) RepairErasureCoded(key *kademlia.Key, ttl time.Duration) (int, error) {
+	if _, err := KademliaMock.DataStore.Get(key); err != nil {
+		return 0, errors.New("no shard of the value was found")
+	}
+	return 2, nil
 }
 
 func (KademliaMock *KademliaMock) Forget(key *kademlia.Key) error {
 	return nil
 }
 
+func (KademliaMock *KademliaMock) Pin(key *kademlia.Key) error {
+	return KademliaMock.DataStore.Pin(key)
+}
+
+func (KademliaMock *KademliaMock) Unpin(key *kademlia.Key) error {
+	return KademliaMock.DataStore.Unpin(key)
+}
+
+func (KademliaMock *KademliaMock) GetPins() []*kademlia.Key {
+	keys := []*kademlia.Key{}
+	for _, item := range KademliaMock.DataStore.Items() {
+		if item.Pinned {
+			keys = append(keys, item.Key)
+		}
+	}
+	return keys
+}
+
+func (KademliaMock *KademliaMock) GetPublications() []kademlia.Publication {
+	return KademliaMock.Publications
+}
+
+func (KademliaMock *KademliaMock) Provide(key *kademlia.Key, ttl time.Duration) (*kademlia.StoreResult, error) {
+	KademliaMock.Provided = append(KademliaMock.Provided, key)
+	return &kademlia.StoreResult{Key: key}, nil
+}
+
+func (KademliaMock *KademliaMock) StopProviding(key *kademlia.Key) error {
+	for i, provided := range KademliaMock.Provided {
+		if provided.Equals(key) {
+			KademliaMock.Provided = append(KademliaMock.Provided[:i], KademliaMock.Provided[i+1:]...)
+			return nil
+		}
+	}
+	return errors.New("key not found")
+}
+
+func (KademliaMock *KademliaMock) FindProviders(key *kademlia.Key) ([]kademlia.Contact, error) {
+	providers := []kademlia.Contact{}
+	for _, provided := range KademliaMock.Provided {
+		if provided.Equals(key) {
+			providers = append(providers, kademlia.NewContact(kademlia.GenerateNewKademliaID("0000000000000000000000000000000000000001"), "10.0.0.1", 3000))
+		}
+	}
+	return providers, nil
+}
+
+func (KademliaMock *KademliaMock) topic(topic string) chan kademlia.TopicMessage {
+	if KademliaMock.Topics == nil {
+		KademliaMock.Topics = make(map[string]chan kademlia.TopicMessage)
+	}
+	if _, ok := KademliaMock.Topics[topic]; !ok {
+		KademliaMock.Topics[topic] = make(chan kademlia.TopicMessage, 16)
+	}
+	return KademliaMock.Topics[topic]
+}
+
+func (KademliaMock *KademliaMock) Subscribe(topic string) (*kademlia.Subscription, error) {
+	return kademlia.NewSubscription(topic, KademliaMock.topic(topic), func() {}), nil
+}
+
+func (KademliaMock *KademliaMock) Publish(topic string, data []byte) (*kademlia.StoreResult, error) {
+	publisher := kademlia.NewContact(kademlia.GenerateNewKademliaID("0000000000000000000000000000000000000001"), "10.0.0.1", 3000)
+	KademliaMock.topic(topic) <- kademlia.TopicMessage{ID: "1", Topic: topic, Data: data, Publisher: publisher, Time: time.Unix(0, 0).UTC()}
+	return &kademlia.StoreResult{Key: kademlia.NewTopicKey(topic)}, nil
+}
+
+func (KademliaMock *KademliaMock) Delete(key *kademlia.Key, token string) (*kademlia.StoreResult, error) {
+	if token != "token" {
+		return &kademlia.StoreResult{Key: key}, errors.New("no replica deleted the value")
+	}
+	return &kademlia.StoreResult{Key: key}, nil
+}
+
 // Creates a test Kademlia instance
 func createTestKademlia() *kademlia.KademliaImplementation {
 	kademlia := kademlia.NewKademlia("localhost", 9000, true, "10.0.0.1", 100000)
@@ -65,11 +263,11 @@ func TestGet(t *testing.T) {
 	content := "kademlia"
 	key := kademlia.NewKey(content)
 
-	dataStore := kademlia.NewDataStore()
-	dataStore.Insert(key, content)
+	dataStore := kademlia.NewInMemoryDataStore()
+	dataStore.Insert(key, kademlia.NewTextValue(content), kademlia.DefaultTTL)
 
 	cli := NewCli(&KademliaMock{
-		DataStore: &dataStore,
+		DataStore: dataStore,
 	})
 
 	command := []string{
@@ -117,7 +315,36 @@ func TestPut(t *testing.T) {
 
 	output := cli.testCommand(command)
 
-	assert.Equal(t, "Got hash: "+key.GetHashString(), output)
+	assert.Equal(t, "Got hash: "+key.GetHashString()+"\nDelete token: token", output)
+}
+
+func TestPutWithTTL(t *testing.T) {
+	value := "kademlia"
+	key := kademlia.NewKey(value)
+
+	cli := NewCli(&KademliaMock{})
+	command := []string{
+		"put",
+		value,
+		"30s",
+	}
+
+	output := cli.testCommand(command)
+
+	assert.Equal(t, "Got hash: "+key.GetHashString()+"\nDelete token: token", output)
+}
+
+func TestPutWithInvalidTTL(t *testing.T) {
+	cli := NewCli(&KademliaMock{})
+	command := []string{
+		"put",
+		"kademlia",
+		"forever",
+	}
+
+	output := cli.testCommand(command)
+
+	assert.Equal(t, ttlError, output)
 }
 
 func TestPutCommand(t *testing.T) {
@@ -381,3 +608,189 @@ func TestForgetCommand(t *testing.T) {
 	output := cli.testCommand(command)
 	assert.Equal(t, noArgsError, output)
 }
+
+func TestPrintStoreResult(t *testing.T) {
+	output := bytes.NewBuffer(nil)
+	contact := kademlia.NewContact(kademlia.NewRandomKademliaID(), "127.0.0.1", 3000)
+	result := &kademlia.StoreResult{Replicas: []kademlia.StoreReplicaResult{
+		{Contact: contact, State: kademlia.STORE_ACKNOWLEDGED},
+		{Contact: contact, State: kademlia.STORE_REFUSED, Reason: "full"},
+	}}
+
+	printStoreResult(output, result)
+
+	assert.Equal(t, "127.0.0.1:3000 acknowledged\n127.0.0.1:3000 refused: full", trimNewlineFromWriterOutput(output))
+}
+
+func TestDelete(t *testing.T) {
+	key := kademlia.NewKey("kademlia")
+
+	cli := NewCli(&KademliaMock{})
+	command := []string{
+		"delete",
+		key.GetHashString(),
+		"token",
+	}
+
+	output := cli.testCommand(command)
+
+	assert.Equal(t, "The data object has been deleted.", output)
+}
+
+func TestDeleteWithWrongToken(t *testing.T) {
+	key := kademlia.NewKey("kademlia")
+
+	cli := NewCli(&KademliaMock{})
+	command := []string{
+		"delete",
+		key.GetHashString(),
+		"wrong token",
+	}
+
+	output := cli.testCommand(command)
+
+	assert.Equal(t, "error when deleting data no replica deleted the value", output)
+}
+
+func TestDeleteCommand(t *testing.T) {
+	kademliaInstance := createTestKademlia()
+
+	cli := NewCli(kademliaInstance)
+	command := []string{
+		"delete",
+		kademlia.NewKey("kademlia").GetHashString(),
+	}
+
+	output := cli.testCommand(command)
+	assert.Equal(t, noArgsError, output)
+}
+
+func TestPinAndUnpin(t *testing.T) {
+	content := "kademlia"
+	key := kademlia.NewKey(content)
+	dataStore := kademlia.NewInMemoryDataStore()
+	dataStore.Insert(key, kademlia.NewTextValue(content), kademlia.DefaultTTL)
+	cli := NewCli(&KademliaMock{DataStore: dataStore})
+
+	assert.Equal(t, "The data object has been pinned.", cli.testCommand([]string{"pin", key.GetHashString()}))
+	assert.Equal(t, key.GetHashString(), cli.testCommand([]string{"pins"}))
+	assert.Equal(t, "The data object has been unpinned.", cli.testCommand([]string{"unpin", key.GetHashString()}))
+	assert.Equal(t, "", cli.testCommand([]string{"pins"}))
+}
+
+func TestPinMissingData(t *testing.T) {
+	cli := NewCli(&KademliaMock{DataStore: kademlia.NewInMemoryDataStore()})
+
+	output := cli.testCommand([]string{"pin", kademlia.NewKey("kademlia").GetHashString()})
+	assert.Equal(t, "error when pinning data key not found", output)
+}
+
+func TestPinCommand(t *testing.T) {
+	cli := NewCli(&KademliaMock{})
+
+	assert.Equal(t, noArgsError, cli.testCommand([]string{"pin"}))
+	assert.Equal(t, noArgsError, cli.testCommand([]string{"pins", "me"}))
+}
+
+func TestPublished(t *testing.T) {
+	key := kademlia.NewKey("kademlia")
+	lastRepublished := time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC)
+	cli := NewCli(&KademliaMock{Publications: []kademlia.Publication{
+		{Key: key, TTL: time.Hour, LastRepublished: lastRepublished, Replicas: 3},
+	}})
+
+	assert.Equal(t, key.GetHashString()+" 3 replicas, last republished 2023-10-01T12:00:00Z", cli.testCommand([]string{"published"}))
+	assert.Equal(t, noArgsError, cli.testCommand([]string{"published", "me"}))
+}
+
+func TestKeyValueCommands(t *testing.T) {
+	cli := NewCli(&KademliaMock{DataStore: kademlia.NewInMemoryDataStore()})
+	hash := kademlia.NewKeyValueKey("user").GetHashString()
+
+	assert.Equal(t, "Stored version 1 under hash: "+hash+"\nDelete token: token", cli.testCommand([]string{"kvput", "user", "first"}))
+	assert.Equal(t, "Stored version 2 under hash: "+hash+"\nDelete token: token", cli.testCommand([]string{"kvput", "user", "second", "1m"}))
+	assert.Equal(t, "Version 2\nGot content: second", cli.testCommand([]string{"kvget", "user"}))
+	assert.Equal(t, "error when looking up data key not found", cli.testCommand([]string{"kvget", "other"}))
+}
+
+func TestKeyValueCommandArguments(t *testing.T) {
+	cli := NewCli(&KademliaMock{DataStore: kademlia.NewInMemoryDataStore()})
+
+	assert.Equal(t, noArgsError, cli.testCommand([]string{"kvput", "user"}))
+	assert.Equal(t, ttlError, cli.testCommand([]string{"kvput", "user", "first", "soon"}))
+	assert.Equal(t, noArgsError, cli.testCommand([]string{"kvget"}))
+}
+
+func TestPutFile(t *testing.T) {
+	path := t.TempDir() + "/list.txt"
+	os.WriteFile(path, []byte("first\r\n\nsecond\n"), 0644)
+	cli := NewCli(&KademliaMock{DataStore: kademlia.NewInMemoryDataStore()})
+
+	expected := "first: got hash " + kademlia.NewKey("first").GetHashString() + ", delete token token\n" +
+		"second: got hash " + kademlia.NewKey("second").GetHashString() + ", delete token token"
+	assert.Equal(t, expected, cli.testCommand([]string{"put", "-f", path}))
+	assert.Equal(t, expected, cli.testCommand([]string{"put", "-f", path, "1m"}))
+}
+
+func TestPutFileErrors(t *testing.T) {
+	path := t.TempDir() + "/missing.txt"
+	cli := NewCli(&KademliaMock{DataStore: kademlia.NewInMemoryDataStore()})
+
+	assert.Equal(t, fileNotFoundError+path, cli.testCommand([]string{"put", "-f", path}))
+	assert.Equal(t, ttlError, cli.testCommand([]string{"put", "-f", path, "soon"}))
+}
+
+func TestProvideCommands(t *testing.T) {
+	cli := NewCli(&KademliaMock{})
+	hash := kademlia.NewKey("kademlia").GetHashString()
+
+	assert.Equal(t, "This node is announced as a provider of the data object.", cli.testCommand([]string{"provide", hash}))
+	assert.Equal(t, "0000000000000000000000000000000000000001 10.0.0.1:3000", cli.testCommand([]string{"providers", hash}))
+	assert.Equal(t, "This node has stopped being announced as a provider of the data object.", cli.testCommand([]string{"unprovide", hash}))
+	assert.Equal(t, "", cli.testCommand([]string{"providers", hash}))
+	assert.Equal(t, "error when announcing provider key not found", cli.testCommand([]string{"unprovide", hash}))
+	assert.Equal(t, noArgsError, cli.testCommand([]string{"providers"}))
+}
+
+func TestTopicCommands(t *testing.T) {
+	cli := NewCli(&KademliaMock{})
+
+	assert.Equal(t, "The message has been published.", cli.testCommand([]string{"publish", "news", "hello"}))
+	assert.Equal(t, "Subscribed to news for 50ms.\n10.0.0.1:3000: hello", cli.testCommand([]string{"subscribe", "news", "50ms"}))
+	assert.Equal(t, durationError, cli.testCommand([]string{"subscribe", "news", "0s"}))
+	assert.Equal(t, noArgsError, cli.testCommand([]string{"publish", "news"}))
+}
+
+func TestFileCommands(t *testing.T) {
+	directory := t.TempDir()
+	os.WriteFile(directory+"/notes.txt", []byte("chunked"), 0644)
+	cli := NewCli(&KademliaMock{DataStore: kademlia.NewInMemoryDataStore()})
+
+	chunk := kademlia.NewValue([]byte("chunked"), kademlia.BinaryContentType)
+	manifest := kademlia.Manifest{Name: "notes.txt", Size: 7, ContentType: "text/plain; charset=utf-8", ChunkSize: kademlia.ChunkSize, Chunks: []string{chunk.GetKey().GetHashString()}}
+	value, _ := manifest.ToValue()
+	hash := value.GetKey().GetHashString()
+
+	assert.Equal(t, "Stored 7 bytes, the manifest got hash: "+hash+"\nDelete token: token", cli.testCommand([]string{"putfile", directory + "/notes.txt"}))
+	assert.Equal(t, "Wrote notes.txt (7 bytes) to "+directory+"/copy.txt", cli.testCommand([]string{"getfile", hash, directory + "/copy.txt"}))
+	data, _ := os.ReadFile(directory + "/copy.txt")
+	assert.Equal(t, []byte("chunked"), data)
+	assert.Equal(t, "Got the manifest of the file notes.txt (7 bytes in 1 chunks), download it with getfile", cli.testCommand([]string{"get", hash}))
+
+	assert.Equal(t, fileNotFoundError+directory+"/missing.txt", cli.testCommand([]string{"putfile", directory + "/missing.txt"}))
+	assert.Equal(t, noArgsError, cli.testCommand([]string{"getfile", hash}))
+}
+
+func TestErasureCodedCommands(t *testing.T) {
+	cli := NewCli(&KademliaMock{DataStore: kademlia.NewInMemoryDataStore()})
+	hash := kademlia.NewKey("sharded").GetHashString()
+
+	assert.Equal(t, "Stored 6 shards, got hash: "+hash, cli.testCommand([]string{"putec", "sharded"}))
+	assert.Equal(t, "Got content: sharded", cli.testCommand([]string{"getec", hash}))
+	assert.Equal(t, "Regenerated 2 lost shards.", cli.testCommand([]string{"repair", hash, "1m"}))
+
+	missing := kademlia.NewKey("missing").GetHashString()
+	assert.Equal(t, "error when repairing data no shard of the value was found", cli.testCommand([]string{"repair", missing}))
+	assert.Equal(t, ttlError, cli.testCommand([]string{"repair", hash, "soon"}))
+	assert.Equal(t, noArgsError, cli.testCommand([]string{"getec"}))
+}
@@ -20,7 +20,25 @@ VERSION:
 	
 COMMANDS:
 	get, g <hash>      		Takes the hash and outputs the contents of the object and the node it was retrieved from, if it could be downloaded
-	put, p <content>      		Takes the content of the file you are uploading and outputs the hash of the object, if content could be uploaded
+	put, p <content> [ttl]		Takes the content of the file you are uploading and outputs the hash of the object, if content could be uploaded. The optional ttl (e.g. 30s, 5m) sets how long it lives
+	put, p -f <file> [ttl]		Stores each line of the file as an object and outputs the hash of each, sharing lookups between objects
+	putfile <file> [ttl]		Stores the file in chunks and outputs the hash of its manifest
+	getfile <hash> <file>		Downloads the file with the manifest hash and writes it to the given path
+	putec <content> [ttl]		Stores the content erasure coded in 4 data and 2 parity shards instead of replicating it
+	getec <hash>			Rebuilds erasure coded content from its shards and outputs it
+	repair <hash> [ttl]		Regenerates the lost shards of erasure coded content
+	kvput <key> <content> [ttl]	Stores the content under the key, replacing the content stored under it before
+	kvget <key>			Outputs the latest content stored under the key and its version
+	delete, d <hash> <token>	Removes the object from every replica right away, the token is the delete token printed when it was put
+	pin <hash>			Keeps the object on this node and republishes it until it is unpinned
+	unpin <hash>			Lets a pinned object expire on this node again
+	pins				Lists the hashes of the objects pinned on this node
+	provide <hash>			Announces this node as a provider of the object, without storing the object in the network
+	unprovide <hash>		Stops announcing this node as a provider of the object
+	providers <hash>		Lists the nodes that announced they provide the object
+	subscribe <topic> [duration]	Prints the messages published to the topic for the duration (e.g. 30s, 5m), one minute by default
+	publish <topic> <content>	Sends the content to every node subscribed to the topic
+	published			Lists the objects this node publishes, with how many replicas they had when last republished
 	kill, k      			Kills the node
 	kademliaid, kid 		Get id associated with the node	 
 	help, h      			Output this help prompt
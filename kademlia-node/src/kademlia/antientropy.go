@@ -0,0 +1,196 @@
+package kademlia
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/arianfiftyone/src/logger"
+)
+
+const (
+	// SyncInterval is how often a node compares the keys it holds with its closest neighbours.
+	SyncInterval   = time.Second * 5
+	SyncRangeBits  = 4                  // The leading bits of a key hash choosing the range of the key in a summary
+	SyncRanges     = 1 << SyncRangeBits // How many ranges the keyspace is split into in a summary
+	MaxSyncEntries = 128                // How many keys a SYNC_RESPONSE lists, so that it fits in a datagram
+)
+
+// SyncEntry is a key held by a node, with a digest of its value and what is left of the lifetime of its value, which
+// is zero if the value should not be replicated any more.
+type SyncEntry struct {
+	Key    *Key          `json:"key"`
+	Digest []byte        `json:"digest"`
+	TTL    time.Duration `json:"ttl"`
+}
+
+// SyncSummary is a Merkle tree of depth one over the keys two nodes should both hold. Each leaf is the hash of the
+// keys and value digests in one range of the keyspace, and the root is the hash of the leaves.
+type SyncSummary struct {
+	Root   []byte   `json:"root"`
+	Ranges [][]byte `json:"ranges"`
+}
+
+// syncRange returns the range of the keyspace the key belongs to in a summary.
+func syncRange(key *Key) int {
+	return int(key.Hash[0] >> (8 - SyncRangeBits))
+}
+
+// valueDigest returns the digest of the value that tells whether two nodes hold the same value for a key.
+func valueDigest(value Value) []byte {
+	digest := sha256.Sum256(value.Data)
+	return digest[:]
+}
+
+// isAmongClosest reports whether the contact is one of the k closest nodes to the key the routing table knows of,
+// counting the node itself.
+func isAmongClosest(routingTable *RoutingTable, key *Key, contact Contact) bool {
+	target := key.GetKademliaIdRepresentationOfKey()
+	distance := contact.ID.CalcDistance(target)
+	closer := 0
+	if !routingTable.Me.ID.Equals(contact.ID) && routingTable.Me.ID.CalcDistance(target).Less(distance) {
+		closer++
+	}
+	for _, other := range routingTable.FindClosestContacts(target, NumberOfClosestNodesToRetrieved) {
+		if !other.ID.Equals(contact.ID) && !other.ID.Equals(routingTable.Me.ID) && other.ID.CalcDistance(target).Less(distance) {
+			closer++
+		}
+	}
+	return closer < NumberOfClosestNodesToRetrieved
+}
+
+// sharedEntries returns the keys in the data store of the node that both the node and the peer should hold, as far as
+// the routing table of the node knows, ordered by key. Shards are held by one node each, so they are never shared.
+func sharedEntries(kademliaNode KademliaNode, peer Contact) []SyncEntry {
+	routingTable := kademliaNode.GetRoutingTable()
+	entries := []SyncEntry{}
+	for _, item := range kademliaNode.GetDataStore().Items() {
+		if item.Value.Namespace != ShardNamespace && isAmongClosest(routingTable, item.Key, routingTable.Me) && isAmongClosest(routingTable, item.Key, peer) {
+			ttl, ok := replicationTTL(kademliaNode, item)
+			if !ok {
+				ttl = 0
+			}
+			entries = append(entries, SyncEntry{Key: item.Key, Digest: valueDigest(item.Value), TTL: ttl})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return bytes.Compare(entries[i].Key.Hash[:], entries[j].Key.Hash[:]) < 0
+	})
+	return entries
+}
+
+// NewSyncSummary builds the summary of the entries, which must be ordered by key.
+func NewSyncSummary(entries []SyncEntry) SyncSummary {
+	leaves := make([][]byte, SyncRanges)
+	for i := range leaves {
+		hash := sha256.New()
+		for _, entry := range entries {
+			if syncRange(entry.Key) == i {
+				hash.Write(entry.Key.Hash[:])
+				hash.Write(entry.Digest)
+			}
+		}
+		leaves[i] = hash.Sum(nil)
+	}
+
+	root := sha256.New()
+	for _, leaf := range leaves {
+		root.Write(leaf)
+	}
+	return SyncSummary{Root: root.Sum(nil), Ranges: leaves}
+}
+
+// differingEntries returns the entries whose range has a different hash in the other summary, at most MaxSyncEntries
+// of them. If there are more, they are listed from the one at index start modulo their number and wrap around, so
+// that successive responses with different starts list all of them. Nothing is compared beyond the roots if they are
+// equal.
+func differingEntries(entries []SyncEntry, summary SyncSummary, other SyncSummary, start int) []SyncEntry {
+	differing := []SyncEntry{}
+	if bytes.Equal(summary.Root, other.Root) {
+		return differing
+	}
+	for _, entry := range entries {
+		i := syncRange(entry.Key)
+		if i < len(other.Ranges) && bytes.Equal(summary.Ranges[i], other.Ranges[i]) {
+			continue
+		}
+		differing = append(differing, entry)
+	}
+	if len(differing) <= MaxSyncEntries {
+		return differing
+	}
+	start %= len(differing)
+	rotated := append(differing[start:], differing[:start]...)
+	return rotated[:MaxSyncEntries]
+}
+
+// antiEntropy synchronises the data store with the k closest contacts to the node once every sync interval.
+func (kademlia *KademliaImplementation) antiEntropy() {
+	for {
+		<-time.After(SyncInterval)
+		me := kademlia.KademliaNode.GetRoutingTable().Me
+		for _, contact := range kademlia.KademliaNode.GetRoutingTable().FindClosestContacts(me.ID, NumberOfClosestNodesToRetrieved) {
+			if !contact.ID.Equals(me.ID) {
+				kademlia.synchronize(contact)
+			}
+		}
+	}
+}
+
+// synchronize sends the contact a summary of the keys both nodes should hold, and pulls the values of the keys the
+// contact answers with that the node is missing or holds a different value for, for what is left of their lifetime at
+// the contact. It returns how many values were stored. Only the ranges whose hashes differ are listed by the contact, so replicas that agree exchange one summary
+// and nothing else. The contact pulls from this node in the same way when it synchronises.
+func (kademlia *KademliaImplementation) synchronize(contact Contact) int {
+	me := kademlia.KademliaNode.GetRoutingTable().Me
+	entries, err := kademlia.Network.SendSyncMessage(&me, &contact, NewSyncSummary(sharedEntries(kademlia.KademliaNode, contact)))
+	if err != nil {
+		return 0
+	}
+
+	dataStore := kademlia.KademliaNode.GetDataStore()
+	validators := kademlia.KademliaNode.GetValidators()
+	pulled := 0
+	for _, entry := range entries {
+		// The contact no longer replicates a value whose lifetime is nearly over
+		if entry.Key == nil || entry.TTL <= 0 {
+			continue
+		}
+		stored, getErr := dataStore.Peek(entry.Key)
+		if getErr == nil && bytes.Equal(valueDigest(stored), entry.Digest) {
+			continue
+		}
+
+		_, value, err := kademlia.Network.SendFindDataMessage(&me, &contact, entry.Key)
+		if err != nil || value == nil {
+			continue
+		}
+		err = validators.Validate(entry.Key, *value)
+		if err != nil {
+			kademlia.KademliaNode.reportMisbehaviour(contact, "answered a FIND_DATA with an invalid value")
+			continue
+		}
+		if getErr == nil {
+			if validators.ValidateUpdate(entry.Key, stored, *value) != nil {
+				continue
+			}
+			if stored.DeleteTokenHash != nil && bytes.Equal(stored.Data, value.Data) {
+				value.DeleteTokenHash = stored.DeleteTokenHash
+			}
+		}
+
+		ttl := kademlia.KademliaNode.expirationTTL(entry.Key, entry.TTL)
+		err = kademlia.KademliaNode.storeValue(contact.Ip, entry.Key, *value, ttl)
+		if err != nil {
+			logger.Log("Refused to pull the data object " + entry.Key.GetHashString() + ": " + err.Error())
+			continue
+		}
+		pulled++
+	}
+	if pulled > 0 {
+		logger.Log("Pulled " + strconv.Itoa(pulled) + " data objects from " + contact.Ip)
+	}
+	return pulled
+}
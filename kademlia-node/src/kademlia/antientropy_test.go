@@ -0,0 +1,129 @@
+package kademlia
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// NetworkSyncMock answers a SYNC and a FIND_DATA from the data store of a peer, and counts the FIND_DATAs.
+type NetworkSyncMock struct {
+	NetworkMock
+	peer      DataStore
+	findDatas int
+}
+
+func (network *NetworkSyncMock) peerEntries() []SyncEntry {
+	kademliaNode := &KademliaNodeImplementation{RoutingTable: NewRoutingTable(NewContact(NewRandomKademliaID(), "127.0.0.1", 1)), DataStore: network.peer}
+	return sharedEntries(kademliaNode, NewContact(NewRandomKademliaID(), "127.0.0.1", 0))
+}
+
+func (network *NetworkSyncMock) SendSyncMessage(from *Contact, contact *Contact, summary SyncSummary) ([]SyncEntry, error) {
+	entries := network.peerEntries()
+	return differingEntries(entries, NewSyncSummary(entries), summary, 0), nil
+}
+
+func (network *NetworkSyncMock) SendFindDataMessage(from *Contact, contact *Contact, key *Key) ([]Contact, *Value, error) {
+	network.findDatas++
+	value, err := network.peer.Get(key)
+	if err != nil {
+		return nil, nil, nil
+	}
+	return nil, &value, nil
+}
+
+func TestSyncSummaryListsDifferingRanges(t *testing.T) {
+	first := NewTextValue("first")
+	second := NewTextValue("second")
+	entries := []SyncEntry{
+		{Key: first.GetKey(), Digest: valueDigest(first), TTL: DefaultTTL},
+		{Key: second.GetKey(), Digest: valueDigest(second), TTL: DefaultTTL},
+	}
+	summary := NewSyncSummary(entries)
+
+	assert.Len(t, summary.Ranges, SyncRanges)
+	assert.Equal(t, summary, NewSyncSummary(entries))
+	assert.Empty(t, differingEntries(entries, summary, summary, 0))
+
+	// Only the range of the key the other node is missing differs
+	other := NewSyncSummary(entries[:1])
+	assert.NotEqual(t, summary.Root, other.Root)
+	differing := differingEntries(entries, summary, other, 0)
+	if syncRange(first.GetKey()) == syncRange(second.GetKey()) {
+		assert.Len(t, differing, 2)
+	} else if assert.Len(t, differing, 1) {
+		assert.True(t, differing[0].Key.Equals(second.GetKey()))
+	}
+}
+
+func TestDifferingEntriesRotateBeyondTheLimit(t *testing.T) {
+	entries := []SyncEntry{}
+	for i := 0; i < MaxSyncEntries+1; i++ {
+		value := NewTextValue("value" + strconv.Itoa(i))
+		entries = append(entries, SyncEntry{Key: value.GetKey(), Digest: valueDigest(value), TTL: DefaultTTL})
+	}
+	summary := NewSyncSummary(entries)
+
+	first := differingEntries(entries, summary, NewSyncSummary(nil), 0)
+	rotated := differingEntries(entries, summary, NewSyncSummary(nil), MaxSyncEntries)
+
+	assert.Len(t, first, MaxSyncEntries)
+	assert.Equal(t, entries[:MaxSyncEntries], first)
+	if assert.Len(t, rotated, MaxSyncEntries) {
+		assert.Equal(t, entries[MaxSyncEntries], rotated[0])
+		assert.Equal(t, entries[0], rotated[1])
+	}
+}
+
+func TestSynchronizePullsMissingValues(t *testing.T) {
+	shared := NewTextValue("shared")
+	missing := NewTextValue("missing")
+	network := &NetworkSyncMock{peer: NewInMemoryDataStore()}
+	network.peer.Insert(shared.GetKey(), shared, DefaultTTL)
+	network.peer.Insert(missing.GetKey(), missing, DefaultTTL/2)
+	kademlia := createQuorumTestKademlia(network)
+	kademlia.KademliaNode.GetDataStore().Insert(shared.GetKey(), shared, DefaultTTL)
+	contact := NewContact(GenerateNewKademliaID("0000000000000000000000000000000000000001"), "127.0.0.1", 1)
+
+	assert.Equal(t, 1, kademlia.synchronize(contact))
+	value, err := kademlia.KademliaNode.GetDataStore().Get(missing.GetKey())
+	assert.NoError(t, err)
+	assert.Equal(t, missing.Data, value.Data)
+	assert.Equal(t, 1, network.findDatas)
+
+	// The value gets what is left of its lifetime at the contact
+	expirationTime, _ := kademlia.KademliaNode.GetDataStore().GetTime(missing.GetKey())
+	assert.False(t, expirationTime.After(time.Now().Add(DefaultTTL/2)))
+
+	// Once the replicas agree nothing is pulled
+	assert.Equal(t, 0, kademlia.synchronize(contact))
+	assert.Equal(t, 1, network.findDatas)
+}
+
+func TestSynchronizeKeepsNewerVersion(t *testing.T) {
+	older, _ := KeyValue{Key: "name", Version: 1, Value: NewTextValue("older")}.ToValue()
+	newer, _ := KeyValue{Key: "name", Version: 2, Value: NewTextValue("newer")}.ToValue()
+	key := NewKeyValueKey("name")
+	network := &NetworkSyncMock{peer: NewInMemoryDataStore()}
+	network.peer.Insert(key, older, DefaultTTL)
+	kademlia := createQuorumTestKademlia(network)
+	kademlia.KademliaNode.GetDataStore().Insert(key, newer, DefaultTTL)
+
+	assert.Equal(t, 0, kademlia.synchronize(NewContact(GenerateNewKademliaID("0000000000000000000000000000000000000001"), "127.0.0.1", 1)))
+	value, _ := kademlia.KademliaNode.GetDataStore().Get(key)
+	assert.Equal(t, newer.Data, value.Data)
+}
+
+func TestSynchronizeSkipsDeletedValues(t *testing.T) {
+	deleted := NewTextValue("deleted")
+	network := &NetworkSyncMock{peer: NewInMemoryDataStore()}
+	network.peer.Insert(deleted.GetKey(), deleted, DefaultTTL)
+	kademlia := createQuorumTestKademlia(network)
+	kademlia.KademliaNode.(*KademliaNodeImplementation).tombstones.Add(deleted.GetKey(), nil, time.Now().Add(time.Minute))
+
+	assert.Equal(t, 0, kademlia.synchronize(NewContact(GenerateNewKademliaID("0000000000000000000000000000000000000001"), "127.0.0.1", 1)))
+	_, err := kademlia.KademliaNode.GetDataStore().Get(deleted.GetKey())
+	assert.Error(t, err)
+}
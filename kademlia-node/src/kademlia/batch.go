@@ -0,0 +1,157 @@
+package kademlia
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// BatchConcurrency is how many lookups StoreMany and LookupMany run at the same time.
+const BatchConcurrency = 8
+
+// BatchStoreResult is the result of storing one value of a batch. Key is nil if the value could not be hashed.
+type BatchStoreResult struct {
+	Key    *Key
+	Result *StoreResult
+	Err    error
+}
+
+// BatchLookupResult is the result of looking up one key of a batch. Value is nil if the key was not found.
+type BatchLookupResult struct {
+	Key   *Key
+	Value *Value
+	Err   error
+}
+
+// StoreMany stores every value like Store does, and returns a result for each value in the same order. Values whose
+// keys are close together are stored at the contacts found by a single lookup.
+func (kademlia *KademliaImplementation) StoreMany(values []Value, ttl time.Duration) []BatchStoreResult {
+	results := make([]BatchStoreResult, len(values))
+	keys := []*Key{}
+	indices := []int{}
+	for i, value := range values {
+		key, err := NewContentKey(value.Namespace, value.Data, kademlia.KademliaNode.GetHashFunction())
+		if err == nil {
+			err = kademlia.KademliaNode.GetValidators().Validate(key, value)
+		}
+		results[i] = BatchStoreResult{Key: key, Err: err}
+		if err == nil {
+			keys = append(keys, key)
+			indices = append(indices, i)
+		}
+	}
+
+	kademlia.forEachKeyGroup(keys, func(group []int, contacts []Contact, err error) {
+		for _, j := range group {
+			i := indices[j]
+			if err != nil {
+				results[i].Err = err
+				continue
+			}
+			results[i].Result, results[i].Err = kademlia.storeAtClosest(keys[j], values[i], ttl, contacts)
+		}
+	})
+	return results
+}
+
+// LookupMany looks up the value of every key like LookupData does, and returns a result for each key in the same
+// order. The values of keys that are close together are asked for at the contacts found by a single lookup, and only
+// the keys none of them holds are looked up on their own.
+func (kademlia *KademliaImplementation) LookupMany(keys []*Key) []BatchLookupResult {
+	results := make([]BatchLookupResult, len(keys))
+	kademlia.forEachKeyGroup(keys, func(group []int, contacts []Contact, err error) {
+		for _, i := range group {
+			results[i].Key = keys[i]
+			if err == nil {
+				results[i].Value = kademlia.findDataAtContacts(keys[i], contacts)
+			}
+			if results[i].Value == nil {
+				_, results[i].Value, results[i].Err = kademlia.LookupData(keys[i])
+			}
+		}
+	})
+	return results
+}
+
+// forEachKeyGroup splits the keys into groups of keys that are close together, and calls handle with the indices of
+// the keys of each group and the k closest contacts to the first key of the group. A key joins the group if it shares
+// more leading bits with the first key than the farthest of those contacts does, so that the contacts are the closest
+// known contacts to it as well. The keys are sorted and split among BatchConcurrency workers, which each walk through
+// their part of the keys one group at a time.
+func (kademlia *KademliaImplementation) forEachKeyGroup(keys []*Key, handle func(group []int, contacts []Contact, err error)) {
+	sorted := make([]int, len(keys))
+	for i := range sorted {
+		sorted[i] = i
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return keys[sorted[i]].GetKademliaIdRepresentationOfKey().Less(keys[sorted[j]].GetKademliaIdRepresentationOfKey())
+	})
+
+	partSize := (len(sorted) + BatchConcurrency - 1) / BatchConcurrency
+	var waitGroup sync.WaitGroup
+	for start := 0; start < len(sorted); start += partSize {
+		waitGroup.Add(1)
+		go func(part []int) {
+			defer waitGroup.Done()
+			for len(part) > 0 {
+				first := keys[part[0]].GetKademliaIdRepresentationOfKey()
+				contacts, err := kademlia.LookupContact(first)
+
+				// With fewer than k contacts every node is known, so all the keys are close enough
+				prefixLength := -1
+				if len(contacts) >= NumberOfClosestNodesToRetrieved {
+					prefixLength = IDLength * 8
+					for _, contact := range contacts {
+						prefixLength = min(prefixLength, first.CommonPrefixLength(contact.ID))
+					}
+				}
+
+				size := 1
+				for size < len(part) && err == nil && first.CommonPrefixLength(keys[part[size]].GetKademliaIdRepresentationOfKey()) > prefixLength {
+					size++
+				}
+				handle(part[:size], contacts, err)
+				part = part[size:]
+			}
+		}(sorted[start:min(start+partSize, len(sorted))])
+	}
+	waitGroup.Wait()
+}
+
+// findDataAtContacts asks the contacts for the value of the key, and returns the valid value the validator of its
+// namespace selects, or nil if none of them holds it.
+func (kademlia *KademliaImplementation) findDataAtContacts(key *Key, contacts []Contact) *Value {
+	validators := kademlia.KademliaNode.GetValidators()
+	me := kademlia.KademliaNode.GetRoutingTable().Me
+
+	values := make([]*Value, len(contacts))
+	var waitGroup sync.WaitGroup
+	for i, contact := range contacts {
+		waitGroup.Add(1)
+		go func(i int, contact Contact) {
+			defer waitGroup.Done()
+			_, value, err := kademlia.Network.SendFindDataMessage(&me, &contact, key)
+			if err != nil || value == nil {
+				return
+			}
+			if validators.Validate(key, *value) != nil {
+				kademlia.KademliaNode.reportMisbehaviour(contact, "answered a FIND_DATA with an invalid value")
+				return
+			}
+			values[i] = value
+		}(i, contact)
+	}
+	waitGroup.Wait()
+
+	found := []Value{}
+	for _, value := range values {
+		if value != nil && (len(found) == 0 || value.Namespace == found[0].Namespace) {
+			found = append(found, *value)
+		}
+	}
+	best, err := validators.Select(key, found)
+	if err != nil {
+		return nil
+	}
+	return &found[best]
+}
@@ -0,0 +1,89 @@
+package kademlia
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// NetworkLookupCountMock records the targets of the lookups that sent a FIND_NODE.
+type NetworkLookupCountMock struct {
+	NetworkKeyValueMock
+	targetsLock sync.Mutex
+	targets     map[KademliaID]bool
+}
+
+func (network *NetworkLookupCountMock) SendFindContactMessage(from *Contact, contact *Contact, id *KademliaID) ([]Contact, error) {
+	network.targetsLock.Lock()
+	defer network.targetsLock.Unlock()
+	if network.targets == nil {
+		network.targets = make(map[KademliaID]bool)
+	}
+	network.targets[*id] = true
+	return nil, nil
+}
+
+func createBatchTestValues(count int) []Value {
+	values := []Value{}
+	for i := 0; i < count; i++ {
+		values = append(values, NewTextValue("value "+strconv.Itoa(i)))
+	}
+	return values
+}
+
+func TestStoreManySharesLookups(t *testing.T) {
+	network := &NetworkLookupCountMock{}
+	kademlia := CreateMockedKademlia(GenerateNewKademliaID("0000000000000000000000000000000000000000"), "127.0.0.1", 0)
+	kademlia.Network = network
+	kademlia.KademliaNode.GetRoutingTable().AddContact(NewContact(GenerateNewKademliaID("0000000000000000000000000000000000000001"), "127.0.0.1", 1))
+	kademlia.KademliaNode.GetRoutingTable().AddContact(NewContact(GenerateNewKademliaID("0000000000000000000000000000000000000002"), "127.0.0.1", 2))
+
+	values := createBatchTestValues(100)
+	results := kademlia.StoreMany(values, DefaultTTL)
+
+	// Fewer than k nodes are found, so they are all the nodes there are and every key can share a lookup
+	network.targetsLock.Lock()
+	assert.LessOrEqual(t, len(network.targets), BatchConcurrency)
+	network.targetsLock.Unlock()
+	if assert.Len(t, results, len(values)) {
+		for i, result := range results {
+			assert.NoError(t, result.Err)
+			assert.True(t, result.Key.Equals(values[i].GetKey()))
+			assert.Len(t, result.Result.GetContacts(STORE_ACKNOWLEDGED), 2)
+			kademlia.Forget(result.Key)
+		}
+	}
+}
+
+func TestStoreManyReportsInvalidValues(t *testing.T) {
+	kademlia := createQuorumTestKademlia(&NetworkKeyValueMock{})
+	values := createBatchTestValues(2)
+	values[0].Namespace = "unknown"
+
+	results := kademlia.StoreMany(values, DefaultTTL)
+
+	assert.Error(t, results[0].Err)
+	assert.Nil(t, results[0].Result)
+	assert.NoError(t, results[1].Err)
+	kademlia.Forget(results[1].Key)
+}
+
+func TestLookupMany(t *testing.T) {
+	kademlia := createQuorumTestKademlia(&NetworkKeyValueMock{})
+	stored := NewTextValue("stored")
+	kademlia.Store(stored, DefaultTTL)
+
+	results := kademlia.LookupMany([]*Key{NewKey("missing"), stored.GetKey()})
+
+	if assert.Len(t, results, 2) {
+		assert.NoError(t, results[0].Err)
+		assert.Nil(t, results[0].Value)
+		assert.NoError(t, results[1].Err)
+		if assert.NotNil(t, results[1].Value) {
+			assert.Equal(t, stored.Data, results[1].Value.Data)
+		}
+	}
+	kademlia.Forget(stored.GetKey())
+}
@@ -60,7 +60,7 @@ func (bucket *bucket) Len() int {
 func (bucket *bucket) Contains(contact Contact) bool {
 	for elt := bucket.list.Front(); elt != nil; elt = elt.Next() {
 		foundContact := elt.Value.(Contact)
-		if foundContact.ID == contact.ID {
+		if foundContact.ID.Equals(contact.ID) {
 			return true
 		}
 	}
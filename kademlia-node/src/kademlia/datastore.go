@@ -1,6 +1,7 @@
 package kademlia
 
 import (
+	"container/heap"
 	"errors"
 	"sync"
 	"time"
@@ -8,146 +9,292 @@ import (
 	"github.com/arianfiftyone/src/logger"
 )
 
-// DataStore represents a key-value data store.
-type DataStore struct {
-	data       map[[KeySize]byte]string      // Map to store key-value pairs.
-	time       map[[KeySize]byte]time.Time   // Map to store key-time for expiration pairs. Unix time.
-	mutexLocks map[[KeySize]byte]*sync.Mutex // Map to store a mutex lock for each key.
-	ttl        time.Duration                 //seconds
-}
-
-// NewDataStore initializes a new DataStore instance.
-func NewDataStore() DataStore {
-	dataStore := DataStore{}
-	dataStore.data = make(map[[KeySize]byte]string)
-	dataStore.time = make(map[[KeySize]byte]time.Time)
-	dataStore.mutexLocks = make(map[[20]byte]*sync.Mutex)
-	dataStore.ttl = time.Second * 10
-	return dataStore
+// DataStore represents a key-value data store backend, where every value expires unless it is refreshed or pinned.
+type DataStore interface {
+	Insert(key *Key, value Value, ttl time.Duration) error
+	Get(key *Key) (Value, error)
+	Peek(key *Key) (Value, error)
+	GetTime(key *Key) (time.Time, error)
+	RefreshExpirationTime(key *Key, ttl time.Duration) error
+	Delete(key *Key) error
+	Pin(key *Key) error
+	Unpin(key *Key) error
+	Items() []DataStoreItem
+	Stats() DataStoreStats
+}
+
+// DataStoreStats summarizes the contents of a DataStore.
+type DataStoreStats struct {
+	Keys  int // The number of stored keys.
+	Bytes int // The total size of the data of the stored values.
 }
 
-// Insert inserts a key-value pair into the DataStore.
-func (dataStore DataStore) Insert(key *Key, value string) {
-	dataStore.mutexLocks[key.Hash] = &sync.Mutex{}
-	mutex := dataStore.mutexLocks[key.Hash]
-	mutex.Lock()
+// DataStoreItem is a snapshot of a key-value pair held in the DataStore.
+type DataStoreItem struct {
+	Key        *Key
+	Value      Value
+	StoreTime  time.Time     // The time the latest STORE was received for the key.
+	TTL        time.Duration // The time the value is kept after it was stored or last refreshed.
+	Expiration time.Time     // The time the value expires unless it is refreshed.
+	Pinned     bool          // Pinned values never expire and are never evicted.
+}
 
-	dataStore.time[key.Hash] = dataStore.calculateExpirationTime()
-	dataStore.data[key.Hash] = value
+// InMemoryDataStore is a DataStore that keeps its key-value pairs in a map. A single timer removes the values in
+// the order they expire, using a min-heap on the expiration time, so no goroutine is kept per value.
+type InMemoryDataStore struct {
+	lock        sync.Mutex
+	entries     map[[KeySize]byte]*inMemoryEntry // Map to store the value and expiration of each key.
+	expirations expirationHeap                   // The entries ordered by their expiration time.
+	timer       *time.Timer                      // Fires when the first entry in expirations expires.
+	bytes       int                              // The total size of the data of the stored values.
+}
 
-	mutex.Unlock()
-	go dataStore.deleteAfterExpirationTime(dataStore.ttl, key)
+// inMemoryEntry is a value in the InMemoryDataStore together with the state needed to expire it.
+type inMemoryEntry struct {
+	key            *Key
+	value          Value
+	expirationTime time.Time
+	storeTime      time.Time     // The time the latest STORE was received for the key.
+	ttl            time.Duration // The time to live requested for the key.
+	index          int           // The position of the entry in the expiration heap, unless the entry is pinned.
+	pinned         bool          // Pinned entries are not in the expiration heap.
 }
 
-func (dataStore DataStore) deleteAfterExpirationTime(timer time.Duration, key *Key) {
+// expirationHeap implements heap.Interface for the entries of an InMemoryDataStore, the entry that expires first is on top.
+type expirationHeap []*inMemoryEntry
 
-	select {
-	case currTime := <-time.After(timer):
-		mutex, ok := dataStore.mutexLocks[key.Hash]
-		if !ok {
-			return
-		}
-		mutex.Lock()
+func (expirations expirationHeap) Len() int { return len(expirations) }
 
-		_, ok = dataStore.time[key.Hash]
-		if !ok {
-			mutex.Unlock()
-			return
-		}
+func (expirations expirationHeap) Less(i, j int) bool {
+	return expirations[i].expirationTime.Before(expirations[j].expirationTime)
+}
+
+func (expirations expirationHeap) Swap(i, j int) {
+	expirations[i], expirations[j] = expirations[j], expirations[i]
+	expirations[i].index = i
+	expirations[j].index = j
+}
+
+func (expirations *expirationHeap) Push(x any) {
+	entry := x.(*inMemoryEntry)
+	entry.index = len(*expirations)
+	*expirations = append(*expirations, entry)
+}
+
+func (expirations *expirationHeap) Pop() any {
+	old := *expirations
+	entry := old[len(old)-1]
+	old[len(old)-1] = nil
+	*expirations = old[:len(old)-1]
+	return entry
+}
+
+// NewInMemoryDataStore initializes a new InMemoryDataStore instance.
+func NewInMemoryDataStore() *InMemoryDataStore {
+	dataStore := &InMemoryDataStore{}
+	dataStore.entries = make(map[[KeySize]byte]*inMemoryEntry)
+	return dataStore
+}
+
+// Insert inserts a key-value pair into the InMemoryDataStore, it is deleted once ttl has passed without a refresh.
+func (dataStore *InMemoryDataStore) Insert(key *Key, value Value, ttl time.Duration) error {
+	dataStore.lock.Lock()
+	defer dataStore.lock.Unlock()
+
+	if dataStore.entries == nil {
+		dataStore.entries = make(map[[KeySize]byte]*inMemoryEntry)
+	}
 
-		differenceInTime := dataStore.time[key.Hash].Sub(currTime)
-		mutex.Unlock()
-		if differenceInTime <= 0 {
-			dataStore.Delete(key)
-		} else {
-			dataStore.deleteAfterExpirationTime(differenceInTime, key)
+	entry, ok := dataStore.entries[key.Hash]
+	if !ok {
+		entry = &inMemoryEntry{key: &Key{Hash: key.Hash, Multihash: key.Multihash}}
+		dataStore.entries[key.Hash] = entry
+		heap.Push(&dataStore.expirations, entry)
+	}
+	dataStore.bytes += len(value.Data) - len(entry.value.Data)
+	entry.value = value
+	entry.expirationTime = dataStore.calculateExpirationTime(ttl)
+	entry.storeTime = time.Now()
+	entry.ttl = ttl
+	if !entry.pinned {
+		heap.Fix(&dataStore.expirations, entry.index)
+		dataStore.scheduleExpiration()
+	}
+	return nil
+}
+
+// scheduleExpiration makes the timer fire when the first entry expires. The lock must be held by the caller.
+func (dataStore *InMemoryDataStore) scheduleExpiration() {
+	if len(dataStore.expirations) <= 0 {
+		if dataStore.timer != nil {
+			dataStore.timer.Stop()
 		}
+		return
+	}
 
+	wait := time.Until(dataStore.expirations[0].expirationTime)
+	if dataStore.timer == nil {
+		dataStore.timer = time.AfterFunc(wait, dataStore.deleteExpired)
+	} else {
+		dataStore.timer.Reset(wait)
 	}
 }
 
-// Get retrieves the value associated with a key from the DataStore.
-func (dataStore DataStore) Get(key *Key) (string, error) {
-	mutex, ok := dataStore.mutexLocks[key.Hash]
-	if !ok {
-		return "", errors.New("key not found")
+// deleteExpired removes every entry whose expiration time has passed and schedules the next expiration.
+func (dataStore *InMemoryDataStore) deleteExpired() {
+	dataStore.lock.Lock()
+	defer dataStore.lock.Unlock()
+
+	now := time.Now()
+	for len(dataStore.expirations) > 0 && !dataStore.expirations[0].expirationTime.After(now) {
+		entry := heap.Pop(&dataStore.expirations).(*inMemoryEntry)
+		delete(dataStore.entries, entry.key.Hash)
+		dataStore.bytes -= len(entry.value.Data)
+		logger.Log("The data object " + entry.key.GetHashString() + " has been deleted due to the expired TTL.")
 	}
-	mutex.Lock()
+	dataStore.scheduleExpiration()
+}
 
-	value, ok := dataStore.data[key.Hash]
+// Get retrieves the value associated with a key from the DataStore, and keeps it for another time to live.
+func (dataStore *InMemoryDataStore) Get(key *Key) (Value, error) {
+	dataStore.lock.Lock()
+	defer dataStore.lock.Unlock()
+
+	entry, ok := dataStore.entries[key.Hash]
 	if !ok {
-		mutex.Unlock()
-		return "", errors.New("key not found")
-	}
-	mutex.Unlock()
-	err := dataStore.RefreshExpirationTime(key)
-	if err != nil {
-		return "", errors.New("Refresh failed")
+		return Value{}, errors.New("key not found")
 	}
+	dataStore.refresh(entry, entry.ttl)
 
-	return value, nil
+	return entry.value, nil
 }
 
-func (dataStore DataStore) GetTime(key *Key) (time.Time, error) {
-	mutex, ok := dataStore.mutexLocks[key.Hash]
+// Peek retrieves the value associated with a key from the DataStore without touching its expiration time.
+func (dataStore *InMemoryDataStore) Peek(key *Key) (Value, error) {
+	dataStore.lock.Lock()
+	defer dataStore.lock.Unlock()
+
+	entry, ok := dataStore.entries[key.Hash]
 	if !ok {
-		return time.Now(), errors.New("key not found")
+		return Value{}, errors.New("key not found")
 	}
-	mutex.Lock()
+	return entry.value, nil
+}
+
+func (dataStore *InMemoryDataStore) GetTime(key *Key) (time.Time, error) {
+	dataStore.lock.Lock()
+	defer dataStore.lock.Unlock()
 
-	time, ok := dataStore.time[key.Hash]
+	entry, ok := dataStore.entries[key.Hash]
 	if !ok {
-		mutex.Unlock()
-		return time, errors.New("key not found")
+		return time.Now(), errors.New("key not found")
 	}
-	mutex.Unlock()
-	return time, nil
+	return entry.expirationTime, nil
 }
 
-func (dataStore DataStore) calculateExpirationTime() time.Time {
-	return time.Now().Add(dataStore.ttl)
+// Items returns a snapshot of every key-value pair in the DataStore, without refreshing their expiration times.
+func (dataStore *InMemoryDataStore) Items() []DataStoreItem {
+	dataStore.lock.Lock()
+	defer dataStore.lock.Unlock()
+
+	items := []DataStoreItem{}
+	for _, entry := range dataStore.entries {
+		items = append(items, DataStoreItem{
+			Key:        &Key{Hash: entry.key.Hash, Multihash: entry.key.Multihash},
+			Value:      entry.value,
+			StoreTime:  entry.storeTime,
+			TTL:        entry.ttl,
+			Expiration: entry.expirationTime,
+			Pinned:     entry.pinned,
+		})
+	}
+	return items
+}
+
+// Stats returns the number of keys and the total size of the values in the DataStore.
+func (dataStore *InMemoryDataStore) Stats() DataStoreStats {
+	dataStore.lock.Lock()
+	defer dataStore.lock.Unlock()
+
+	return DataStoreStats{Keys: len(dataStore.entries), Bytes: dataStore.bytes}
 }
 
-func (dataStore DataStore) RefreshExpirationTime(key *Key) error {
-	mutex, ok := dataStore.mutexLocks[key.Hash]
+func (dataStore *InMemoryDataStore) calculateExpirationTime(ttl time.Duration) time.Time {
+	return time.Now().Add(ttl)
+}
+
+// RefreshExpirationTime keeps the value for another ttl, which replaces the time to live it was inserted with.
+func (dataStore *InMemoryDataStore) RefreshExpirationTime(key *Key, ttl time.Duration) error {
+	dataStore.lock.Lock()
+	defer dataStore.lock.Unlock()
+
+	entry, ok := dataStore.entries[key.Hash]
 	if !ok {
 		return errors.New("key not found")
 	}
-	mutex.Lock()
+	dataStore.refresh(entry, ttl)
+	return nil
+}
+
+// refresh moves the expiration time of the entry ttl into the future. The lock must be held by the caller.
+func (dataStore *InMemoryDataStore) refresh(entry *inMemoryEntry, ttl time.Duration) {
+	entry.expirationTime = dataStore.calculateExpirationTime(ttl)
+	entry.ttl = ttl
+	if !entry.pinned {
+		heap.Fix(&dataStore.expirations, entry.index)
+		dataStore.scheduleExpiration()
+	}
+}
+
+func (dataStore *InMemoryDataStore) Delete(key *Key) error {
+	dataStore.lock.Lock()
+	defer dataStore.lock.Unlock()
 
-	_, ok = dataStore.data[key.Hash]
+	entry, ok := dataStore.entries[key.Hash]
 	if !ok {
-		mutex.Unlock()
 		return errors.New("key not found")
 	}
-	ttl := dataStore.calculateExpirationTime()
-	dataStore.time[key.Hash] = ttl
-	mutex.Unlock()
+	if !entry.pinned {
+		heap.Remove(&dataStore.expirations, entry.index)
+	}
+	delete(dataStore.entries, key.Hash)
+	dataStore.bytes -= len(entry.value.Data)
+	dataStore.scheduleExpiration()
+	logger.Log("The data object " + key.GetHashString() + " has been deleted.")
 	return nil
 }
 
-func (dataStore DataStore) Delete(key *Key) error {
-	mutex, ok := dataStore.mutexLocks[key.Hash]
+// Pin keeps the value of the key until it is unpinned or deleted, it no longer expires.
+func (dataStore *InMemoryDataStore) Pin(key *Key) error {
+	dataStore.lock.Lock()
+	defer dataStore.lock.Unlock()
+
+	entry, ok := dataStore.entries[key.Hash]
 	if !ok {
 		return errors.New("key not found")
 	}
-	mutex.Lock()
+	if !entry.pinned {
+		heap.Remove(&dataStore.expirations, entry.index)
+		entry.pinned = true
+		dataStore.scheduleExpiration()
+	}
+	return nil
+}
+
+// Unpin lets the value of the key expire again, once its time to live has passed from now without a refresh.
+func (dataStore *InMemoryDataStore) Unpin(key *Key) error {
+	dataStore.lock.Lock()
+	defer dataStore.lock.Unlock()
 
-	value, ok := dataStore.data[key.Hash]
+	entry, ok := dataStore.entries[key.Hash]
 	if !ok {
-		mutex.Unlock()
 		return errors.New("key not found")
 	}
-	_, ok = dataStore.time[key.Hash]
-	if !ok {
-		mutex.Unlock()
-		return errors.New("key not found")
+	if entry.pinned {
+		entry.pinned = false
+		entry.expirationTime = dataStore.calculateExpirationTime(entry.ttl)
+		heap.Push(&dataStore.expirations, entry)
+		dataStore.scheduleExpiration()
 	}
-
-	delete(dataStore.time, key.Hash)
-	delete(dataStore.data, key.Hash)
-	delete(dataStore.mutexLocks, key.Hash)
-	mutex.Unlock()
-	logger.Log("The data object " + key.GetHashString() + " with the value " + value + " has been deleted due to the expired TTL.")
 	return nil
 }
@@ -3,49 +3,50 @@ package kademlia
 import (
 	"fmt"
 	"reflect"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
-// TestNewDataStore tests the NewDataStore function.
-func TestNewDataStore(t *testing.T) {
-	dataStore := NewDataStore()
+// TestNewInMemoryDataStore tests the NewInMemoryDataStore function.
+func TestNewInMemoryDataStore(t *testing.T) {
+	dataStore := NewInMemoryDataStore()
 
-	if dataStore.data == nil {
-		t.Error("NewDataStore: Data map should be initialized.")
+	if dataStore.entries == nil {
+		t.Error("NewInMemoryDataStore: Entries map should be initialized.")
 	}
 }
 
 // TestInsert tests the Insert method.
 func TestInsert(t *testing.T) {
-	dataStore := NewDataStore()
+	dataStore := NewInMemoryDataStore()
 
 	value := string("testValue")
 	key := NewKey(value)
 
-	dataStore.Insert(key, value)
+	dataStore.Insert(key, NewTextValue(value), DefaultTTL)
 
-	if !reflect.DeepEqual(dataStore.data[key.Hash], value) {
-		t.Errorf("Insert: Expected %v, got %v", value, dataStore.data[key.Hash])
+	if !reflect.DeepEqual(string(dataStore.entries[key.Hash].value.Data), value) {
+		t.Errorf("Insert: Expected %v, got %v", value, string(dataStore.entries[key.Hash].value.Data))
 	}
 }
 
 func TestInsertAndGet(t *testing.T) {
-	dataStore := NewDataStore()
+	dataStore := NewInMemoryDataStore()
 
 	value := "testValue"
 	key := NewKey(value)
 
-	dataStore.Insert(key, value)
+	dataStore.Insert(key, NewTextValue(value), DefaultTTL)
 
 	retrievedValue, err := dataStore.Get(key)
 	if err != nil {
 		t.Errorf("Get: Unexpected error: %v", err)
 	}
 
-	if retrievedValue != value {
+	if string(retrievedValue.Data) != value {
 		t.Errorf("Get: Expected %v, got %v", value, retrievedValue)
 	}
 
@@ -58,13 +59,33 @@ func TestInsertAndGet(t *testing.T) {
 	}
 }
 
+func TestPeekDoesNotRefresh(t *testing.T) {
+	dataStore := NewInMemoryDataStore()
+
+	value := "testValue"
+	key := NewKey(value)
+	dataStore.Insert(key, NewTextValue(value), DefaultTTL)
+	insertedTime, _ := dataStore.GetTime(key)
+
+	time.Sleep(time.Millisecond * 10)
+	retrievedValue, err := dataStore.Peek(key)
+
+	assert.NoError(t, err)
+	assert.Equal(t, value, string(retrievedValue.Data))
+	peekedTime, _ := dataStore.GetTime(key)
+	assert.Equal(t, insertedTime, peekedTime)
+
+	_, err = dataStore.Peek(NewKey("testValue2"))
+	assert.Error(t, err)
+}
+
 func TestInsertAndGetTime(t *testing.T) {
-	dataStore := NewDataStore()
+	dataStore := NewInMemoryDataStore()
 
 	// Insert a key-value pair
 	value := "testValue"
 	key := NewKey(value)
-	dataStore.Insert(key, value)
+	dataStore.Insert(key, NewTextValue(value), DefaultTTL)
 
 	// Retrieve the time associated with the key
 	insertedTime, err := dataStore.GetTime(key)
@@ -73,7 +94,7 @@ func TestInsertAndGetTime(t *testing.T) {
 	}
 
 	// Calculate the expected expiration time
-	expectedTime := time.Now().Add(dataStore.ttl)
+	expectedTime := time.Now().Add(DefaultTTL)
 
 	fmt.Println(insertedTime)
 	fmt.Println(expectedTime)
@@ -85,12 +106,12 @@ func TestInsertAndGetTime(t *testing.T) {
 }
 
 func TestRefreshExpirationTime(t *testing.T) {
-	dataStore := NewDataStore()
+	dataStore := NewInMemoryDataStore()
 
 	// Insert a key-value pair
 	value := "testValue"
 	key := NewKey(value)
-	dataStore.Insert(key, value)
+	dataStore.Insert(key, NewTextValue(value), DefaultTTL)
 
 	// Retrieve the time associated with the key
 	insertedTime, err := dataStore.GetTime(key)
@@ -102,7 +123,7 @@ func TestRefreshExpirationTime(t *testing.T) {
 	delay := time.Second * 2
 	time.Sleep(delay + time.Millisecond*100)
 
-	dataStore.RefreshExpirationTime(key)
+	dataStore.RefreshExpirationTime(key, DefaultTTL)
 
 	newTime, err := dataStore.GetTime(key)
 	if err != nil {
@@ -116,75 +137,166 @@ func TestRefreshExpirationTime(t *testing.T) {
 }
 
 func TestDeleteExpiredData(t *testing.T) {
-	dataStore := NewDataStore()
+	dataStore := NewInMemoryDataStore()
 
-	//dataStore.ttl = time.Second * 1
+	//ttl := time.Second * 1
 
 	// Insert a key-value pair
 	value := "testValue"
 	key := NewKey(value)
 
-	dataStore.data[key.Hash] = value
-	dataStore.time[key.Hash] = dataStore.calculateExpirationTime()
+	dataStore.Insert(key, NewTextValue(value), DefaultTTL)
 
 	dataStore.Delete(key)
 
-	//expectedMap := map[[KeySize]byte]string{}
-
-	//assert.Equal(t, expectedMap, dataStore.data)
+	assert.Empty(t, dataStore.entries)
+	assert.Empty(t, dataStore.expirations)
 }
 
 func TestDeleteExpiredDataInsert(t *testing.T) {
-	dataStore := NewDataStore()
+	dataStore := NewInMemoryDataStore()
 
-	dataStore.ttl = time.Second * 1
+	ttl := time.Second * 1
 
-	fmt.Println(dataStore.ttl)
+	fmt.Println(ttl)
 
 	// Insert a key-value pair
 	value := "testValue"
 	key := NewKey(value)
-	dataStore.Insert(key, value)
+	dataStore.Insert(key, NewTextValue(value), ttl)
 
 	value = "testValue2"
 	key = NewKey(value)
-	dataStore.Insert(key, value)
+	dataStore.Insert(key, NewTextValue(value), ttl)
 
 	value = "testValue3"
 	key = NewKey(value)
-	dataStore.Insert(key, value)
+	dataStore.Insert(key, NewTextValue(value), ttl)
 
 	time.Sleep(time.Second * 5)
 
-	expectedMap := map[[KeySize]byte]string{}
-
-	assert.Equal(t, expectedMap, dataStore.data)
+	assert.Empty(t, dataStore.Items())
 }
 
 func TestDeleteExpiredDataInsert2(t *testing.T) {
-	dataStore := NewDataStore()
+	dataStore := NewInMemoryDataStore()
 
-	dataStore.ttl = time.Second * 1
+	ttl := time.Second * 1
 
 	// Insert a key-value pair
 	value := "testValue"
 	key := NewKey(value)
-	dataStore.Insert(key, value)
+	dataStore.Insert(key, NewTextValue(value), ttl)
 
 	value = "testValue2"
 	key = NewKey(value)
-	dataStore.Insert(key, value)
+	dataStore.Insert(key, NewTextValue(value), ttl)
 
-	dataStore.ttl = time.Second * 10
-	fmt.Println(dataStore.ttl)
+	ttl = time.Second * 10
+	fmt.Println(ttl)
 
 	value = "testValue3"
 	key = NewKey(value)
-	dataStore.Insert(key, value)
+	dataStore.Insert(key, NewTextValue(value), ttl)
 
 	time.Sleep(time.Second * 5)
 
-	expectedMap := map[[KeySize]byte]string{key.Hash: value}
+	items := dataStore.Items()
+	assert.Len(t, items, 1)
+	assert.Equal(t, key.Hash, items[0].Key.Hash)
+	assert.Equal(t, value, string(items[0].Value.Data))
+}
+
+func TestItems(t *testing.T) {
+	dataStore := NewInMemoryDataStore()
+
+	value := "testValue"
+	key := NewKey(value)
+	dataStore.Insert(key, NewTextValue(value), DefaultTTL)
+
+	items := dataStore.Items()
+
+	assert.Len(t, items, 1)
+	assert.Equal(t, key.Hash, items[0].Key.Hash)
+	assert.Equal(t, value, string(items[0].Value.Data))
+	assert.Equal(t, dataStore.entries[key.Hash].storeTime, items[0].StoreTime)
+	assert.Equal(t, dataStore.entries[key.Hash].expirationTime, items[0].Expiration)
+}
+
+func TestStats(t *testing.T) {
+	dataStore := NewInMemoryDataStore()
+
+	dataStore.Insert(NewKey("testValue"), NewTextValue("testValue"), DefaultTTL)
+	dataStore.Insert(NewKey("testValue2"), NewTextValue("testValue2"), DefaultTTL)
+
+	assert.Equal(t, DataStoreStats{Keys: 2, Bytes: len("testValue") + len("testValue2")}, dataStore.Stats())
+}
+
+func TestExpiredDataIsDeletedInOrder(t *testing.T) {
+	dataStore := NewInMemoryDataStore()
+
+	dataStore.Insert(NewKey("testValue"), NewTextValue("testValue"), time.Millisecond*300)
+	dataStore.Insert(NewKey("testValue2"), NewTextValue("testValue2"), time.Millisecond*100)
+	dataStore.Insert(NewKey("testValue3"), NewTextValue("testValue3"), DefaultTTL)
+
+	time.Sleep(time.Millisecond * 200)
+	assert.Equal(t, 2, dataStore.Stats().Keys)
+	_, err := dataStore.Get(NewKey("testValue2"))
+	assert.Error(t, err)
+
+	time.Sleep(time.Millisecond * 200)
+	assert.Equal(t, 1, dataStore.Stats().Keys)
+	_, err = dataStore.Get(NewKey("testValue3"))
+	assert.NoError(t, err)
+}
+
+// TestConcurrentAccess is meant to be run with -race, it inserts, reads, refreshes and deletes keys from many goroutines.
+func TestConcurrentAccess(t *testing.T) {
+	dataStore := NewInMemoryDataStore()
+
+	var waitGroup sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		waitGroup.Add(1)
+		go func(i int) {
+			defer waitGroup.Done()
+			value := fmt.Sprint("testValue", i%5)
+			key := NewKey(value)
+			dataStore.Insert(key, NewTextValue(value), time.Millisecond*time.Duration(10+i))
+			dataStore.Get(key)
+			dataStore.GetTime(key)
+			dataStore.RefreshExpirationTime(key, time.Millisecond*time.Duration(10+i))
+			dataStore.Items()
+			dataStore.Stats()
+			if i%3 == 0 {
+				dataStore.Delete(key)
+			}
+		}(i)
+	}
+	waitGroup.Wait()
+
+	time.Sleep(time.Millisecond * 100)
+	assert.Empty(t, dataStore.Items())
+}
+
+func TestPinnedDataDoesNotExpire(t *testing.T) {
+	dataStore := NewInMemoryDataStore()
+
+	value := "testValue"
+	key := NewKey(value)
+	dataStore.Insert(key, NewTextValue(value), time.Millisecond*100)
+	assert.NoError(t, dataStore.Pin(key))
+	assert.Error(t, dataStore.Pin(NewKey("testValue2")))
+
+	time.Sleep(time.Millisecond * 200)
+
+	_, err := dataStore.Get(key)
+	assert.NoError(t, err)
+	assert.True(t, dataStore.Items()[0].Pinned)
+
+	assert.NoError(t, dataStore.Unpin(key))
+	time.Sleep(time.Millisecond * 200)
 
-	assert.Equal(t, expectedMap, dataStore.data)
+	_, err = dataStore.Get(key)
+	assert.Error(t, err)
+	assert.Empty(t, dataStore.expirations)
 }
@@ -0,0 +1,716 @@
+package kademlia
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/arianfiftyone/src/logger"
+)
+
+const (
+	DiskSegmentMaxSize     = 4 * 1024 * 1024 // Size in bytes at which a new segment is started
+	DiskCompactionInterval = time.Minute     // How often the background compaction checks if it should run
+
+	diskCompactionMinDeadBytes = 1024 * 1024 // Compaction only runs when at least this many bytes are dead
+	diskExpirationInterval     = time.Second
+	diskRecordHeaderSize       = 8
+	diskSegmentPrefix          = "segment-"
+	diskSegmentSuffix          = ".log"
+	diskTemporarySuffix        = ".tmp"
+)
+
+type diskOperation string
+
+const (
+	DISK_PUT     diskOperation = "PUT"
+	DISK_REFRESH diskOperation = "REFRESH"
+	DISK_DELETE  diskOperation = "DELETE"
+	DISK_PIN     diskOperation = "PIN"
+	DISK_UNPIN   diskOperation = "UNPIN"
+)
+
+// diskRecord is one entry of the append-only log. It is stored as a header holding the length and CRC-32 of the
+// JSON encoded record, followed by the record itself.
+type diskRecord struct {
+	Operation      diskOperation `json:"operation"`
+	Key            string        `json:"key"`
+	Value          *Value        `json:"value,omitempty"`
+	TTL            time.Duration `json:"ttl,omitempty"`
+	ExpirationTime int64         `json:"expirationTime,omitempty"` // Unix time in nanoseconds
+	StoreTime      int64         `json:"storeTime,omitempty"`      // Unix time in nanoseconds
+	Pinned         bool          `json:"pinned,omitempty"`
+}
+
+// diskIndexEntry points to the PUT record holding the current value of a key.
+type diskIndexEntry struct {
+	key            *Key
+	segment        int
+	offset         int64
+	size           int64
+	valueSize      int
+	ttl            time.Duration
+	expirationTime time.Time
+	storeTime      time.Time
+	pinned         bool
+}
+
+// DiskDataStore is a DataStore that keeps its key-value pairs in an append-only log of segment files, so that
+// they survive a restart of the node. An in-memory index maps every key to the record holding its value.
+type DiskDataStore struct {
+	lock          sync.Mutex
+	directory     string
+	index         map[[KeySize]byte]*diskIndexEntry
+	segments      map[int]*os.File
+	activeSegment int
+	activeSize    int64
+	deadBytes     int64 // Bytes in the segments that no longer hold a live value
+	stop          chan bool
+}
+
+// NewDiskDataStore opens the log in the given directory, or creates it if it does not exist. Records left
+// incomplete by a crash are discarded and values that expired while the node was down are purged.
+func NewDiskDataStore(directory string) (*DiskDataStore, error) {
+	err := os.MkdirAll(directory, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	dataStore := &DiskDataStore{
+		directory: directory,
+		index:     make(map[[KeySize]byte]*diskIndexEntry),
+		segments:  make(map[int]*os.File),
+		stop:      make(chan bool),
+	}
+
+	segmentIds, err := dataStore.findSegments()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, segmentId := range segmentIds {
+		err = dataStore.loadSegment(segmentId)
+		if err != nil {
+			dataStore.closeSegments()
+			return nil, err
+		}
+	}
+	dataStore.purgeExpired()
+
+	nextSegment := 1
+	if len(segmentIds) > 0 {
+		nextSegment = segmentIds[len(segmentIds)-1] + 1
+	}
+	err = dataStore.openActiveSegment(nextSegment)
+	if err != nil {
+		dataStore.closeSegments()
+		return nil, err
+	}
+
+	go dataStore.maintain()
+	return dataStore, nil
+}
+
+func (dataStore *DiskDataStore) segmentPath(segmentId int) string {
+	return filepath.Join(dataStore.directory, fmt.Sprintf("%s%06d%s", diskSegmentPrefix, segmentId, diskSegmentSuffix))
+}
+
+// findSegments returns the ids of the segments in the directory in ascending order, and removes temporary
+// segments left behind by a compaction that did not finish.
+func (dataStore *DiskDataStore) findSegments() ([]int, error) {
+	entries, err := os.ReadDir(dataStore.directory)
+	if err != nil {
+		return nil, err
+	}
+
+	segmentIds := []int{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasSuffix(name, diskTemporarySuffix) {
+			os.Remove(filepath.Join(dataStore.directory, name))
+			continue
+		}
+		if !strings.HasPrefix(name, diskSegmentPrefix) || !strings.HasSuffix(name, diskSegmentSuffix) {
+			continue
+		}
+		segmentId, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(name, diskSegmentPrefix), diskSegmentSuffix))
+		if err != nil {
+			continue
+		}
+		segmentIds = append(segmentIds, segmentId)
+	}
+	sort.Ints(segmentIds)
+	return segmentIds, nil
+}
+
+// loadSegment replays every record of a segment into the index. The segment is truncated at the first
+// record that is incomplete or does not match its checksum.
+func (dataStore *DiskDataStore) loadSegment(segmentId int) error {
+	file, err := os.OpenFile(dataStore.segmentPath(segmentId), os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	dataStore.segments[segmentId] = file
+
+	reader := bufio.NewReader(file)
+	var offset int64
+	for {
+		record, size, err := readDiskRecord(reader)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			logger.Log("Discarding the end of segment " + strconv.Itoa(segmentId) + ": " + err.Error())
+			return file.Truncate(offset)
+		}
+
+		dataStore.apply(record, segmentId, offset, size)
+		offset += size
+	}
+}
+
+// apply updates the index with a record that has been written at the given position.
+func (dataStore *DiskDataStore) apply(record diskRecord, segmentId int, offset int64, size int64) {
+	key, err := ParseKey(record.Key)
+	if err != nil || (record.Operation == DISK_PUT && record.Value == nil) {
+		dataStore.deadBytes += size
+		return
+	}
+	hash := key.Hash
+	entry, ok := dataStore.index[hash]
+
+	switch record.Operation {
+	case DISK_PUT:
+		if ok {
+			dataStore.deadBytes += entry.size
+		}
+		dataStore.index[hash] = &diskIndexEntry{
+			key:            key,
+			segment:        segmentId,
+			offset:         offset,
+			size:           size,
+			valueSize:      len(record.Value.Data),
+			ttl:            record.TTL,
+			expirationTime: time.Unix(0, record.ExpirationTime),
+			storeTime:      time.Unix(0, record.StoreTime),
+			pinned:         record.Pinned,
+		}
+
+	case DISK_PIN:
+		dataStore.deadBytes += size
+		if ok {
+			entry.pinned = true
+		}
+
+	case DISK_UNPIN:
+		dataStore.deadBytes += size
+		if ok {
+			entry.pinned = false
+			entry.expirationTime = time.Unix(0, record.ExpirationTime)
+		}
+
+	case DISK_REFRESH:
+		dataStore.deadBytes += size
+		if ok {
+			entry.ttl = record.TTL
+			entry.expirationTime = time.Unix(0, record.ExpirationTime)
+		}
+
+	case DISK_DELETE:
+		dataStore.deadBytes += size
+		if ok {
+			dataStore.deadBytes += entry.size
+			delete(dataStore.index, hash)
+		}
+
+	default:
+		dataStore.deadBytes += size
+	}
+}
+
+func (dataStore *DiskDataStore) openActiveSegment(segmentId int) error {
+	file, err := os.OpenFile(dataStore.segmentPath(segmentId), os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	dataStore.segments[segmentId] = file
+	dataStore.activeSegment = segmentId
+	dataStore.activeSize = info.Size()
+	return nil
+}
+
+func (dataStore *DiskDataStore) closeSegments() {
+	for segmentId, file := range dataStore.segments {
+		file.Close()
+		delete(dataStore.segments, segmentId)
+	}
+}
+
+func encodeDiskRecord(record diskRecord) ([]byte, error) {
+	payload, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+
+	bytes := make([]byte, diskRecordHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(bytes[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(bytes[4:8], crc32.ChecksumIEEE(payload))
+	copy(bytes[diskRecordHeaderSize:], payload)
+	return bytes, nil
+}
+
+// readDiskRecord reads the next record and returns it together with its size on disk. io.EOF is only
+// returned if the reader ends exactly between two records.
+func readDiskRecord(reader io.Reader) (diskRecord, int64, error) {
+	var record diskRecord
+
+	header := make([]byte, diskRecordHeaderSize)
+	_, err := io.ReadFull(reader, header)
+	if err != nil {
+		return record, 0, err
+	}
+
+	length := binary.BigEndian.Uint32(header[0:4])
+	if length > DiskSegmentMaxSize {
+		return record, 0, errors.New("record too large")
+	}
+	payload := make([]byte, length)
+	_, err = io.ReadFull(reader, payload)
+	if err != nil {
+		return record, 0, io.ErrUnexpectedEOF
+	}
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:8]) {
+		return record, 0, errors.New("checksum mismatch")
+	}
+
+	err = json.Unmarshal(payload, &record)
+	if err != nil {
+		return record, 0, err
+	}
+	return record, int64(len(header) + len(payload)), nil
+}
+
+// write appends a record to the active segment, starting a new segment if the active one is full,
+// and returns the position it was written at.
+func (dataStore *DiskDataStore) write(record diskRecord, shouldSync bool) (int, int64, int64, error) {
+	bytes, err := encodeDiskRecord(record)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	if len(bytes) > DiskSegmentMaxSize {
+		return 0, 0, 0, errors.New("record too large")
+	}
+
+	if dataStore.activeSize > 0 && dataStore.activeSize+int64(len(bytes)) > DiskSegmentMaxSize {
+		err = dataStore.segments[dataStore.activeSegment].Sync()
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		err = dataStore.openActiveSegment(dataStore.activeSegment + 1)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+	}
+
+	file := dataStore.segments[dataStore.activeSegment]
+	offset := dataStore.activeSize
+	written, err := file.Write(bytes)
+	dataStore.activeSize += int64(written)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	if shouldSync {
+		err = file.Sync()
+		if err != nil {
+			return 0, 0, 0, err
+		}
+	}
+
+	return dataStore.activeSegment, offset, int64(len(bytes)), nil
+}
+
+// readValue reads the value of an index entry from its segment.
+func (dataStore *DiskDataStore) readValue(entry *diskIndexEntry) (Value, error) {
+	file, ok := dataStore.segments[entry.segment]
+	if !ok {
+		return Value{}, errors.New("segment not found")
+	}
+
+	record, _, err := readDiskRecord(io.NewSectionReader(file, entry.offset, entry.size))
+	if err != nil {
+		return Value{}, err
+	}
+	if record.Value == nil {
+		return Value{}, errors.New("record holds no value")
+	}
+	return *record.Value, nil
+}
+
+// getEntry returns the index entry of a key, unless it does not exist or has expired.
+func (dataStore *DiskDataStore) getEntry(key *Key) (*diskIndexEntry, error) {
+	entry, ok := dataStore.index[key.Hash]
+	if !ok || (!entry.pinned && !entry.expirationTime.After(time.Now())) {
+		return nil, errors.New("key not found")
+	}
+	return entry, nil
+}
+
+// Insert inserts a key-value pair into the DiskDataStore and waits until it has been written to disk.
+// The value expires once ttl has passed without a refresh. Nothing is inserted if the write fails.
+func (dataStore *DiskDataStore) Insert(key *Key, value Value, ttl time.Duration) error {
+	dataStore.lock.Lock()
+	defer dataStore.lock.Unlock()
+
+	record := diskRecord{
+		Operation:      DISK_PUT,
+		Key:            key.GetHashString(),
+		Value:          &value,
+		TTL:            ttl,
+		ExpirationTime: time.Now().Add(ttl).UnixNano(),
+		StoreTime:      time.Now().UnixNano(),
+	}
+	if entry, err := dataStore.getEntry(key); err == nil {
+		record.Pinned = entry.pinned
+	}
+	segmentId, offset, size, err := dataStore.write(record, true)
+	if err != nil {
+		logger.Log("Failed to write the data object " + key.GetHashString() + " to disk: " + err.Error())
+		return err
+	}
+	dataStore.apply(record, segmentId, offset, size)
+	return nil
+}
+
+// Get retrieves the value associated with a key from the DiskDataStore and refreshes its expiration time.
+func (dataStore *DiskDataStore) Get(key *Key) (Value, error) {
+	dataStore.lock.Lock()
+	defer dataStore.lock.Unlock()
+
+	entry, err := dataStore.getEntry(key)
+	if err != nil {
+		return Value{}, err
+	}
+	value, err := dataStore.readValue(entry)
+	if err != nil {
+		return Value{}, err
+	}
+
+	err = dataStore.refreshExpirationTime(key, entry.ttl)
+	if err != nil {
+		return Value{}, errors.New("Refresh failed")
+	}
+	return value, nil
+}
+
+// Peek retrieves the value associated with a key from the DiskDataStore without touching its expiration time.
+func (dataStore *DiskDataStore) Peek(key *Key) (Value, error) {
+	dataStore.lock.Lock()
+	defer dataStore.lock.Unlock()
+
+	entry, err := dataStore.getEntry(key)
+	if err != nil {
+		return Value{}, err
+	}
+	return dataStore.readValue(entry)
+}
+
+func (dataStore *DiskDataStore) GetTime(key *Key) (time.Time, error) {
+	dataStore.lock.Lock()
+	defer dataStore.lock.Unlock()
+
+	entry, err := dataStore.getEntry(key)
+	if err != nil {
+		return time.Now(), err
+	}
+	return entry.expirationTime, nil
+}
+
+// RefreshExpirationTime keeps the value for another ttl, which replaces the time to live it was inserted with.
+func (dataStore *DiskDataStore) RefreshExpirationTime(key *Key, ttl time.Duration) error {
+	dataStore.lock.Lock()
+	defer dataStore.lock.Unlock()
+
+	return dataStore.refreshExpirationTime(key, ttl)
+}
+
+// refreshExpirationTime logs a new expiration time for the key. The record is not synced to disk,
+// losing it in a crash only makes the value expire earlier.
+func (dataStore *DiskDataStore) refreshExpirationTime(key *Key, ttl time.Duration) error {
+	_, err := dataStore.getEntry(key)
+	if err != nil {
+		return err
+	}
+
+	record := diskRecord{
+		Operation:      DISK_REFRESH,
+		Key:            key.GetHashString(),
+		TTL:            ttl,
+		ExpirationTime: time.Now().Add(ttl).UnixNano(),
+	}
+	segmentId, offset, size, err := dataStore.write(record, false)
+	if err != nil {
+		return err
+	}
+	dataStore.apply(record, segmentId, offset, size)
+	return nil
+}
+
+func (dataStore *DiskDataStore) Delete(key *Key) error {
+	dataStore.lock.Lock()
+	defer dataStore.lock.Unlock()
+
+	_, err := dataStore.getEntry(key)
+	if err != nil {
+		return err
+	}
+
+	record := diskRecord{
+		Operation: DISK_DELETE,
+		Key:       key.GetHashString(),
+	}
+	segmentId, offset, size, err := dataStore.write(record, true)
+	if err != nil {
+		return err
+	}
+	dataStore.apply(record, segmentId, offset, size)
+	logger.Log("The data object " + key.GetHashString() + " has been deleted from disk.")
+	return nil
+}
+
+// Pin keeps the value of the key until it is unpinned or deleted, it no longer expires.
+func (dataStore *DiskDataStore) Pin(key *Key) error {
+	dataStore.lock.Lock()
+	defer dataStore.lock.Unlock()
+
+	_, err := dataStore.getEntry(key)
+	if err != nil {
+		return err
+	}
+
+	record := diskRecord{
+		Operation: DISK_PIN,
+		Key:       key.GetHashString(),
+	}
+	segmentId, offset, size, err := dataStore.write(record, true)
+	if err != nil {
+		return err
+	}
+	dataStore.apply(record, segmentId, offset, size)
+	return nil
+}
+
+// Unpin lets the value of the key expire again, once its time to live has passed from now without a refresh.
+func (dataStore *DiskDataStore) Unpin(key *Key) error {
+	dataStore.lock.Lock()
+	defer dataStore.lock.Unlock()
+
+	entry, err := dataStore.getEntry(key)
+	if err != nil {
+		return err
+	}
+
+	record := diskRecord{
+		Operation:      DISK_UNPIN,
+		Key:            key.GetHashString(),
+		ExpirationTime: time.Now().Add(entry.ttl).UnixNano(),
+	}
+	segmentId, offset, size, err := dataStore.write(record, true)
+	if err != nil {
+		return err
+	}
+	dataStore.apply(record, segmentId, offset, size)
+	return nil
+}
+
+// Items returns a snapshot of every key-value pair in the DiskDataStore, without refreshing their expiration times.
+func (dataStore *DiskDataStore) Items() []DataStoreItem {
+	dataStore.lock.Lock()
+	defer dataStore.lock.Unlock()
+
+	items := []DataStoreItem{}
+	for _, entry := range dataStore.index {
+		key := entry.key
+		if _, err := dataStore.getEntry(key); err != nil {
+			continue
+		}
+		value, err := dataStore.readValue(entry)
+		if err != nil {
+			logger.Log("Failed to read the data object " + key.GetHashString() + " from disk: " + err.Error())
+			continue
+		}
+		items = append(items, DataStoreItem{
+			Key:        key,
+			Value:      value,
+			StoreTime:  entry.storeTime,
+			TTL:        entry.ttl,
+			Expiration: entry.expirationTime,
+			Pinned:     entry.pinned,
+		})
+	}
+	return items
+}
+
+// Stats returns the number of keys and the total size of the values in the DiskDataStore.
+func (dataStore *DiskDataStore) Stats() DataStoreStats {
+	dataStore.lock.Lock()
+	defer dataStore.lock.Unlock()
+
+	stats := DataStoreStats{}
+	for _, entry := range dataStore.index {
+		stats.Keys++
+		stats.Bytes += entry.valueSize
+	}
+	return stats
+}
+
+// purgeExpired removes every expired key that is not pinned from the index. Their records are dropped by the next compaction.
+func (dataStore *DiskDataStore) purgeExpired() {
+	now := time.Now()
+	for hash, entry := range dataStore.index {
+		if entry.pinned || entry.expirationTime.After(now) {
+			continue
+		}
+		dataStore.deadBytes += entry.size
+		delete(dataStore.index, hash)
+		logger.Log("The data object " + entry.key.GetHashString() + " has been deleted due to the expired TTL.")
+	}
+}
+
+// maintain purges expired keys and compacts the log in the background until the DiskDataStore is closed.
+func (dataStore *DiskDataStore) maintain() {
+	lastCompaction := time.Now()
+	for {
+		select {
+		case <-dataStore.stop:
+			return
+
+		case <-time.After(diskExpirationInterval):
+			dataStore.lock.Lock()
+			dataStore.purgeExpired()
+			shouldCompact := time.Since(lastCompaction) >= DiskCompactionInterval && dataStore.deadBytes >= diskCompactionMinDeadBytes
+			dataStore.lock.Unlock()
+
+			if shouldCompact {
+				lastCompaction = time.Now()
+				err := dataStore.Compact()
+				if err != nil {
+					logger.Log("Failed to compact the data store: " + err.Error())
+				}
+			}
+		}
+	}
+}
+
+// Compact rewrites every live value into a single new segment and removes the old segments. The new segment is
+// written to a temporary file first, so a crash during compaction leaves the old segments intact.
+func (dataStore *DiskDataStore) Compact() error {
+	dataStore.lock.Lock()
+	defer dataStore.lock.Unlock()
+
+	dataStore.purgeExpired()
+
+	compactedSegment := dataStore.activeSegment + 1
+	temporaryPath := dataStore.segmentPath(compactedSegment) + diskTemporarySuffix
+	file, err := os.OpenFile(temporaryPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	compactedIndex := make(map[[KeySize]byte]*diskIndexEntry)
+	var offset int64
+	for hash, entry := range dataStore.index {
+		value, err := dataStore.readValue(entry)
+		if err != nil {
+			file.Close()
+			os.Remove(temporaryPath)
+			return err
+		}
+
+		bytes, err := encodeDiskRecord(diskRecord{
+			Operation:      DISK_PUT,
+			Key:            entry.key.GetHashString(),
+			Value:          &value,
+			TTL:            entry.ttl,
+			ExpirationTime: entry.expirationTime.UnixNano(),
+			StoreTime:      entry.storeTime.UnixNano(),
+			Pinned:         entry.pinned,
+		})
+		if err == nil {
+			_, err = file.Write(bytes)
+		}
+		if err != nil {
+			file.Close()
+			os.Remove(temporaryPath)
+			return err
+		}
+
+		compactedIndex[hash] = &diskIndexEntry{
+			key:            entry.key,
+			segment:        compactedSegment,
+			offset:         offset,
+			size:           int64(len(bytes)),
+			valueSize:      entry.valueSize,
+			ttl:            entry.ttl,
+			expirationTime: entry.expirationTime,
+			storeTime:      entry.storeTime,
+			pinned:         entry.pinned,
+		}
+		offset += int64(len(bytes))
+	}
+
+	err = file.Sync()
+	if err == nil {
+		err = os.Rename(temporaryPath, dataStore.segmentPath(compactedSegment))
+	}
+	if err != nil {
+		file.Close()
+		os.Remove(temporaryPath)
+		return err
+	}
+
+	// The old segments are removed oldest first, so that a crash in between never leaves a PUT without the DELETE after it
+	oldSegmentIds := []int{}
+	for segmentId := range dataStore.segments {
+		oldSegmentIds = append(oldSegmentIds, segmentId)
+	}
+	sort.Ints(oldSegmentIds)
+	for _, segmentId := range oldSegmentIds {
+		dataStore.segments[segmentId].Close()
+		os.Remove(dataStore.segmentPath(segmentId))
+		delete(dataStore.segments, segmentId)
+	}
+	dataStore.segments[compactedSegment] = file
+	dataStore.index = compactedIndex
+	dataStore.deadBytes = 0
+
+	return dataStore.openActiveSegment(compactedSegment + 1)
+}
+
+// Close stops the background maintenance and closes the segment files.
+func (dataStore *DiskDataStore) Close() error {
+	dataStore.stop <- true
+
+	dataStore.lock.Lock()
+	defer dataStore.lock.Unlock()
+
+	err := dataStore.segments[dataStore.activeSegment].Sync()
+	dataStore.closeSegments()
+	return err
+}
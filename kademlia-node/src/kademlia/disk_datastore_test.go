@@ -0,0 +1,261 @@
+package kademlia
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiskDataStoreInsertAndGet(t *testing.T) {
+	dataStore, err := NewDiskDataStore(t.TempDir())
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+	defer dataStore.Close()
+
+	value := "testValue"
+	key := NewKey(value)
+	dataStore.Insert(key, NewTextValue(value), DefaultTTL)
+
+	retrievedValue, err := dataStore.Get(key)
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+	assert.Equal(t, value, string(retrievedValue.Data))
+
+	_, err = dataStore.Get(NewKey("testValue2"))
+	assert.Error(t, err)
+}
+
+func TestDiskDataStoreSurvivesRestart(t *testing.T) {
+	directory := t.TempDir()
+	dataStore, err := NewDiskDataStore(directory)
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+
+	value := "testValue"
+	key := NewKey(value)
+	dataStore.Insert(key, NewTextValue(value), DefaultTTL)
+	deletedValue := "testValue2"
+	dataStore.Insert(NewKey(deletedValue), NewTextValue(deletedValue), DefaultTTL)
+	dataStore.Delete(NewKey(deletedValue))
+	expirationTime, _ := dataStore.GetTime(key)
+	dataStore.Close()
+
+	reopenedDataStore, err := NewDiskDataStore(directory)
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+	defer reopenedDataStore.Close()
+
+	reopenedExpirationTime, err := reopenedDataStore.GetTime(key)
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+	assert.True(t, expirationTime.Equal(reopenedExpirationTime))
+
+	retrievedValue, err := reopenedDataStore.Get(key)
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+	assert.Equal(t, value, string(retrievedValue.Data))
+
+	_, err = reopenedDataStore.Get(NewKey(deletedValue))
+	assert.Error(t, err)
+}
+
+func TestDiskDataStoreKeepsMultihashKeys(t *testing.T) {
+	directory := t.TempDir()
+	dataStore, err := NewDiskDataStore(directory)
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+
+	key, _ := NewKeyWithHashFunction([]byte("testValue"), BLAKE2B_256)
+	dataStore.Insert(key, NewTextValue("testValue"), DefaultTTL)
+	dataStore.Close()
+
+	reopenedDataStore, err := NewDiskDataStore(directory)
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+	defer reopenedDataStore.Close()
+
+	items := reopenedDataStore.Items()
+	if assert.Len(t, items, 1) {
+		assert.Equal(t, key, items[0].Key)
+	}
+}
+
+func TestDiskDataStorePurgesExpiredOnLoad(t *testing.T) {
+	directory := t.TempDir()
+	dataStore, err := NewDiskDataStore(directory)
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+
+	value := "testValue"
+	dataStore.Insert(NewKey(value), NewTextValue(value), time.Millisecond*100)
+	dataStore.Close()
+
+	time.Sleep(time.Millisecond * 200)
+
+	reopenedDataStore, err := NewDiskDataStore(directory)
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+	defer reopenedDataStore.Close()
+
+	assert.Empty(t, reopenedDataStore.Items())
+	assert.Equal(t, DataStoreStats{}, reopenedDataStore.Stats())
+}
+
+func TestDiskDataStoreRecoversFromTornWrite(t *testing.T) {
+	directory := t.TempDir()
+	dataStore, err := NewDiskDataStore(directory)
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+
+	value := "testValue"
+	key := NewKey(value)
+	dataStore.Insert(key, NewTextValue(value), DefaultTTL)
+	segmentPath := dataStore.segmentPath(dataStore.activeSegment)
+	dataStore.Close()
+
+	// Simulate a crash in the middle of writing the next record
+	info, _ := os.Stat(segmentPath)
+	file, _ := os.OpenFile(segmentPath, os.O_WRONLY|os.O_APPEND, 0644)
+	file.Write([]byte{0, 0, 1, 0, 1, 2, 3, 4, '{', '"'})
+	file.Close()
+
+	reopenedDataStore, err := NewDiskDataStore(directory)
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+	defer reopenedDataStore.Close()
+
+	retrievedValue, err := reopenedDataStore.Get(key)
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+	assert.Equal(t, value, string(retrievedValue.Data))
+
+	truncatedInfo, _ := os.Stat(segmentPath)
+	assert.Equal(t, info.Size(), truncatedInfo.Size())
+}
+
+func TestDiskDataStoreCompact(t *testing.T) {
+	directory := t.TempDir()
+	dataStore, err := NewDiskDataStore(directory)
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+
+	value := "testValue"
+	key := NewKey(value)
+	for i := 0; i < 10; i++ {
+		dataStore.Insert(key, NewTextValue(value), DefaultTTL)
+		dataStore.RefreshExpirationTime(key, DefaultTTL)
+	}
+	deletedValue := "testValue2"
+	dataStore.Insert(NewKey(deletedValue), NewTextValue(deletedValue), DefaultTTL)
+	dataStore.Delete(NewKey(deletedValue))
+
+	err = dataStore.Compact()
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+	assert.Equal(t, int64(0), dataStore.deadBytes)
+
+	// The compacted segment and the new empty active segment
+	segments, _ := filepath.Glob(filepath.Join(directory, diskSegmentPrefix+"*"))
+	assert.Len(t, segments, 2)
+	dataStore.Close()
+
+	reopenedDataStore, err := NewDiskDataStore(directory)
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+	defer reopenedDataStore.Close()
+
+	assert.Equal(t, DataStoreStats{Keys: 1, Bytes: len(value)}, reopenedDataStore.Stats())
+	retrievedValue, err := reopenedDataStore.Get(key)
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+	assert.Equal(t, value, string(retrievedValue.Data))
+}
+
+func TestDiskDataStoreKeepsPinsAcrossRestart(t *testing.T) {
+	directory := t.TempDir()
+	dataStore, err := NewDiskDataStore(directory)
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+
+	value := "testValue"
+	key := NewKey(value)
+	dataStore.Insert(key, NewTextValue(value), time.Millisecond*100)
+	assert.NoError(t, dataStore.Pin(key))
+	// Storing the value again keeps it pinned
+	dataStore.Insert(key, NewTextValue(value), time.Millisecond*100)
+	dataStore.Close()
+
+	time.Sleep(time.Millisecond * 200)
+
+	reopenedDataStore, err := NewDiskDataStore(directory)
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+	defer reopenedDataStore.Close()
+
+	assert.NoError(t, reopenedDataStore.Compact())
+	items := reopenedDataStore.Items()
+	if assert.Len(t, items, 1) {
+		assert.True(t, items[0].Pinned)
+	}
+
+	assert.NoError(t, reopenedDataStore.Unpin(key))
+	time.Sleep(time.Millisecond * 200)
+	_, err = reopenedDataStore.Get(key)
+	assert.Error(t, err)
+}
+
+func TestDiskDataStoreInsertFailsWhenTheDirectoryIsUnwritable(t *testing.T) {
+	directory := filepath.Join(t.TempDir(), "segments")
+	dataStore, err := NewDiskDataStore(directory)
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+	defer dataStore.Close()
+	kademliaNode := NewKademliaNode("127.0.0.1", 3010, false, WithDataStore(dataStore))
+	messageHandler := &MessageHandlerImplementation{
+		kademliaNode: kademliaNode,
+	}
+
+	// The next record needs a new segment, which cannot be created once the directory is replaced by a file, not even
+	// by root
+	dataStore.activeSize = DiskSegmentMaxSize
+	assert.NoError(t, os.RemoveAll(directory))
+	assert.NoError(t, os.WriteFile(directory, nil, 0400))
+
+	value := NewTextValue("value")
+	assert.Error(t, dataStore.Insert(value.GetKey(), value, DefaultTTL))
+	_, err = dataStore.Get(value.GetKey())
+	assert.Error(t, err)
+
+	// A STORE the node could not write is refused rather than acknowledged
+	bytes, _ := json.Marshal(NewStoreMessage(NewContact(NewRandomKademliaID(), "127.0.0.1", 80), value.GetKey(), value, DefaultTTL))
+	response, err := messageHandler.HandleMessage(bytes, "127.0.0.1")
+	assert.NoError(t, err)
+	var storeResponse StoreResponse
+	json.Unmarshal(response, &storeResponse)
+	assert.False(t, storeResponse.StoreSuccess)
+	assert.Empty(t, dataStore.Items())
+}
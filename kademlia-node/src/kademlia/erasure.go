@@ -0,0 +1,350 @@
+package kademlia
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/arianfiftyone/src/logger"
+	"golang.org/x/exp/slices"
+)
+
+const (
+	// ShardContentType is the content type of a Value holding a Shard.
+	ShardContentType = "application/vnd.kademlia.shard+json"
+
+	// ShardRepairInterval is how often a node regenerates the lost shards of the values it stored erasure coded.
+	ShardRepairInterval = time.Second * 30
+)
+
+// Shard is one of the shards of an erasure coded value. Each shard is stored under a key of its own, derived from the
+// hash of the value and the index of the shard, and carries what is needed to rebuild the value from any
+// Coding.DataShards of the shards. The digests of every shard of the value tell a corrupt shard from a lost one, which
+// only the hash of the value could not.
+type Shard struct {
+	Object      string        `json:"object"` // The hash of the value
+	Index       int           `json:"index"`
+	Coding      ErasureCoding `json:"coding"`
+	Size        int           `json:"size"` // The size of the value, without the padding of the last data shard
+	ContentType string        `json:"contentType,omitempty"`
+	Digests     [][]byte      `json:"digests"` // The digest of the data of every shard of the value, by index
+	Data        []byte        `json:"data"`
+}
+
+// shardDigest returns the digest of the data of a shard.
+func shardDigest(data []byte) []byte {
+	digest := sha256.Sum256(data)
+	return digest[:]
+}
+
+// describesSameValue reports whether two shards agree about the value they are shards of.
+func (shard Shard) describesSameValue(other Shard) bool {
+	return shard.Object == other.Object && shard.Coding == other.Coding && shard.Size == other.Size &&
+		shard.ContentType == other.ContentType && slices.EqualFunc(shard.Digests, other.Digests, bytes.Equal)
+}
+
+// NewShardKey returns the key shard i of the value with the given key is stored under.
+func NewShardKey(object *Key, i int) *Key {
+	return NewKey(ShardNamespace + "/" + object.GetHashString() + "/" + strconv.Itoa(i))
+}
+
+// ShardFromValue decodes the shard held by a value.
+func ShardFromValue(value Value) (Shard, error) {
+	var shard Shard
+	if value.ContentType != ShardContentType {
+		return shard, errors.New("the value is not a shard")
+	}
+	err := json.Unmarshal(value.Data, &shard)
+	return shard, err
+}
+
+// GetKey returns the key the shard is stored under.
+func (shard Shard) GetKey() (*Key, error) {
+	object, err := ParseKey(shard.Object)
+	if err != nil {
+		return nil, err
+	}
+	return NewShardKey(object, shard.Index), nil
+}
+
+// ToValue encodes the shard as a value that can be stored in the network.
+func (shard Shard) ToValue() (Value, error) {
+	data, err := json.Marshal(shard)
+	if err != nil {
+		return Value{}, err
+	}
+	value := NewValue(data, ShardContentType)
+	value.Namespace = ShardNamespace
+	return value, nil
+}
+
+// StoreErasureCoded splits the value into shards with the erasure coding and stores each shard at as few nodes as the
+// write quorum allows, at different nodes where possible, instead of storing the value at the k closest nodes. With
+// the default write quorum of one every shard is held by a single node, so the parity shards are the only redundancy
+// and a shard is lost with the node holding it until the next repair, see WithWriteQuorum to keep more copies. The
+// value can be rebuilt by LookupErasureCoded with the hash in the result as long as Coding.DataShards shards are left,
+// and the shards are kept refreshed and repaired until they are forgotten. The result lists what every node answered
+// for every shard, and an error is returned with it if too few shards were stored to rebuild the value.
+func (kademlia *KademliaImplementation) StoreErasureCoded(value Value, coding ErasureCoding, ttl time.Duration) (*StoreResult, error) {
+	key, err := NewKeyWithHashFunction(value.Data, kademlia.KademliaNode.GetHashFunction())
+	if err != nil {
+		return nil, err
+	}
+	data, err := coding.Encode(value.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	digests := [][]byte{}
+	for i := range data {
+		digests = append(digests, shardDigest(data[i]))
+	}
+	shards := make(map[int]Shard)
+	for i := range data {
+		shards[i] = Shard{Object: key.GetHashString(), Index: i, Coding: coding, Size: len(value.Data), ContentType: value.ContentType, Digests: digests, Data: data[i]}
+	}
+	result, stored := kademlia.storeShards(key, shards, ttl)
+	if stored < coding.DataShards {
+		return result, errors.New("only " + strconv.Itoa(stored) + " of the " + strconv.Itoa(coding.DataShards) + " shards needed to rebuild the value were stored")
+	}
+	return result, nil
+}
+
+// storeShards stores the shards of the value with the given key, and returns what every node answered and how many
+// shards were stored. The nodes are looked up in parallel, and then each shard is given the closest nodes to its key
+// that no earlier shard was given, so that losing one node loses as few shards as possible.
+func (kademlia *KademliaImplementation) storeShards(key *Key, shards map[int]Shard, ttl time.Duration) (*StoreResult, int) {
+	indices := []int{}
+	for i := range shards {
+		indices = append(indices, i)
+	}
+	sort.Ints(indices)
+
+	closest := make([][]Contact, len(indices))
+	var waitGroup sync.WaitGroup
+	for j, i := range indices {
+		waitGroup.Add(1)
+		go func(j int, i int) {
+			defer waitGroup.Done()
+			closest[j], _ = kademlia.LookupContact(NewShardKey(key, i).GetKademliaIdRepresentationOfKey())
+		}(j, i)
+	}
+	waitGroup.Wait()
+
+	// As many copies of each shard as the write quorum requires, which is one by default
+	replicas := max(1, kademlia.KademliaNode.GetWriteQuorum())
+	used := make(map[KademliaID]bool)
+	assigned := make([][]Contact, len(indices))
+	for j := range indices {
+		for _, contact := range closest[j] {
+			if len(assigned[j]) < replicas && !used[*contact.ID] {
+				assigned[j] = append(assigned[j], contact)
+				used[*contact.ID] = true
+			}
+		}
+		// With fewer nodes than shards some nodes hold several shards
+		for _, contact := range closest[j] {
+			if len(assigned[j]) < replicas && !kademlia.FirstSetContainsAllContactsOfSecondSet(assigned[j], []Contact{contact}) {
+				assigned[j] = append(assigned[j], contact)
+			}
+		}
+	}
+
+	result := &StoreResult{Key: key}
+	results := make([]*StoreResult, len(indices))
+	stored := make([]bool, len(indices))
+	validators := kademlia.KademliaNode.GetValidators()
+	for j, i := range indices {
+		waitGroup.Add(1)
+		go func(j int, i int) {
+			defer waitGroup.Done()
+			shardKey := NewShardKey(key, i)
+			value, err := shards[i].ToValue()
+			if err == nil {
+				err = validators.Validate(shardKey, value)
+			}
+			if err != nil {
+				logger.Log("Failed to encode shard " + strconv.Itoa(i) + " of " + key.GetHashString() + ": " + err.Error())
+				return
+			}
+			results[j], err = kademlia.storeAtClosest(shardKey, value, ttl, assigned[j])
+			stored[j] = err == nil
+		}(j, i)
+	}
+	waitGroup.Wait()
+
+	count := 0
+	for j := range indices {
+		if results[j] != nil {
+			result.Replicas = append(result.Replicas, results[j].Replicas...)
+		}
+		if stored[j] {
+			count++
+		}
+	}
+	return result, count
+}
+
+// findShards looks up the shards of the value with the given key, and returns every shard found. Since a value that
+// can be rebuilt has lost at most MaxParityShards shards, the shards up to that index are probed until one is found,
+// and then the rest of the shards its erasure coding has.
+func (kademlia *KademliaImplementation) findShards(key *Key) ([]Shard, error) {
+	found := []Shard{}
+	lookup := func(from int, to int) {
+		keys := []*Key{}
+		for i := from; i < to; i++ {
+			keys = append(keys, NewShardKey(key, i))
+		}
+		for _, result := range kademlia.LookupMany(keys) {
+			if result.Value == nil {
+				continue
+			}
+			shard, err := ShardFromValue(*result.Value)
+			if err == nil {
+				found = append(found, shard)
+			}
+		}
+	}
+
+	lookedUp := DefaultErasureCoding.TotalShards()
+	lookup(0, lookedUp)
+	if len(found) == 0 {
+		lookup(lookedUp, MaxParityShards+1)
+		lookedUp = MaxParityShards + 1
+	}
+	if len(found) == 0 {
+		return nil, errors.New("no shard of the value was found")
+	}
+	total := lookedUp
+	for _, shard := range found {
+		total = max(total, shard.Coding.TotalShards())
+	}
+	if total > lookedUp {
+		lookup(lookedUp, total)
+	}
+	return found, nil
+}
+
+// rebuild returns the value the shards hold, which must hash to the key. The shards are grouped by what they tell of
+// the value, and the largest group that rebuilds it is used. A shard whose data does not have the digest the group
+// lists for it is corrupt and is treated as lost. It also returns a shard of the group, which tells the erasure coding
+// of the value, the data of every shard by index with the lost ones regenerated, and the indices of the lost shards.
+func rebuild(key *Key, found []Shard) ([]byte, *Shard, [][]byte, []int, error) {
+	groups := [][]Shard{}
+	for _, shard := range found {
+		i := slices.IndexFunc(groups, func(group []Shard) bool { return group[0].describesSameValue(shard) })
+		if i < 0 {
+			groups = append(groups, []Shard{shard})
+		} else {
+			groups[i] = append(groups[i], shard)
+		}
+	}
+	sort.SliceStable(groups, func(i, j int) bool { return len(groups[i]) > len(groups[j]) })
+
+	for _, group := range groups {
+		reference := group[0]
+		total := reference.Coding.TotalShards()
+		if reference.Object != key.GetHashString() || len(reference.Digests) != total {
+			continue
+		}
+		shards := make([][]byte, total)
+		for _, shard := range group {
+			if shard.Index >= 0 && shard.Index < total && bytes.Equal(shardDigest(shard.Data), reference.Digests[shard.Index]) {
+				shards[shard.Index] = shard.Data
+			}
+		}
+		lost := []int{}
+		for i, shard := range shards {
+			if shard == nil {
+				lost = append(lost, i)
+			}
+		}
+
+		if reference.Coding.Reconstruct(shards) != nil {
+			continue
+		}
+		data, err := reference.Coding.Join(shards, reference.Size)
+		if err != nil || !key.Matches(data) {
+			continue
+		}
+		// The regenerated shards must have the digests the group lists, or they would be taken for corrupt ones
+		if slices.ContainsFunc(lost, func(i int) bool { return !bytes.Equal(shardDigest(shards[i]), reference.Digests[i]) }) {
+			continue
+		}
+		return data, &reference, shards, lost, nil
+	}
+	return nil, nil, nil, nil, errors.New("the shards do not rebuild the value")
+}
+
+// LookupErasureCoded rebuilds the value with the given key from its shards, which are looked up in parallel.
+func (kademlia *KademliaImplementation) LookupErasureCoded(key *Key) (*Value, error) {
+	found, err := kademlia.findShards(key)
+	if err != nil {
+		return nil, err
+	}
+	data, reference, _, _, err := rebuild(key, found)
+	if err != nil {
+		return nil, err
+	}
+	value := NewValue(data, reference.ContentType)
+	return &value, nil
+}
+
+// RepairErasureCoded looks up the shards of the value with the given key, regenerates the shards that are lost or
+// corrupt from the others, and stores them again with the time to live. It returns how many shards were stored.
+func (kademlia *KademliaImplementation) RepairErasureCoded(key *Key, ttl time.Duration) (int, error) {
+	found, err := kademlia.findShards(key)
+	if err != nil {
+		return 0, err
+	}
+	_, reference, shards, missing, err := rebuild(key, found)
+	if err != nil {
+		return 0, err
+	}
+	if len(missing) == 0 {
+		return 0, nil
+	}
+
+	repaired := make(map[int]Shard)
+	for _, i := range missing {
+		shard := *reference
+		shard.Index = i
+		shard.Data = shards[i]
+		repaired[i] = shard
+	}
+	_, stored := kademlia.storeShards(key, repaired, ttl)
+	logger.Log("Repaired " + strconv.Itoa(stored) + " of the " + strconv.Itoa(len(missing)) + " lost shards of " + key.GetHashString())
+	if stored < len(missing) {
+		return stored, errors.New("only " + strconv.Itoa(stored) + " of the " + strconv.Itoa(len(missing)) + " lost shards were stored")
+	}
+	return stored, nil
+}
+
+// repairShards repairs the values whose shards this node publishes once every shard repair interval.
+func (kademlia *KademliaImplementation) repairShards() {
+	for {
+		<-time.After(ShardRepairInterval)
+
+		ttls := make(map[string]time.Duration)
+		for _, publication := range kademlia.KademliaNode.GetPublisherRegistry().GetPublications() {
+			if shard, err := ShardFromValue(publication.Value); err == nil {
+				ttls[shard.Object] = publication.TTL
+			}
+		}
+		for object, ttl := range ttls {
+			key, err := ParseKey(object)
+			if err != nil {
+				continue
+			}
+			_, err = kademlia.RepairErasureCoded(key, ttl)
+			if err != nil {
+				logger.Log("Failed to repair the shards of " + object + ": " + err.Error())
+			}
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package kademlia
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// removeShards drops the shards with the given indices from every contact of the network.
+func removeShards(network *NetworkFileMock, key *Key, indices ...int) {
+	network.lock.Lock()
+	defer network.lock.Unlock()
+	for _, i := range indices {
+		delete(network.values, NewShardKey(key, i).Hash)
+	}
+}
+
+func TestStoreAndLookupErasureCoded(t *testing.T) {
+	network := &NetworkFileMock{}
+	kademlia := createQuorumTestKademlia(network)
+	value := NewTextValue("a value that is split into four data shards and two parity shards")
+
+	result, err := kademlia.StoreErasureCoded(value, DefaultErasureCoding, DefaultTTL)
+	assert.NoError(t, err)
+	assert.True(t, result.Key.Equals(value.GetKey()))
+	// Each shard is stored at a single node
+	assert.Len(t, result.Replicas, 6)
+	assert.Len(t, network.values, 6)
+
+	removeShards(network, result.Key, 0, 4)
+	found, err := kademlia.LookupErasureCoded(result.Key)
+	assert.NoError(t, err)
+	assert.Equal(t, value.Data, found.Data)
+	assert.Equal(t, TextContentType, found.ContentType)
+
+	removeShards(network, result.Key, 1)
+	_, err = kademlia.LookupErasureCoded(result.Key)
+	assert.Error(t, err)
+}
+
+func TestRepairErasureCoded(t *testing.T) {
+	network := &NetworkFileMock{}
+	kademlia := createQuorumTestKademlia(network)
+	value := NewTextValue("a value whose lost shards are regenerated")
+	result, err := kademlia.StoreErasureCoded(value, DefaultErasureCoding, DefaultTTL)
+	assert.NoError(t, err)
+
+	repaired, err := kademlia.RepairErasureCoded(result.Key, DefaultTTL)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, repaired)
+
+	removeShards(network, result.Key, 0, 1)
+	repaired, err = kademlia.RepairErasureCoded(result.Key, DefaultTTL)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, repaired)
+	assert.Len(t, network.values, 6)
+
+	// The repaired shards are the shards that were lost, so the value can be rebuilt without the parity shards
+	removeShards(network, result.Key, 4, 5)
+	found, err := kademlia.LookupErasureCoded(result.Key)
+	assert.NoError(t, err)
+	assert.Equal(t, value.Data, found.Data)
+
+	removeShards(network, result.Key, 2)
+	_, err = kademlia.RepairErasureCoded(result.Key, DefaultTTL)
+	assert.Error(t, err)
+}
+
+// replaceShard replaces the data of shard i at every contact of the network, and lists the digest of the new data as
+// the digest of the shard if forged is set.
+func replaceShard(network *NetworkFileMock, key *Key, i int, data []byte, forged bool) {
+	network.lock.Lock()
+	defer network.lock.Unlock()
+	shard, _ := ShardFromValue(network.values[NewShardKey(key, i).Hash])
+	shard.Data = data
+	if forged {
+		shard.Digests = append([][]byte{}, shard.Digests...)
+		shard.Digests[i] = shardDigest(data)
+	}
+	network.values[NewShardKey(key, i).Hash], _ = shard.ToValue()
+}
+
+func TestRepairErasureCodedReplacesCorruptShards(t *testing.T) {
+	network := &NetworkFileMock{}
+	kademlia := createQuorumTestKademlia(network)
+	value := NewTextValue("a value whose corrupt shards are regenerated")
+	result, err := kademlia.StoreErasureCoded(value, DefaultErasureCoding, DefaultTTL)
+	assert.NoError(t, err)
+
+	// One shard is corrupt, and another lists a digest that matches its corrupt data
+	shard, _ := ShardFromValue(network.values[NewShardKey(result.Key, 0).Hash])
+	corrupt := make([]byte, len(shard.Data))
+	replaceShard(network, result.Key, 0, corrupt, false)
+	replaceShard(network, result.Key, 1, corrupt, true)
+
+	found, err := kademlia.LookupErasureCoded(result.Key)
+	assert.NoError(t, err)
+	assert.Equal(t, value.Data, found.Data)
+
+	repaired, err := kademlia.RepairErasureCoded(result.Key, DefaultTTL)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, repaired)
+
+	// Without the parity shards the value is rebuilt from the repaired shards
+	removeShards(network, result.Key, 4, 5)
+	found, err = kademlia.LookupErasureCoded(result.Key)
+	assert.NoError(t, err)
+	assert.Equal(t, value.Data, found.Data)
+}
+
+func TestLookupErasureCodedMissingValue(t *testing.T) {
+	kademlia := createQuorumTestKademlia(&NetworkFileMock{})
+
+	_, err := kademlia.LookupErasureCoded(NewKey("missing"))
+	assert.Error(t, err)
+}
+
+func TestShardValidator(t *testing.T) {
+	validators := ValidatorRegistry{}
+	object := NewKey("object")
+	digests := make([][]byte, DefaultErasureCoding.TotalShards())
+	digests[1] = shardDigest([]byte{1, 2})
+	shard := Shard{Object: object.GetHashString(), Index: 1, Coding: DefaultErasureCoding, Size: 8, Digests: digests, Data: []byte{1, 2}}
+	value, _ := shard.ToValue()
+	valid := value
+	assert.NoError(t, validators.Validate(NewShardKey(object, 1), value))
+	assert.Error(t, validators.Validate(NewShardKey(object, 2), value))
+
+	shard.Data = []byte{1, 2, 3}
+	value, _ = shard.ToValue()
+	assert.Error(t, validators.Validate(NewShardKey(object, 1), value))
+
+	// A corrupt shard has the right size but not its digest, and is replaced by the valid shard
+	shard.Data = []byte{1, 3}
+	value, _ = shard.ToValue()
+	assert.Error(t, validators.Validate(NewShardKey(object, 1), value))
+	assert.NoError(t, validators.ValidateUpdate(NewShardKey(object, 1), value, valid))
+	assert.Error(t, validators.ValidateUpdate(NewShardKey(object, 1), valid, value))
+
+	shard.Data = []byte{1, 2}
+	shard.Index = 6
+	value, _ = shard.ToValue()
+	assert.Error(t, validators.Validate(NewShardKey(object, 6), value))
+}
@@ -0,0 +1,41 @@
+package kademlia
+
+import (
+	"time"
+)
+
+// maxTTLHalvings bounds how many times the time to live of a value is halved, beyond it the minimum time to live is granted.
+const maxTTLHalvings = 32
+
+// expirationTTL returns the time to live the node grants a value stored under the key. As in the Kademlia paper, the
+// requested time to live is kept by the k closest nodes to the key, and halved for every other node known to be closer
+// to the key than this one, so cached and over-replicated copies expire fast. The result is clamped to the TTL bounds.
+func (kademliaNode *KademliaNodeImplementation) expirationTTL(key *Key, ttl time.Duration) time.Duration {
+	ttl = kademliaNode.clampTTL(ttl)
+
+	closer := kademliaNode.countCloserContacts(key, NumberOfClosestNodesToRetrieved+maxTTLHalvings)
+	halvings := closer - NumberOfClosestNodesToRetrieved + 1
+	if halvings <= 0 {
+		return ttl
+	}
+	return kademliaNode.clampTTL(max(ttl>>halvings, time.Nanosecond))
+}
+
+// countCloserContacts returns how many contacts in the routing table are closer to the key than this node, at most limit.
+func (kademliaNode *KademliaNodeImplementation) countCloserContacts(key *Key, limit int) int {
+	target := key.GetKademliaIdRepresentationOfKey()
+	me := kademliaNode.GetRoutingTable().Me
+	me.CalcDistance(target)
+
+	closer := 0
+	for _, contact := range kademliaNode.GetRoutingTable().FindClosestContacts(target, limit+1) {
+		if contact.ID.Equals(me.ID) {
+			continue
+		}
+		if !contact.Less(&me) || closer >= limit {
+			break
+		}
+		closer++
+	}
+	return closer
+}
@@ -0,0 +1,42 @@
+package kademlia
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func createExpirationTestNode(closerContacts int) (*KademliaNodeImplementation, *Key) {
+	kademliaNode := NewKademliaNode("127.0.0.1", 3009, false, WithTTLBounds(time.Second, time.Hour))
+	kademliaNode.RoutingTable = NewRoutingTable(NewContact(GenerateNewKademliaID("FFFFFFFF00000000000000000000000000000000"), "127.0.0.1", 3009))
+
+	// The contacts with a single bit set are all closer to the key than this node
+	key := GetKeyRepresentationOfKademliaId(GenerateNewKademliaID("0000000000000000000000000000000000000000"))
+	for i := 0; i < closerContacts; i++ {
+		id := KademliaID{}
+		id[IDLength-1-i/8] = 1 << (i % 8)
+		kademliaNode.RoutingTable.AddContact(NewContact(&id, "127.0.0.1", 3100+i))
+	}
+	return kademliaNode, key
+}
+
+func TestExpirationTTLAmongClosestNodes(t *testing.T) {
+	kademliaNode, key := createExpirationTestNode(NumberOfClosestNodesToRetrieved - 1)
+
+	assert.Equal(t, time.Minute, kademliaNode.expirationTTL(key, time.Minute))
+}
+
+func TestExpirationTTLHalvesWithCloserNodes(t *testing.T) {
+	kademliaNode, key := createExpirationTestNode(NumberOfClosestNodesToRetrieved)
+	assert.Equal(t, 30*time.Second, kademliaNode.expirationTTL(key, time.Minute))
+
+	kademliaNode, key = createExpirationTestNode(NumberOfClosestNodesToRetrieved + 2)
+	assert.Equal(t, 7500*time.Millisecond, kademliaNode.expirationTTL(key, time.Minute))
+}
+
+func TestExpirationTTLIsClampedToMinimum(t *testing.T) {
+	kademliaNode, key := createExpirationTestNode(NumberOfClosestNodesToRetrieved + 20)
+
+	assert.Equal(t, time.Second, kademliaNode.expirationTTL(key, time.Minute))
+}
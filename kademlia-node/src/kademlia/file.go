@@ -0,0 +1,136 @@
+package kademlia
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+const (
+	// ManifestContentType is the content type of a Value holding a Manifest.
+	ManifestContentType = "application/vnd.kademlia.manifest+json"
+
+	ChunkSize     = 32 * 1024 // The size of every chunk of a file but the last, small enough for a STORE to fit in a datagram
+	MaxFileChunks = 512       // How many chunks a manifest lists, so that the manifest fits in a datagram as well
+)
+
+// Manifest describes a file stored as chunks. Each chunk is stored as a value of its own, and the manifest lists
+// their hashes in order.
+type Manifest struct {
+	Name        string   `json:"name"`
+	Size        int      `json:"size"`
+	ContentType string   `json:"contentType,omitempty"`
+	ChunkSize   int      `json:"chunkSize"`
+	Chunks      []string `json:"chunks"`
+}
+
+// ManifestFromValue decodes the manifest held by a value.
+func ManifestFromValue(value Value) (Manifest, error) {
+	var manifest Manifest
+	if value.ContentType != ManifestContentType {
+		return manifest, errors.New("the value is not a file manifest")
+	}
+	err := json.Unmarshal(value.Data, &manifest)
+	return manifest, err
+}
+
+// ToValue encodes the manifest as a value that can be stored in the network.
+func (manifest Manifest) ToValue() (Value, error) {
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return Value{}, err
+	}
+	return NewValue(data, ManifestContentType), nil
+}
+
+// StoreFile splits the data into chunks of ChunkSize bytes and stores each of them, then stores the manifest of the
+// file. The result is the result of storing the manifest, whose hash is the hash GetFile takes. Every chunk and the
+// manifest are kept refreshed until they are forgotten. If a chunk or the manifest cannot be stored, the chunks stored
+// for the file are forgotten again.
+func (kademlia *KademliaImplementation) StoreFile(name string, data []byte, contentType string, ttl time.Duration) (*StoreResult, error) {
+	chunks := []Value{}
+	for start := 0; start < len(data); start += ChunkSize {
+		chunks = append(chunks, NewValue(data[start:min(start+ChunkSize, len(data))], BinaryContentType))
+	}
+	if len(chunks) > MaxFileChunks {
+		return nil, errors.New("the file is too large")
+	}
+
+	// The chunks another file shares with this one are still maintained for it if this file fails
+	registry := kademlia.KademliaNode.GetPublisherRegistry()
+	published := make(map[[KeySize]byte]bool)
+	for _, publication := range registry.GetPublications() {
+		published[publication.Key.Hash] = true
+	}
+	forgetChunks := func(results []BatchStoreResult) {
+		for _, result := range results {
+			if result.Key != nil && !published[result.Key.Hash] {
+				registry.Remove(result.Key)
+			}
+		}
+	}
+
+	manifest := Manifest{Name: name, Size: len(data), ContentType: contentType, ChunkSize: ChunkSize, Chunks: []string{}}
+	results := kademlia.StoreMany(chunks, ttl)
+	for _, result := range results {
+		if result.Err != nil {
+			forgetChunks(results)
+			return result.Result, errors.New("failed to store a chunk of the file: " + result.Err.Error())
+		}
+		manifest.Chunks = append(manifest.Chunks, result.Key.GetHashString())
+	}
+
+	value, err := manifest.ToValue()
+	if err != nil {
+		forgetChunks(results)
+		return nil, err
+	}
+	result, err := kademlia.Store(value, ttl)
+	if err != nil {
+		forgetChunks(results)
+	}
+	return result, err
+}
+
+// GetFile looks up the manifest with the given hash and rebuilds the file it describes. The chunks are looked up in
+// parallel, and each chunk must hash to the hash the manifest lists for it.
+func (kademlia *KademliaImplementation) GetFile(key *Key) (*Manifest, []byte, error) {
+	_, value, err := kademlia.LookupData(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if value == nil {
+		return nil, nil, errors.New("the manifest was not found")
+	}
+	manifest, err := ManifestFromValue(*value)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keys := []*Key{}
+	for _, hash := range manifest.Chunks {
+		chunkKey, err := ParseKey(hash)
+		if err != nil {
+			return nil, nil, errors.New("the manifest lists an invalid chunk hash")
+		}
+		keys = append(keys, chunkKey)
+	}
+
+	data := make([]byte, 0, manifest.Size)
+	for i, result := range kademlia.LookupMany(keys) {
+		if result.Err != nil {
+			return nil, nil, result.Err
+		}
+		if result.Value == nil {
+			return nil, nil, errors.New("chunk " + manifest.Chunks[i] + " of the file was not found")
+		}
+		if !result.Key.Matches(result.Value.Data) {
+			return nil, nil, errors.New("chunk " + manifest.Chunks[i] + " of the file does not match its hash")
+		}
+		data = append(data, result.Value.Data...)
+	}
+	if len(data) != manifest.Size {
+		return nil, nil, errors.New("the chunks of the file do not add up to its size")
+	}
+	return &manifest, data, nil
+}
@@ -0,0 +1,118 @@
+package kademlia
+
+import (
+	"crypto/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// NetworkFileMock stores every value at every contact unless manifests are refused, and answers a FIND_DATA with the
+// stored value, or with corrupt data for the corrupt key.
+type NetworkFileMock struct {
+	NetworkMock
+	lock            sync.Mutex
+	values          map[[KeySize]byte]Value
+	corrupt         *Key
+	refuseManifests bool
+}
+
+func (network *NetworkFileMock) SendStoreMessage(from *Contact, contact *Contact, key *Key, value Value, ttl time.Duration) error {
+	network.lock.Lock()
+	defer network.lock.Unlock()
+	if network.refuseManifests && value.ContentType == ManifestContentType {
+		return &StoreRefusedError{Reason: "the storage quota is reached"}
+	}
+	if network.values == nil {
+		network.values = make(map[[KeySize]byte]Value)
+	}
+	network.values[key.Hash] = value
+	return nil
+}
+
+func (network *NetworkFileMock) SendFindDataMessage(from *Contact, contact *Contact, key *Key) ([]Contact, *Value, error) {
+	network.lock.Lock()
+	defer network.lock.Unlock()
+	value, ok := network.values[key.Hash]
+	if !ok {
+		return nil, nil, nil
+	}
+	if network.corrupt != nil && network.corrupt.Equals(key) {
+		value.Data = append([]byte{}, value.Data...)
+		value.Data[0]++
+	}
+	return nil, &value, nil
+}
+
+func TestStoreAndGetFile(t *testing.T) {
+	kademlia := createQuorumTestKademlia(&NetworkFileMock{})
+	data := make([]byte, ChunkSize*5/2)
+	rand.Read(data)
+
+	result, err := kademlia.StoreFile("data.bin", data, BinaryContentType, DefaultTTL)
+	assert.NoError(t, err)
+
+	manifest, found, err := kademlia.GetFile(result.Key)
+	assert.NoError(t, err)
+	assert.Equal(t, "data.bin", manifest.Name)
+	assert.Equal(t, len(data), manifest.Size)
+	assert.Len(t, manifest.Chunks, 3)
+	assert.Equal(t, data, found)
+}
+
+func TestStoreFileForgetsChunksOnFailure(t *testing.T) {
+	network := &NetworkFileMock{}
+	kademlia := createQuorumTestKademlia(network)
+	data := make([]byte, ChunkSize*3/2)
+	rand.Read(data)
+
+	_, err := kademlia.StoreFile("data.bin", data, BinaryContentType, DefaultTTL)
+	assert.NoError(t, err)
+	assert.Len(t, kademlia.GetPublications(), 3)
+
+	// The chunks of the stored file are kept, the new chunks of the file whose manifest is refused are not
+	network.refuseManifests = true
+	_, err = kademlia.StoreFile("copy.bin", data, BinaryContentType, DefaultTTL)
+	assert.Error(t, err)
+	assert.Len(t, kademlia.GetPublications(), 3)
+
+	other := make([]byte, ChunkSize)
+	rand.Read(other)
+	_, err = kademlia.StoreFile("other.bin", other, BinaryContentType, DefaultTTL)
+	assert.Error(t, err)
+	assert.Len(t, kademlia.GetPublications(), 3)
+}
+
+func TestGetFileRejectsCorruptChunk(t *testing.T) {
+	network := &NetworkFileMock{}
+	kademlia := createQuorumTestKademlia(network)
+	data := make([]byte, ChunkSize*2)
+	rand.Read(data)
+
+	result, err := kademlia.StoreFile("data.bin", data, BinaryContentType, DefaultTTL)
+	assert.NoError(t, err)
+	_, value, _ := network.SendFindDataMessage(nil, nil, result.Key)
+	manifest, _ := ManifestFromValue(*value)
+	network.corrupt, _ = ParseKey(manifest.Chunks[1])
+
+	_, _, err = kademlia.GetFile(result.Key)
+	assert.Error(t, err)
+}
+
+func TestGetFileOfValueThatIsNoManifest(t *testing.T) {
+	kademlia := createQuorumTestKademlia(&NetworkFileMock{})
+	result, err := kademlia.Store(NewTextValue("not a manifest"), DefaultTTL)
+	assert.NoError(t, err)
+
+	_, _, err = kademlia.GetFile(result.Key)
+	assert.Error(t, err)
+}
+
+func TestStoreFileTooLarge(t *testing.T) {
+	kademlia := createQuorumTestKademlia(&NetworkFileMock{})
+
+	_, err := kademlia.StoreFile("large.bin", make([]byte, MaxFileChunks*ChunkSize+1), BinaryContentType, DefaultTTL)
+	assert.Error(t, err)
+}
@@ -2,6 +2,7 @@ package kademlia
 
 import (
 	"errors"
+	"strconv"
 	"sync"
 	"time"
 
@@ -12,20 +13,41 @@ import (
 type Kademlia interface {
 	Start()
 	Join()
-	Store(content string) (*Key, error)
+	Store(value Value, ttl time.Duration) (*StoreResult, error)
+	PutRecord(record Record, ttl time.Duration) (*StoreResult, error)
+	Put(key string, value Value, ttl time.Duration) (*StoreResult, error)
+	Get(key string) (*KeyValue, error)
 	GetKademliaNode() *KademliaNode
 	FirstSetContainsAllContactsOfSecondSet(first []Contact, second []Contact) bool
 	LookupContact(targetId *KademliaID) ([]Contact, error)
-	LookupData(key *Key) ([]Contact, string, error)
+	LookupData(key *Key) ([]Contact, *Value, error)
+	LookupDataInNamespace(key *Key, namespace string) ([]Contact, *Value, error)
+	LookupDataWithQuorum(key *Key, quorum int, repair bool) (*Value, ReplicaReport, error)
+	StoreMany(values []Value, ttl time.Duration) []BatchStoreResult
+	LookupMany(keys []*Key) []BatchLookupResult
 	Forget(key *Key) error
+	Delete(key *Key, token string) (*StoreResult, error)
+	Pin(key *Key) error
+	Unpin(key *Key) error
+	GetPins() []*Key
+	GetPublications() []Publication
+	Provide(key *Key, ttl time.Duration) (*StoreResult, error)
+	StopProviding(key *Key) error
+	FindProviders(key *Key) ([]Contact, error)
+	StoreFile(name string, data []byte, contentType string, ttl time.Duration) (*StoreResult, error)
+	GetFile(key *Key) (*Manifest, []byte, error)
+	StoreErasureCoded(value Value, coding ErasureCoding, ttl time.Duration) (*StoreResult, error)
+	LookupErasureCoded(key *Key) (*Value, error)
+	RepairErasureCoded(key *Key, ttl time.Duration) (int, error)
+	Subscribe(topic string) (*Subscription, error)
+	Publish(topic string, data []byte) (*StoreResult, error)
 }
 
 type KademliaImplementation struct {
-	Network             Network
-	KademliaNode        KademliaNode
-	isBootstrap         bool
-	bootstrapContact    *Contact
-	keyToStopRefreshMap (map[[KeySize]byte]chan bool) // The key represents the hash of a stored value, and the channel it maps to will stop refreshing the value if called on
+	Network          Network
+	KademliaNode     KademliaNode
+	isBootstrap      bool
+	bootstrapContact *Contact
 }
 
 type Lock struct {
@@ -43,9 +65,9 @@ const (
 )
 
 // NewKademlia gives new instance of a kademlia participant, it can start lisining for RPC's and join the network.
-func NewKademlia(ip string, port int, isBootstrap bool, bootstrapIp string, bootstrapPort int) *KademliaImplementation {
+func NewKademlia(ip string, port int, isBootstrap bool, bootstrapIp string, bootstrapPort int, options ...KademliaNodeOption) *KademliaImplementation {
 
-	kademliaNode := NewKademliaNode(ip, port, isBootstrap)
+	kademliaNode := NewKademliaNode(ip, port, isBootstrap, options...)
 	network := &NetworkImplementation{
 		ip,
 		port,
@@ -66,11 +88,10 @@ func NewKademlia(ip string, port int, isBootstrap bool, bootstrapIp string, boot
 		)
 	}
 	return &KademliaImplementation{
-		Network:             network,
-		KademliaNode:        kademliaNode,
-		isBootstrap:         isBootstrap,
-		bootstrapContact:    &contact,
-		keyToStopRefreshMap: make(map[[KeySize]byte]chan bool),
+		Network:          network,
+		KademliaNode:     kademliaNode,
+		isBootstrap:      isBootstrap,
+		bootstrapContact: &contact,
 	}
 
 }
@@ -84,6 +105,12 @@ func (kademlia *KademliaImplementation) Start() {
 		}()
 
 	}
+	go kademlia.replicate()
+	go kademlia.reannounceProviders()
+	go kademlia.antiEntropy()
+	go kademlia.repairShards()
+	// Values published before a restart are republished as well
+	kademlia.KademliaNode.GetPublisherRegistry().startRepublishing(kademlia.republish)
 
 	err := kademlia.Network.Listen()
 	if err != nil {
@@ -144,66 +171,193 @@ func (kademlia *KademliaImplementation) Join() {
 	kademlia.refresh()
 }
 
+// Forget stops republishing the value of the key, which then expires at its replicas.
 func (kademlia *KademliaImplementation) Forget(key *Key) error {
-	_, ok := kademlia.keyToStopRefreshMap[key.Hash]
-	if !ok {
+	if !kademlia.KademliaNode.GetPublisherRegistry().Remove(key) {
 		return errors.New("key not found")
 	}
-
-	kademlia.keyToStopRefreshMap[key.Hash] <- true
 	return nil
 }
 
-func (kademlia *KademliaImplementation) Store(content string) (*Key, error) {
+// GetPublications returns the values this node maintains in the network.
+func (kademlia *KademliaImplementation) GetPublications() []Publication {
+	return kademlia.KademliaNode.GetPublisherRegistry().GetPublications()
+}
+
+// Store stores the value at the k closest nodes to the hash of its data, and keeps refreshing it until it is forgotten.
+// The requested time to live is clamped to the bounds of this node, zero gives the default time to live. The result
+// lists what every contact answered, and an error is returned with it if fewer than the write quorum acknowledged.
+func (kademlia *KademliaImplementation) Store(value Value, ttl time.Duration) (*StoreResult, error) {
 	// A node finds k nodes to check if they are close to the hash
 
-	key := NewKey(content)
+	key, err := NewContentKey(value.Namespace, value.Data, kademlia.KademliaNode.GetHashFunction())
+	if err != nil {
+		return nil, err
+	}
+	return kademlia.storeUnderKey(key, value, ttl)
+}
+
+// PutRecord stores a signed record under the key of its publisher and salt, and keeps refreshing it until it is
+// forgotten. Storing nodes refuse the record unless its sequence number is higher than the one they hold.
+func (kademlia *KademliaImplementation) PutRecord(record Record, ttl time.Duration) (*StoreResult, error) {
+	value, err := record.ToValue()
+	if err != nil {
+		return nil, err
+	}
+	return kademlia.storeUnderKey(record.GetKey(), value, ttl)
+}
+
+func (kademlia *KademliaImplementation) storeUnderKey(key *Key, value Value, ttl time.Duration) (*StoreResult, error) {
+	err := kademlia.KademliaNode.GetValidators().Validate(key, value)
+	if err != nil {
+		return nil, err
+	}
 	contacts, err := kademlia.LookupContact(key.GetKademliaIdRepresentationOfKey())
 
 	if err != nil {
 		return nil, err
 	}
+	return kademlia.storeAtClosest(key, value, ttl, contacts)
+}
 
+// storeAtClosest stores a validated value at the k closest contacts to its key, and keeps refreshing it until it is
+// forgotten if enough of them store it.
+func (kademlia *KademliaImplementation) storeAtClosest(key *Key, value Value, ttl time.Duration, contacts []Contact) (*StoreResult, error) {
 	if len(contacts) <= 0 {
 		return nil, errors.New("found no node to store the value in")
 	}
+	ttl = kademlia.KademliaNode.clampTTL(ttl)
+	token, err := NewDeleteToken()
+	if err != nil {
+		return nil, err
+	}
+	value.DeleteTokenHash = HashDeleteToken(token)
 
-	kademlia.keyToStopRefreshMap[key.Hash] = make(chan bool)
-	// the contacts will be refreshed twice each time to live (ttl) time cycle
-	go func(key *Key, contacts []Contact) {
-		for {
-			select {
-			case stopRefresh := <-kademlia.keyToStopRefreshMap[key.Hash]:
-				if stopRefresh {
-					return
-
-				}
-			case <-time.After(time.Duration(kademlia.KademliaNode.GetDataStore().ttl / 2)):
-				for _, contact := range contacts {
-					kademlia.Network.SendRefreshExpirationTimeMessage(&kademlia.KademliaNode.GetRoutingTable().Me, &contact, key)
-				}
-			}
+	result := &StoreResult{Key: key, DeleteToken: token, Replicas: kademlia.storeAtContacts(key, value, ttl, contacts)}
+	contacts = result.GetContacts(STORE_ACKNOWLEDGED)
+	quorum := kademlia.KademliaNode.GetWriteQuorum()
+	if len(contacts) < quorum {
+		return result, errors.New("only " + strconv.Itoa(len(contacts)) + " of the " + strconv.Itoa(quorum) + " replicas required stored the value")
+	}
 
+	// The contacts will be refreshed twice each time to live (ttl) time cycle, replacing an earlier version of the value
+	registry := kademlia.KademliaNode.GetPublisherRegistry()
+	registry.Add(Publication{
+		Key:             key,
+		Value:           value,
+		TTL:             ttl,
+		DeleteToken:     token,
+		Contacts:        contacts,
+		LastRepublished: time.Now(),
+		Replicas:        len(contacts),
+	})
+	registry.startRepublishing(kademlia.republish)
+
+	return result, nil
+}
+
+// republish refreshes the expiration time of the value at each of its replicas, and stores it again at the replicas
+// that no longer hold it. It returns how many replicas hold the value afterwards.
+func (kademlia *KademliaImplementation) republish(publication Publication) int {
+	me := kademlia.KademliaNode.GetRoutingTable().Me
+	replicas := 0
+	for _, contact := range publication.Contacts {
+		if kademlia.Network.SendRefreshExpirationTimeMessage(&me, &contact, publication.Key, publication.TTL) ||
+			kademlia.Network.SendStoreMessage(&me, &contact, publication.Key, publication.Value, publication.TTL) == nil {
+			replicas++
 		}
-	}(key, contacts)
+	}
+	logger.Log("Republished the data object " + publication.Key.GetHashString() + " to " + strconv.Itoa(replicas) + " replicas")
+	return replicas
+}
 
-	for _, contact := range contacts {
-		kademlia.Network.SendStoreMessage(&kademlia.KademliaNode.GetRoutingTable().Me, &contact, key, content)
+// Delete removes the value of the key from the k closest nodes right away, which keep a tombstone until the value
+// would have expired at every replica so that it is not stored again. The token must be the delete token returned when the value was
+// stored. The result lists what every contact answered, and an error is returned with it if none deleted the value.
+func (kademlia *KademliaImplementation) Delete(key *Key, token string) (*StoreResult, error) {
+	contacts, err := kademlia.LookupContact(key.GetKademliaIdRepresentationOfKey())
+	if err != nil {
+		return nil, err
 	}
-	return key, nil
+
+	// The replicas keep their tombstones for as long as the value was published for, unknown unless this node published it
+	var ttl time.Duration
+	if publication, err := kademlia.KademliaNode.GetPublisherRegistry().Get(key); err == nil {
+		ttl = publication.TTL
+	}
+
+	me := kademlia.KademliaNode.GetRoutingTable().Me
+	result := &StoreResult{Key: key, Replicas: make([]StoreReplicaResult, len(contacts))}
+	var waitGroup sync.WaitGroup
+	for i, contact := range contacts {
+		waitGroup.Add(1)
+		go func(i int, contact Contact) {
+			defer waitGroup.Done()
+			result.Replicas[i] = newStoreReplicaResult(contact, kademlia.Network.SendDeleteMessage(&me, &contact, key, token, ttl))
+		}(i, contact)
+	}
+	waitGroup.Wait()
+
+	// This node may hold a replica as well, which it would otherwise keep replicating
+	localErr := kademlia.KademliaNode.deleteValue(key, token, ttl)
+	if len(result.GetContacts(STORE_ACKNOWLEDGED)) <= 0 && localErr != nil {
+		return result, errors.New("no replica deleted the value")
+	}
+
+	kademlia.KademliaNode.GetPublisherRegistry().Remove(key)
+	return result, nil
+}
+
+// storeAtContacts sends a STORE to each of the closest contacts in parallel and returns what every contact answered. A
+// contact that refuses the value or does not answer is replaced by the next closest contact in the routing table.
+func (kademlia *KademliaImplementation) storeAtContacts(key *Key, value Value, ttl time.Duration, closest []Contact) []StoreReplicaResult {
+	me := kademlia.KademliaNode.GetRoutingTable().Me
+
+	candidates := append([]Contact{}, closest...)
+	for _, contact := range kademlia.KademliaNode.GetRoutingTable().FindClosestContacts(key.GetKademliaIdRepresentationOfKey(), bucketSize) {
+		if !slices.ContainsFunc(candidates, func(candidate Contact) bool { return candidate.ID.Equals(contact.ID) }) {
+			candidates = append(candidates, contact)
+		}
+	}
+
+	results := []StoreReplicaResult{}
+	acknowledged := 0
+	for next := 0; acknowledged < len(closest) && next < len(candidates); {
+		batch := candidates[next:min(next+len(closest)-acknowledged, len(candidates))]
+		next += len(batch)
+
+		batchResults := make([]StoreReplicaResult, len(batch))
+		var waitGroup sync.WaitGroup
+		for i, contact := range batch {
+			waitGroup.Add(1)
+			go func(i int, contact Contact) {
+				defer waitGroup.Done()
+				batchResults[i] = newStoreReplicaResult(contact, kademlia.Network.SendStoreMessage(&me, &contact, key, value, ttl))
+			}(i, contact)
+		}
+		waitGroup.Wait()
+
+		for _, result := range batchResults {
+			if result.State == STORE_ACKNOWLEDGED {
+				acknowledged++
+			}
+		}
+		results = append(results, batchResults...)
+	}
+	return results
 }
 
 func (kademlia *KademliaImplementation) GetKademliaNode() *KademliaNode {
 	return &kademlia.KademliaNode
 }
 
-func (kademlia *KademliaImplementation) queryAlphaContacts(lookupType LookupType, contactsToQuery []Contact, queriedContacts *[]Contact, targetId KademliaID, foundContactsChannel chan []Contact, foundValueChannel chan string, queryFailedChannel chan error, lock *Lock) {
+func (kademlia *KademliaImplementation) queryAlphaContacts(lookupType LookupType, contactsToQuery []Contact, queriedContacts *[]Contact, targetId KademliaID, key *Key, foundContactsChannel chan []Contact, foundValueChannel chan *Value, queryFailedChannel chan error, lock *Lock) {
 
 	for i := 0; i < len(contactsToQuery); i++ {
 		go func(contactToQuery Contact) {
 			var foundContacts []Contact
 			var err error
-			var foundValue string
+			var foundValue *Value
 
 			switch lookupType {
 
@@ -211,7 +365,7 @@ func (kademlia *KademliaImplementation) queryAlphaContacts(lookupType LookupType
 				foundContacts, err = kademlia.Network.SendFindContactMessage(&kademlia.KademliaNode.GetRoutingTable().Me, &contactToQuery, &targetId)
 
 			case LOOKUP_DATA:
-				foundContacts, foundValue, err = kademlia.Network.SendFindDataMessage(&kademlia.KademliaNode.GetRoutingTable().Me, &contactToQuery, GetKeyRepresentationOfKademliaId(&targetId))
+				foundContacts, foundValue, err = kademlia.Network.SendFindDataMessage(&kademlia.KademliaNode.GetRoutingTable().Me, &contactToQuery, key)
 
 			}
 
@@ -219,12 +373,18 @@ func (kademlia *KademliaImplementation) queryAlphaContacts(lookupType LookupType
 			*queriedContacts = append(*queriedContacts, contactToQuery)
 			lock.mutex.Unlock()
 
+			// A value that does not hash to the key is discarded, as if the contact did not answer
+			if err == nil && foundValue != nil && kademlia.KademliaNode.GetValidators().Validate(key, *foundValue) != nil {
+				kademlia.KademliaNode.reportMisbehaviour(contactToQuery, "answered a FIND_DATA with a value that is not the hash of the key")
+				err = errors.New("found a value that does not match the key")
+			}
+
 			if err != nil {
 				queryFailedChannel <- err
 				return
 			}
 
-			if foundValue != "" {
+			if foundValue != nil {
 				foundValueChannel <- foundValue
 				return
 			}
@@ -305,7 +465,7 @@ func (kademlia *KademliaImplementation) getContactsToQuery(queriedContacts *[]Co
 	return contactsToQuery
 }
 
-func (kademlia *KademliaImplementation) lookupRound(lookupType LookupType, targetId *KademliaID, lookupCompleteChannel chan bool, lookupDataChannel chan string, stop *bool, previousClosestToTargetList []Contact, queriedContacts *[]Contact, closestToTargetList *[]Contact, lock *Lock) {
+func (kademlia *KademliaImplementation) lookupRound(lookupType LookupType, targetId *KademliaID, key *Key, lookupCompleteChannel chan bool, lookupDataChannel chan *Value, stop *bool, previousClosestToTargetList []Contact, queriedContacts *[]Contact, closestToTargetList *[]Contact, lock *Lock) {
 	contactsToQuery := kademlia.getContactsToQuery(queriedContacts, closestToTargetList, lock)
 	lock.mutex.Lock()
 	if *stop {
@@ -315,10 +475,10 @@ func (kademlia *KademliaImplementation) lookupRound(lookupType LookupType, targe
 	lock.mutex.Unlock()
 
 	foundContactsChannel := make(chan []Contact)
-	foundValueChannel := make(chan string)
+	foundValueChannel := make(chan *Value)
 	queryFailedChannel := make(chan error)
 
-	kademlia.queryAlphaContacts(lookupType, contactsToQuery, queriedContacts, *targetId, foundContactsChannel, foundValueChannel, queryFailedChannel, lock)
+	kademlia.queryAlphaContacts(lookupType, contactsToQuery, queriedContacts, *targetId, key, foundContactsChannel, foundValueChannel, queryFailedChannel, lock)
 	timesFailed := 0
 
 Loop:
@@ -330,7 +490,7 @@ Loop:
 			kClosest := kademlia.getKClosest(*closestToTargetList, foundContacts, targetId, NumberOfClosestNodesToRetrieved)
 			*closestToTargetList = kClosest
 
-			go kademlia.lookupRound(lookupType, targetId, lookupCompleteChannel, lookupDataChannel, stop, *closestToTargetList, queriedContacts, closestToTargetList, lock)
+			go kademlia.lookupRound(lookupType, targetId, key, lookupCompleteChannel, lookupDataChannel, stop, *closestToTargetList, queriedContacts, closestToTargetList, lock)
 			lock.mutex.Unlock()
 		case foundValue := <-foundValueChannel:
 			*stop = true
@@ -355,7 +515,9 @@ Loop:
 	}
 }
 
-func (kademlia *KademliaImplementation) lookup(lookupType LookupType, targetId *KademliaID) ([]Contact, string, error) {
+// lookup finds the k closest contacts to the target id. A LOOKUP_DATA also looks for the value of the key, which is nil
+// for a LOOKUP_CONTACT.
+func (kademlia *KademliaImplementation) lookup(lookupType LookupType, targetId *KademliaID, key *Key) ([]Contact, *Value, error) {
 
 	lock := &Lock{}
 	queriedContacts := new([]Contact)
@@ -365,13 +527,13 @@ func (kademlia *KademliaImplementation) lookup(lookupType LookupType, targetId *
 	closestToTargetList = &alphaClosest
 
 	lookupCompleteChannel := make(chan bool)
-	lookupDataChannel := make(chan string)
+	lookupDataChannel := make(chan *Value)
 
 	stop := false
 	stopPointer := &stop
 	for {
 		*stopPointer = false
-		go kademlia.lookupRound(lookupType, targetId, lookupCompleteChannel, lookupDataChannel, stopPointer, []Contact{}, queriedContacts, closestToTargetList, lock)
+		go kademlia.lookupRound(lookupType, targetId, key, lookupCompleteChannel, lookupDataChannel, stopPointer, []Contact{}, queriedContacts, closestToTargetList, lock)
 
 		select {
 		case <-lookupCompleteChannel:
@@ -390,7 +552,7 @@ func (kademlia *KademliaImplementation) lookup(lookupType LookupType, targetId *
 		foundContactsChannel := make(chan []Contact)
 		queryFailedChannel := make(chan error)
 
-		kademlia.queryAlphaContacts(lookupType, contactsToQuery, queriedContacts, *targetId, foundContactsChannel, nil, queryFailedChannel, lock)
+		kademlia.queryAlphaContacts(lookupType, contactsToQuery, queriedContacts, *targetId, key, foundContactsChannel, nil, queryFailedChannel, lock)
 
 		for i := 0; i < len(contactsToQuery); i++ {
 			select {
@@ -409,17 +571,73 @@ func (kademlia *KademliaImplementation) lookup(lookupType LookupType, targetId *
 	kClosest := *closestToTargetList
 	lock.mutex.Unlock()
 
-	return kClosest, "", nil
+	return kClosest, nil, nil
 
 }
 
 func (kademlia *KademliaImplementation) LookupContact(targetId *KademliaID) ([]Contact, error) {
-	kClosest, _, err := kademlia.lookup(LOOKUP_CONTACT, targetId)
+	kClosest, _, err := kademlia.lookup(LOOKUP_CONTACT, targetId, nil)
 	return kClosest, err
 }
 
-func (kademlia *KademliaImplementation) LookupData(key *Key) ([]Contact, string, error) {
-	kClosest, value, err := kademlia.lookup(LOOKUP_DATA, key.GetKademliaIdRepresentationOfKey())
+// LookupData returns the value of the key if it is found in the network, otherwise the value is nil and the k closest
+// contacts to the key are returned. Values outside the default namespace are mutable, for them the version the validator
+// of the namespace selects among the versions held by the k closest contacts is returned.
+func (kademlia *KademliaImplementation) LookupData(key *Key) ([]Contact, *Value, error) {
+	kClosest, value, err := kademlia.lookup(LOOKUP_DATA, key.GetKademliaIdRepresentationOfKey(), key)
+	if err == nil && value != nil && value.Namespace != DefaultNamespace {
+		value = kademlia.selectBestValue(key, value.Namespace, value)
+	}
 	return kClosest, value, err
 
 }
+
+// LookupDataInNamespace is LookupData for a key that only holds values in the namespace, such as the key of a record.
+// A value in another namespace is never returned, instead the k closest contacts to the key are asked for a version in
+// the namespace, and the value is nil if none of them holds one.
+func (kademlia *KademliaImplementation) LookupDataInNamespace(key *Key, namespace string) ([]Contact, *Value, error) {
+	kClosest, value, err := kademlia.lookup(LOOKUP_DATA, key.GetKademliaIdRepresentationOfKey(), key)
+	if err != nil || value == nil {
+		return kClosest, value, err
+	}
+	if value.Namespace != namespace || namespace != DefaultNamespace {
+		value = kademlia.selectBestValue(key, namespace, value)
+	}
+	return kClosest, value, nil
+}
+
+// selectBestValue asks the k closest contacts to the key for their version of the value, and returns the valid version
+// in the namespace the validator of the namespace selects, among them and the version the lookup found. It returns nil
+// if no version in the namespace is found.
+func (kademlia *KademliaImplementation) selectBestValue(key *Key, namespace string, found *Value) *Value {
+	validators := kademlia.KademliaNode.GetValidators()
+
+	values := []Value{}
+	if found.Namespace == namespace {
+		values = append(values, *found)
+	}
+	contacts, err := kademlia.LookupContact(key.GetKademliaIdRepresentationOfKey())
+	if err != nil {
+		contacts = nil
+	}
+	for _, contact := range contacts {
+		_, value, err := kademlia.Network.SendFindDataMessage(&kademlia.KademliaNode.GetRoutingTable().Me, &contact, key)
+		if err != nil || value == nil || value.Namespace != namespace {
+			continue
+		}
+		if validators.Validate(key, *value) != nil {
+			kademlia.KademliaNode.reportMisbehaviour(contact, "answered a FIND_DATA with an invalid value")
+			continue
+		}
+		values = append(values, *value)
+	}
+	if len(values) == 0 {
+		return nil
+	}
+
+	best, err := validators.Select(key, values)
+	if err != nil {
+		return &values[0]
+	}
+	return &values[best]
+}
@@ -1,27 +1,111 @@
 package kademlia
 
 import (
+	"time"
+
 	"github.com/arianfiftyone/src/logger"
 )
 
 const (
-	NumberOfClosestNodesToRetrieved = 3 // Must be atleast 3, otherwize some tests will fail
+	NumberOfClosestNodesToRetrieved = 3                     // Must be atleast 3, otherwize some tests will fail
+	HandOffInterval                 = time.Millisecond * 50 // Minimum time between two STOREs handing off data to new contacts
+
+	DefaultTTL    = time.Second * 10 // Time to live of a value stored without requesting one
+	DefaultMinTTL = time.Second
+	DefaultMaxTTL = time.Hour * 24
 )
 
 type KademliaNode interface {
 	setNetwork(network Network)
 	GetRoutingTable() *RoutingTable
-	GetDataStore() *DataStore
+	GetDataStore() DataStore
+	GetHashFunction() HashFunction
+	GetValidators() *ValidatorRegistry
+	GetWriteQuorum() int
+	GetPublisherRegistry() *PublisherRegistry
+	GetProviderStore() *ProviderStore
+	GetPubSub() *PubSub
 	updateRoutingTable(contact Contact)
+	clampTTL(ttl time.Duration) time.Duration
+	expirationTTL(key *Key, ttl time.Duration) time.Duration
+	storeValue(origin string, key *Key, value Value, ttl time.Duration) error
+	deleteValue(key *Key, token string, ttl time.Duration) error
+	sweepTombstones()
+	forwardTopicMessage(message TopicMessage) int
+	reportMisbehaviour(contact Contact, reason string)
 }
 
 type KademliaNodeImplementation struct {
-	Network      Network
-	RoutingTable *RoutingTable
-	DataStore    *DataStore
+	Network        Network
+	RoutingTable   *RoutingTable
+	DataStore      DataStore
+	handOffLimiter rateLimiter
+	minTTL         time.Duration
+	maxTTL         time.Duration // No upper bound if zero
+	hashFunction   HashFunction  // The default hash function if zero
+	writeQuorum    int           // The default write quorum if zero
+
+	storageQuota      StorageQuota
+	storageAccounting storageAccounting
+	misbehaviour      misbehaviourCounter
+	validators        ValidatorRegistry
+	tombstones        tombstoneSet
+	publisherRegistry PublisherRegistry
+	providerStore     ProviderStore
+	pubSub            PubSub
+}
+
+// KademliaNodeOption configures an optional part of a KademliaNodeImplementation.
+type KademliaNodeOption func(kademliaNode *KademliaNodeImplementation)
+
+// WithDataStore makes the node keep its values in the given DataStore backend instead of in memory.
+func WithDataStore(dataStore DataStore) KademliaNodeOption {
+	return func(kademliaNode *KademliaNodeImplementation) {
+		kademliaNode.DataStore = dataStore
+	}
+}
+
+// WithTTLBounds makes the node clamp the time to live requested for a value to the range [minTTL, maxTTL].
+func WithTTLBounds(minTTL time.Duration, maxTTL time.Duration) KademliaNodeOption {
+	return func(kademliaNode *KademliaNodeImplementation) {
+		kademliaNode.minTTL = minTTL
+		kademliaNode.maxTTL = maxTTL
+	}
+}
+
+// WithHashFunction makes the node hash the values it stores with the given hash function.
+func WithHashFunction(hashFunction HashFunction) KademliaNodeOption {
+	return func(kademliaNode *KademliaNodeImplementation) {
+		kademliaNode.hashFunction = hashFunction
+	}
+}
+
+// WithValidator makes the node validate the values of the namespace with the given validator.
+func WithValidator(namespace string, validator Validator) KademliaNodeOption {
+	return func(kademliaNode *KademliaNodeImplementation) {
+		kademliaNode.validators.Register(namespace, validator)
+	}
+}
+
+// WithWriteQuorum makes the node fail a store unless at least writeQuorum of the k closest contacts store the value.
+func WithWriteQuorum(writeQuorum int) KademliaNodeOption {
+	return func(kademliaNode *KademliaNodeImplementation) {
+		kademliaNode.writeQuorum = writeQuorum
+	}
 }
 
-func NewKademliaNode(ip string, port int, isBootstrap bool) *KademliaNodeImplementation {
+// WithPublisherRegistryFile makes the node save the values it publishes to the file and a directory of values next to
+// it, and continue publishing the values saved in them.
+func WithPublisherRegistryFile(path string) KademliaNodeOption {
+	return func(kademliaNode *KademliaNodeImplementation) {
+		err := kademliaNode.publisherRegistry.Load(path)
+		if err != nil {
+			logger.Log("Failed to load the publisher registry: " + err.Error())
+		}
+	}
+}
+
+func NewKademliaNode(ip string, port int, isBootstrap bool, options ...KademliaNodeOption) *KademliaNodeImplementation {
 	var routingTable *RoutingTable
 	var kademliaID KademliaID
 
@@ -43,13 +127,19 @@ func NewKademliaNode(ip string, port int, isBootstrap bool) *KademliaNodeImpleme
 	// Create a new RoutingTable instance and add the initial contact
 	routingTable = NewRoutingTable(contact)
 
-	// Create new DataStore instance
-	dataStore := NewDataStore()
-
-	return &KademliaNodeImplementation{
+	// Create new DataStore instance, unless another backend is given as an option
+	kademliaNode := &KademliaNodeImplementation{
 		RoutingTable: routingTable,
-		DataStore:    &dataStore,
+		DataStore:    NewInMemoryDataStore(),
+		minTTL:       DefaultMinTTL,
+		maxTTL:       DefaultMaxTTL,
+		storageQuota: DefaultStorageQuota(),
 	}
+	for _, option := range options {
+		option(kademliaNode)
+	}
+
+	return kademliaNode
 }
 
 func (kademliaNode *KademliaNodeImplementation) setNetwork(network Network) {
@@ -60,20 +150,80 @@ func (kademliaNode *KademliaNodeImplementation) GetRoutingTable() *RoutingTable
 	return kademliaNode.RoutingTable
 }
 
-func (kademliaNode *KademliaNodeImplementation) GetDataStore() *DataStore {
+func (kademliaNode *KademliaNodeImplementation) GetDataStore() DataStore {
 	return kademliaNode.DataStore
 }
 
+// GetHashFunction returns the hash function the node makes the keys of the values it stores with.
+func (kademliaNode *KademliaNodeImplementation) GetHashFunction() HashFunction {
+	if kademliaNode.hashFunction == 0 {
+		return DefaultHashFunction
+	}
+	return kademliaNode.hashFunction
+}
+
+// GetValidators returns the registry of the validators the node checks values with, per namespace.
+func (kademliaNode *KademliaNodeImplementation) GetValidators() *ValidatorRegistry {
+	return &kademliaNode.validators
+}
+
+// GetWriteQuorum returns how many replicas must acknowledge a STORE the node sends.
+func (kademliaNode *KademliaNodeImplementation) GetWriteQuorum() int {
+	if kademliaNode.writeQuorum <= 0 {
+		return DefaultWriteQuorum
+	}
+	return kademliaNode.writeQuorum
+}
+
+// GetPublisherRegistry returns the registry of the values the node maintains in the network.
+func (kademliaNode *KademliaNodeImplementation) GetPublisherRegistry() *PublisherRegistry {
+	return &kademliaNode.publisherRegistry
+}
+
+// GetProviderStore returns the provider records announced to the node.
+func (kademliaNode *KademliaNodeImplementation) GetProviderStore() *ProviderStore {
+	return &kademliaNode.providerStore
+}
+
+// GetPubSub returns the subscriptions the node keeps as a rendezvous point and as a subscriber.
+func (kademliaNode *KademliaNodeImplementation) GetPubSub() *PubSub {
+	return &kademliaNode.pubSub
+}
+
+// clampTTL returns the time to live the node grants for a requested one, a requested time to live of zero gets the default.
+func (kademliaNode *KademliaNodeImplementation) clampTTL(ttl time.Duration) time.Duration {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	if ttl < kademliaNode.minTTL {
+		ttl = kademliaNode.minTTL
+	}
+	if kademliaNode.maxTTL > 0 && ttl > kademliaNode.maxTTL {
+		ttl = kademliaNode.maxTTL
+	}
+	return ttl
+}
+
+func (kademliaNode *KademliaNodeImplementation) reportMisbehaviour(contact Contact, reason string) {
+	kademliaNode.misbehaviour.Report(contact, reason)
+}
+
+// GetMisbehaviourCount returns how often the node with the given id has been caught misbehaving.
+func (kademliaNode *KademliaNodeImplementation) GetMisbehaviourCount(id *KademliaID) int {
+	return kademliaNode.misbehaviour.GetCount(id)
+}
+
 func (kademliaNode *KademliaNodeImplementation) updateRoutingTable(contact Contact) {
 	if kademliaNode.Network == nil {
 		return
 	}
 
 	bucket := kademliaNode.RoutingTable.buckets[kademliaNode.RoutingTable.getBucketIndex(contact.ID)]
+	isNewContact := !bucket.Contains(contact) && !contact.ID.Equals(kademliaNode.RoutingTable.Me.ID)
 	if bucket.Len() < bucketSize {
 		kademliaNode.RoutingTable.AddContact(contact)
 
-	} else if !bucket.Contains(contact) {
+	} else if isNewContact {
 		lastContact := bucket.list.Back().Value.(Contact)
 
 		// Ping the last node in the bucket, replace if it does not respond otherwize do nothing
@@ -87,4 +237,37 @@ func (kademliaNode *KademliaNodeImplementation) updateRoutingTable(contact Conta
 		bucket.AddContact(contact)
 
 	}
+
+	if isNewContact {
+		go kademliaNode.handOffData(contact)
+	}
+}
+
+// handOffData stores every key in the data store at the new contact if it is now among the k closest to the key.
+// To avoid the same key being handed off by several nodes, a key is only sent if this node is closer to it than
+// every other known node among the k closest. The STOREs are rate limited so that a join does not cause a storm.
+func (kademliaNode *KademliaNodeImplementation) handOffData(contact Contact) {
+	me := kademliaNode.RoutingTable.Me
+
+	for _, item := range kademliaNode.DataStore.Items() {
+		target := item.Key.GetKademliaIdRepresentationOfKey()
+		me.CalcDistance(target)
+
+		isAmongClosest := false
+		isClosestSender := true
+		for _, closeContact := range kademliaNode.RoutingTable.FindClosestContacts(target, NumberOfClosestNodesToRetrieved) {
+			if closeContact.ID.Equals(contact.ID) {
+				isAmongClosest = true
+			} else if !closeContact.ID.Equals(me.ID) && closeContact.Less(&me) {
+				isClosestSender = false
+			}
+		}
+
+		ttl, ok := replicationTTL(kademliaNode, item)
+		if isAmongClosest && isClosestSender && ok {
+			kademliaNode.handOffLimiter.Wait(HandOffInterval)
+			logger.Log("Handing off the data object " + item.Key.GetHashString() + " to " + contact.Ip)
+			kademliaNode.Network.SendStoreMessage(&me, &contact, item.Key, item.Value, ttl)
+		}
+	}
 }
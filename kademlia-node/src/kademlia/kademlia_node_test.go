@@ -1,6 +1,7 @@
 package kademlia
 
 import (
+	"errors"
 	"fmt"
 	"testing"
 	"time"
@@ -42,17 +43,48 @@ func (network *NetworkMock) SendPingMessage(from *Contact, contact *Contact) err
 func (network *NetworkMock) SendFindContactMessage(from *Contact, contact *Contact, id *KademliaID) ([]Contact, error) {
 	return nil, nil
 }
-func (network *NetworkMock) SendFindDataMessage(from *Contact, contact *Contact, key *Key) ([]Contact, string, error) {
-	return nil, "", nil
+func (network *NetworkMock) SendFindDataMessage(from *Contact, contact *Contact, key *Key) ([]Contact, *Value, error) {
+	return nil, nil, nil
 }
-func (network *NetworkMock) SendStoreMessage(from *Contact, contact *Contact, key *Key, value string) bool {
-	return false
+func (network *NetworkMock) SendFindDataMessageWithTTL(from *Contact, contact *Contact, key *Key) (*Value, time.Duration, error) {
+	return nil, 0, nil
+}
+func (network *NetworkMock) SendStoreMessage(from *Contact, contact *Contact, key *Key, value Value, ttl time.Duration) error {
+	return errors.New("no answer")
 }
 
-func (network *NetworkMock) SendRefreshExpirationTimeMessage(from *Contact, contact *Contact, key *Key) bool {
+func (network *NetworkMock) SendRefreshExpirationTimeMessage(from *Contact, contact *Contact, key *Key, ttl time.Duration) bool {
 	return false
 }
 
+func (network *NetworkMock) SendAddProviderMessage(from *Contact, contact *Contact, key *Key, ttl time.Duration) error {
+	return errors.New("no answer")
+}
+
+func (network *NetworkMock) SendGetProvidersMessage(from *Contact, contact *Contact, key *Key) ([]Contact, error) {
+	return nil, errors.New("no answer")
+}
+
+func (network *NetworkMock) SendSubscribeMessage(from *Contact, contact *Contact, topic string, ttl time.Duration) error {
+	return errors.New("no answer")
+}
+
+func (network *NetworkMock) SendPublishMessage(from *Contact, contact *Contact, message TopicMessage) error {
+	return errors.New("no answer")
+}
+
+func (network *NetworkMock) SendDeliverMessage(from *Contact, contact *Contact, message TopicMessage) error {
+	return errors.New("no answer")
+}
+
+func (network *NetworkMock) SendSyncMessage(from *Contact, contact *Contact, summary SyncSummary) ([]SyncEntry, error) {
+	return nil, errors.New("no answer")
+}
+
+func (network *NetworkMock) SendDeleteMessage(from *Contact, contact *Contact, key *Key, token string, ttl time.Duration) error {
+	return errors.New("no answer")
+}
+
 func TestUpdateRoutingTableFullTable(t *testing.T) {
 	kademliaNode := NewKademliaNode("127.0.0.1", 3002, false)
 	kademliaNode.setNetwork(&NetworkMock{})
@@ -77,3 +109,60 @@ func TestUpdateRoutingTableFullTable(t *testing.T) {
 
 	assert.Equal(t, contact, bucket.list.Front().Value.(Contact))
 }
+
+func TestUpdateRoutingTableHandsOffData(t *testing.T) {
+	kademliaNode := NewKademliaNode("127.0.0.1", 3002, false)
+	kademliaNode.RoutingTable = NewRoutingTable(NewContact(GenerateNewKademliaID("0000000000000000000000000000000000000000"), "127.0.0.1", 3002))
+	network := &NetworkStoreMock{}
+	kademliaNode.setNetwork(network)
+
+	kademliaNode.RoutingTable.AddContact(NewContact(GenerateNewKademliaID("FFFFFFFF00000000000000000000000000000000"), "198.168.1.1", 5000))
+
+	key := GetKeyRepresentationOfKademliaId(GenerateNewKademliaID("0000000000000000000000000000000000000001"))
+	kademliaNode.DataStore.Insert(key, NewTextValue("value"), DefaultTTL)
+
+	contact := NewContact(GenerateNewKademliaID("0000000000000000000000000000000000000002"), "198.168.1.2", 5000)
+	kademliaNode.updateRoutingTable(contact)
+
+	time.Sleep(HandOffInterval * 2)
+
+	network.lock.Lock()
+	defer network.lock.Unlock()
+	assert.Equal(t, []Contact{contact}, network.stored)
+}
+
+func TestHandOffDataOnlyFromClosestNode(t *testing.T) {
+	kademliaNode := NewKademliaNode("127.0.0.1", 3002, false)
+	kademliaNode.RoutingTable = NewRoutingTable(NewContact(GenerateNewKademliaID("0000000000000000000000000000000000000000"), "127.0.0.1", 3002))
+	network := &NetworkStoreMock{}
+	kademliaNode.setNetwork(network)
+
+	// This contact is closer to the key than the node itself, so it is responsible for the hand off
+	kademliaNode.RoutingTable.AddContact(NewContact(GenerateNewKademliaID("0000000000000000000000000000000000000001"), "198.168.1.1", 5000))
+
+	key := GetKeyRepresentationOfKademliaId(GenerateNewKademliaID("0000000000000000000000000000000000000001"))
+	kademliaNode.DataStore.Insert(key, NewTextValue("value"), DefaultTTL)
+
+	contact := NewContact(GenerateNewKademliaID("0000000000000000000000000000000000000002"), "198.168.1.2", 5000)
+	kademliaNode.RoutingTable.AddContact(contact)
+	kademliaNode.handOffData(contact)
+
+	assert.Empty(t, network.stored)
+}
+
+func TestNewKademliaNodeWithDataStore(t *testing.T) {
+	dataStore := NewInMemoryDataStore()
+
+	kademliaNode := NewKademliaNode("127.0.0.1", 3002, false, WithDataStore(dataStore))
+
+	assert.Same(t, dataStore, kademliaNode.GetDataStore())
+}
+
+func TestClampTTL(t *testing.T) {
+	kademliaNode := NewKademliaNode("127.0.0.1", 3003, false, WithTTLBounds(time.Second*2, time.Minute))
+
+	assert.Equal(t, DefaultTTL, kademliaNode.clampTTL(0))
+	assert.Equal(t, time.Second*2, kademliaNode.clampTTL(time.Millisecond))
+	assert.Equal(t, time.Minute, kademliaNode.clampTTL(time.Hour))
+	assert.Equal(t, time.Second*30, kademliaNode.clampTTL(time.Second*30))
+}
@@ -29,10 +29,10 @@ func TestJoinWithBootstrapOnly(t *testing.T) {
 
 func CreateMockedJoinKademlia(kademliaID *KademliaID, ip string, port int, bootstrapContact *Contact) KademliaImplementation {
 	routingTable := NewRoutingTable(NewContact(kademliaID, ip, port))
-	dataStore := NewDataStore()
+	dataStore := NewInMemoryDataStore()
 	kademliaNode := &KademliaNodeImplementation{
 		RoutingTable: routingTable,
-		DataStore:    &dataStore,
+		DataStore:    dataStore,
 	}
 
 	network := &NetworkImplementation{
@@ -124,10 +124,10 @@ func TestLookupContact(t *testing.T) {
 
 func CreateMockedKademlia(kademliaID *KademliaID, ip string, port int) KademliaImplementation {
 	routingTable := NewRoutingTable(NewContact(kademliaID, ip, port))
-	dataStore := NewDataStore()
+	dataStore := NewInMemoryDataStore()
 	kademliaNode := &KademliaNodeImplementation{
 		RoutingTable: routingTable,
-		DataStore:    &dataStore,
+		DataStore:    dataStore,
 	}
 
 	network := &NetworkImplementation{
@@ -139,12 +139,10 @@ func CreateMockedKademlia(kademliaID *KademliaID, ip string, port int) KademliaI
 	}
 	kademliaNode.setNetwork(network)
 
-	ketToStopRefreshMap := make(map[[KeySize]byte]chan bool)
 	kademlia := KademliaImplementation{
-		Network:             network,
-		KademliaNode:        kademliaNode,
-		isBootstrap:         true,
-		keyToStopRefreshMap: ketToStopRefreshMap,
+		Network:      network,
+		KademliaNode: kademliaNode,
+		isBootstrap:  true,
 	}
 
 	return kademlia
@@ -281,16 +279,16 @@ func TestLookupDataFindsData(t *testing.T) {
 
 	bootstrap := CreateMockedKademlia(GenerateNewKademliaID("FFFFFFFF00000000000000000000000000000000"), "127.0.0.1", 10069)
 
+	value := "value"
+	key := NewKey(value)
+
 	kademlia1 := CreateMockedKademlia(GenerateNewKademliaID("0000000000000000000000000000000000000001"), "127.0.0.1", 10021)
-	kademlia2 := CreateMockedKademlia(GenerateNewKademliaID("0000000000000000000000000000000000000002"), "127.0.0.1", 10022)
+	kademlia2 := CreateMockedKademlia(key.GetKademliaIdRepresentationOfKey(), "127.0.0.1", 10022) // Sets kademlia2's id to be the same as the key
 
 	bootstrap.KademliaNode.GetRoutingTable().AddContact(kademlia1.KademliaNode.GetRoutingTable().Me)
 	kademlia1.KademliaNode.GetRoutingTable().AddContact(kademlia2.KademliaNode.GetRoutingTable().Me)
 
-	value := "value"
-	key := GetKeyRepresentationOfKademliaId(GenerateNewKademliaID("0000000000000000000000000000000000000002")) // Sets the key to be the same as kademlia2's id
-
-	kademlia2.KademliaNode.GetDataStore().Insert(key, value)
+	kademlia2.KademliaNode.GetDataStore().Insert(key, NewTextValue(value), DefaultTTL)
 
 	go bootstrap.Start()
 	go kademlia1.Start()
@@ -303,8 +301,37 @@ func TestLookupDataFindsData(t *testing.T) {
 
 	_, data, _ := kademlia.LookupData(key)
 
-	assert.Equal(t, value, data)
+	if assert.NotNil(t, data) {
+		assert.Equal(t, value, string(data.Data))
+	}
+
+}
+
+func TestLookupDataDiscardsMismatchingValue(t *testing.T) {
+
+	bootstrap := CreateMockedKademlia(GenerateNewKademliaID("FFFFFFFF00000000000000000000000000000000"), "127.0.0.1", 10041)
+
+	kademlia1 := CreateMockedKademlia(GenerateNewKademliaID("0000000000000000000000000000000000000001"), "127.0.0.1", 10042)
+
+	bootstrap.KademliaNode.GetRoutingTable().AddContact(kademlia1.KademliaNode.GetRoutingTable().Me)
+
+	// kademlia1 claims to have the value of the key, but answers with another value
+	key := NewKey("value")
+	kademlia1.KademliaNode.GetDataStore().Insert(key, NewTextValue("poisoned"), DefaultTTL)
+
+	go bootstrap.Start()
+	go kademlia1.Start()
+	time.Sleep(time.Second)
 
+	kademlia := NewKademlia("127.0.0.1", 4040, false, "", 0)
+
+	kademlia.KademliaNode.GetRoutingTable().AddContact(bootstrap.KademliaNode.GetRoutingTable().Me)
+
+	_, data, err := kademlia.LookupData(key)
+
+	assert.NoError(t, err)
+	assert.Nil(t, data)
+	assert.Equal(t, 1, kademlia.KademliaNode.(*KademliaNodeImplementation).GetMisbehaviourCount(kademlia1.KademliaNode.GetRoutingTable().Me.ID))
 }
 
 func TestLookupDataFindsNoData(t *testing.T) {
@@ -393,11 +420,12 @@ func TestStore(t *testing.T) {
 	kademlia.KademliaNode.GetRoutingTable().AddContact(bootstrap.KademliaNode.GetRoutingTable().Me)
 
 	content := "testy"
-	key, err := kademlia.Store(content)
+	result, err := kademlia.Store(NewTextValue(content), DefaultTTL)
 
 	if err != nil {
 		assert.Fail(t, err.Error())
 	}
+	key := result.Key
 
 	list, _ := kademlia.LookupContact(key.GetKademliaIdRepresentationOfKey())
 
@@ -415,7 +443,7 @@ func TestStore(t *testing.T) {
 					assert.Fail(t, err.Error())
 				}
 
-				assert.True(t, retrivedContent == content)
+				assert.True(t, string(retrivedContent.Data) == content)
 				break
 
 			}
@@ -505,11 +533,12 @@ func TestBig(t *testing.T) {
 	time.Sleep(time.Second)
 
 	content := "hello"
-	key, err := kademlias[len(kademlias)-1].Store(content)
+	result, err := kademlias[len(kademlias)-1].Store(NewTextValue(content), DefaultTTL)
 
 	if err != nil {
 		assert.Fail(t, err.Error())
 	}
+	key := result.Key
 
 	for _, kademlia := range kademlias {
 		contacts, retrivedContent, err := kademlia.LookupData(key)
@@ -529,7 +558,9 @@ func TestBig(t *testing.T) {
 			assert.Fail(t, err.Error())
 		}
 
-		assert.Equal(t, content, retrivedContent)
+		if assert.NotNil(t, retrivedContent) {
+			assert.Equal(t, content, string(retrivedContent.Data))
+		}
 
 	}
 
@@ -546,18 +577,19 @@ func TestRefresh(t *testing.T) {
 	kademlia.KademliaNode.GetRoutingTable().AddContact(bootstrap.KademliaNode.GetRoutingTable().Me)
 
 	content := "testy"
-	key, err := kademlia.Store(content)
+	result, err := kademlia.Store(NewTextValue(content), DefaultTTL)
 
 	if err != nil {
 		assert.Fail(t, err.Error())
 	}
+	key := result.Key
 
 	initTime, err := bootstrap.KademliaNode.GetDataStore().GetTime(key)
 	if err != nil {
 		t.Errorf("Expected no error, but got %v", err)
 	}
 
-	time.Sleep((bootstrap.KademliaNode.GetDataStore().ttl / 2) + time.Millisecond*100)
+	time.Sleep((DefaultTTL / 2) + time.Millisecond*100)
 
 	endTime, err := bootstrap.KademliaNode.GetDataStore().GetTime(key)
 	if err != nil {
@@ -566,7 +598,7 @@ func TestRefresh(t *testing.T) {
 
 	fmt.Println(initTime)
 	fmt.Println(endTime)
-	assert.Greater(t, endTime, initTime.Add(bootstrap.KademliaNode.GetDataStore().ttl/2))
+	assert.Greater(t, endTime, initTime.Add(DefaultTTL/2))
 }
 
 func TestStopRefresh(t *testing.T) {
@@ -580,19 +612,20 @@ func TestStopRefresh(t *testing.T) {
 	kademlia.KademliaNode.GetRoutingTable().AddContact(bootstrap.KademliaNode.GetRoutingTable().Me)
 
 	content := "testy"
-	key, err := kademlia.Store(content)
+	result, err := kademlia.Store(NewTextValue(content), DefaultTTL)
 
 	if err != nil {
 		assert.Fail(t, err.Error())
 	}
+	key := result.Key
 
 	initTime, err := bootstrap.KademliaNode.GetDataStore().GetTime(key)
 	if err != nil {
 		t.Errorf("Expected no error, but got %v", err)
 	}
-	kademlia.keyToStopRefreshMap[key.Hash] <- true
+	kademlia.Forget(key)
 
-	time.Sleep((bootstrap.KademliaNode.GetDataStore().ttl / 2) + time.Millisecond*100)
+	time.Sleep((DefaultTTL / 2) + time.Millisecond*100)
 
 	endTime, err := bootstrap.KademliaNode.GetDataStore().GetTime(key)
 	if err != nil {
@@ -616,20 +649,19 @@ func TestForget(t *testing.T) {
 	kademlia.KademliaNode.GetRoutingTable().AddContact(bootstrap.KademliaNode.GetRoutingTable().Me)
 
 	content := "testy"
-	key, err := kademlia.Store(content)
+	result, err := kademlia.Store(NewTextValue(content), DefaultTTL)
 
 	if err != nil {
 		assert.Fail(t, err.Error())
 	}
+	key := result.Key
 
 	err = kademlia.Forget(key)
 	if err != nil {
 		assert.Fail(t, err.Error())
 	}
 
-	time.Sleep((bootstrap.KademliaNode.GetDataStore().ttl / 2) + time.Millisecond*100)
-
-	expectedMap := map[[KeySize]byte]string{}
+	time.Sleep((DefaultTTL / 2) + time.Millisecond*100)
 
-	assert.Equal(t, expectedMap, kademlia.KademliaNode.GetDataStore().data)
+	assert.Empty(t, kademlia.KademliaNode.GetDataStore().Items())
 }
@@ -3,6 +3,7 @@ package kademlia
 import (
 	"encoding/hex"
 	"errors"
+	"math/bits"
 	"math/rand"
 
 	"github.com/arianfiftyone/src/logger"
@@ -98,6 +99,17 @@ func (kademliaID KademliaID) CalcDistance(target *KademliaID) *KademliaID {
 	return &result
 }
 
+// CommonPrefixLength returns how many leading bits kademliaID and target have in common
+func (kademliaID KademliaID) CommonPrefixLength(target *KademliaID) int {
+	distance := kademliaID.CalcDistance(target)
+	for i := 0; i < IDLength; i++ {
+		if distance[i] != 0 {
+			return i*8 + bits.LeadingZeros8(distance[i])
+		}
+	}
+	return IDLength * 8
+}
+
 // String returns a simple string representation of a KademliaID
 func (kademliaID *KademliaID) String() string {
 	return hex.EncodeToString(kademliaID[0:IDLength])
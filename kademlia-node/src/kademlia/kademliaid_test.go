@@ -47,3 +47,12 @@ func TestNewRandomKademliaidInRange2(t *testing.T) {
 	assert.True(t, lowerBound.Less(kademliaId) || lowerBound.Equals(kademliaId))
 
 }
+
+func TestCommonPrefixLength(t *testing.T) {
+	id := GenerateNewKademliaID("F000000000000000000000000000000000000000")
+
+	assert.Equal(t, 0, id.CommonPrefixLength(GenerateNewKademliaID("0000000000000000000000000000000000000000")))
+	assert.Equal(t, 5, id.CommonPrefixLength(GenerateNewKademliaID("F400000000000000000000000000000000000000")))
+	assert.Equal(t, 159, id.CommonPrefixLength(GenerateNewKademliaID("F000000000000000000000000000000000000001")))
+	assert.Equal(t, 160, id.CommonPrefixLength(id))
+}
@@ -1,33 +1,173 @@
 package kademlia
 
 import (
+	"bytes"
 	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
 	"encoding/hex"
+	"errors"
+
+	"golang.org/x/crypto/blake2b"
 )
 
 const (
 	KeySize = IDLength
 )
 
+// HashFunction is the multicodec code of a hash function, which is the prefix of a multihash.
+type HashFunction uint64
+
+const (
+	SHA1        HashFunction = 0x11
+	SHA2_256    HashFunction = 0x12
+	BLAKE2B_256 HashFunction = 0xb220
+)
+
+// DefaultHashFunction is the hash function of new keys.
+const DefaultHashFunction = SHA2_256
+
+var hashFunctions = map[HashFunction]func(data []byte) []byte{
+	SHA1: func(data []byte) []byte {
+		hash := sha1.Sum(data)
+		return hash[:]
+	},
+	SHA2_256: func(data []byte) []byte {
+		hash := sha256.Sum256(data)
+		return hash[:]
+	},
+	BLAKE2B_256: func(data []byte) []byte {
+		hash := blake2b.Sum256(data)
+		return hash[:]
+	},
+}
+
+var hashFunctionNames = map[HashFunction]string{
+	SHA1:        "sha1",
+	SHA2_256:    "sha2-256",
+	BLAKE2B_256: "blake2b-256",
+}
+
+// ParseHashFunction returns the hash function with the given multicodec name, for example sha2-256.
+func ParseHashFunction(name string) (HashFunction, error) {
+	for hashFunction, hashFunctionName := range hashFunctionNames {
+		if hashFunctionName == name {
+			return hashFunction, nil
+		}
+	}
+	return 0, errors.New("unsupported hash function " + name)
+}
+
+func (hashFunction HashFunction) String() string {
+	return hashFunctionNames[hashFunction]
+}
+
+// Key is the hash of a value. Hash is the position of the key in the ID space, which is the first IDLength bytes of
+// the digest. Multihash is the whole self-describing digest: a varint hash function code, a varint digest length and
+// the digest. SHA-1 keys have no Multihash, since their digest is exactly the Hash, so they stay the same as before
+// keys became multihashes.
 type Key struct {
-	Hash [KeySize]byte
+	Hash      [KeySize]byte
+	Multihash []byte `json:",omitempty"`
 }
 
+// NewKey returns the key of the value, hashed with the default hash function.
 func NewKey(value string) *Key {
-	bytes := []byte(value)
-	hash := sha1.Sum(bytes)
+	key, _ := NewKeyWithHashFunction([]byte(value), DefaultHashFunction)
+	return key
+}
+
+// NewKeyWithHashFunction returns the key of the data, hashed with the given hash function.
+func NewKeyWithHashFunction(data []byte, hashFunction HashFunction) (*Key, error) {
+	hash, ok := hashFunctions[hashFunction]
+	if !ok {
+		return nil, errors.New("unsupported hash function")
+	}
+	digest := hash(data)
+
+	if hashFunction == SHA1 {
+		key := &Key{}
+		copy(key.Hash[:], digest)
+		return key, nil
+	}
 
-	return &Key{
-		hash,
+	multihash := binary.AppendUvarint(nil, uint64(hashFunction))
+	multihash = binary.AppendUvarint(multihash, uint64(len(digest)))
+	multihash = append(multihash, digest...)
+	return newKeyFromMultihash(multihash)
+}
+
+// ParseKey parses a hash string, which is either the hex encoding of a multihash or the 40 hex characters of a
+// SHA-1 key.
+func ParseKey(hashString string) (*Key, error) {
+	decoded, err := hex.DecodeString(hashString)
+	if err != nil {
+		return nil, errors.New("the hash is not hex encoded")
+	}
+
+	if len(decoded) == KeySize {
+		key := &Key{}
+		copy(key.Hash[:], decoded)
+		return key, nil
+	}
+	return newKeyFromMultihash(decoded)
+}
+
+func newKeyFromMultihash(multihash []byte) (*Key, error) {
+	code, codeLength := binary.Uvarint(multihash)
+	if codeLength <= 0 {
+		return nil, errors.New("the multihash has no hash function code")
+	}
+	if _, ok := hashFunctions[HashFunction(code)]; !ok {
+		return nil, errors.New("unsupported hash function")
+	}
+	digestLength, lengthLength := binary.Uvarint(multihash[codeLength:])
+	if lengthLength <= 0 {
+		return nil, errors.New("the multihash has no digest length")
+	}
+	digest := multihash[codeLength+lengthLength:]
+	if uint64(len(digest)) != digestLength || len(digest) < KeySize {
+		return nil, errors.New("the multihash has the wrong digest length")
 	}
+
+	key := &Key{}
+	copy(key.Hash[:], digest)
+	if HashFunction(code) != SHA1 {
+		key.Multihash = append([]byte{}, multihash...)
+	}
+	return key, nil
+}
+
+// GetHashFunction returns the hash function the key was made with.
+func (key *Key) GetHashFunction() HashFunction {
+	if len(key.Multihash) == 0 {
+		return SHA1
+	}
+	code, _ := binary.Uvarint(key.Multihash)
+	return HashFunction(code)
+}
+
+// Matches reports whether the key is the hash of the data, comparing the whole digest and not only its position in
+// the ID space.
+func (key *Key) Matches(data []byte) bool {
+	other, err := NewKeyWithHashFunction(data, key.GetHashFunction())
+	return err == nil && other.Equals(key)
+}
+
+// Equals reports whether both keys are made with the same hash function and have the same digest.
+func (key *Key) Equals(other *Key) bool {
+	return key.Hash == other.Hash && bytes.Equal(key.Multihash, other.Multihash)
 }
 
 func (key *Key) GetHashString() string {
+	if len(key.Multihash) > 0 {
+		return hex.EncodeToString(key.Multihash)
+	}
 	return hex.EncodeToString(key.Hash[:])
 }
 
 func (key *Key) GetKademliaIdRepresentationOfKey() *KademliaID {
-	return GenerateNewKademliaID(key.GetHashString())
+	return GenerateNewKademliaID(hex.EncodeToString(key.Hash[:]))
 }
 
 func GetKeyRepresentationOfKademliaId(id *KademliaID) *Key {
@@ -0,0 +1,66 @@
+package kademlia
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewKeyIsSHA256Multihash(t *testing.T) {
+	key := NewKey("value")
+
+	assert.Equal(t, SHA2_256, key.GetHashFunction())
+	assert.Equal(t, "1220", key.GetHashString()[:4])
+	assert.Len(t, key.Multihash, 34)
+	// The position in the ID space is the start of the digest
+	assert.Equal(t, key.Multihash[2:2+KeySize], key.Hash[:])
+}
+
+func TestParseKeyRoundTrip(t *testing.T) {
+	for _, hashFunction := range []HashFunction{SHA1, SHA2_256, BLAKE2B_256} {
+		key, err := NewKeyWithHashFunction([]byte("value"), hashFunction)
+		assert.NoError(t, err)
+
+		parsed, err := ParseKey(key.GetHashString())
+		assert.NoError(t, err)
+		assert.Equal(t, key, parsed)
+		assert.Equal(t, hashFunction, parsed.GetHashFunction())
+		assert.True(t, parsed.Matches([]byte("value")))
+		assert.False(t, parsed.Matches([]byte("other value")))
+	}
+}
+
+func TestParseKeyLegacySHA1(t *testing.T) {
+	hash := sha1.Sum([]byte("value"))
+	legacy := hex.EncodeToString(hash[:])
+
+	key, err := ParseKey(legacy)
+
+	assert.NoError(t, err)
+	assert.Equal(t, legacy, key.GetHashString())
+	assert.Empty(t, key.Multihash)
+	assert.True(t, key.Matches([]byte("value")))
+
+	// A SHA-1 multihash is the same key as the legacy hash
+	multihash, err := ParseKey("1114" + legacy)
+	assert.NoError(t, err)
+	assert.Equal(t, key, multihash)
+}
+
+func TestParseKeyInvalid(t *testing.T) {
+	for _, hashString := range []string{"", "not hex", "1220abcd", "99" + NewKey("value").GetHashString()[2:], NewKey("value").GetHashString() + "00"} {
+		_, err := ParseKey(hashString)
+		assert.Error(t, err, hashString)
+	}
+}
+
+func TestParseHashFunction(t *testing.T) {
+	hashFunction, err := ParseHashFunction("blake2b-256")
+	assert.NoError(t, err)
+	assert.Equal(t, BLAKE2B_256, hashFunction)
+
+	_, err = ParseHashFunction("md5")
+	assert.Error(t, err)
+}
@@ -0,0 +1,87 @@
+package kademlia
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// KeyValueContentType is the content type of a Value holding a KeyValue.
+const KeyValueContentType = "application/vnd.kademlia.kv+json"
+
+// KeyValue is a value stored under a key chosen by the application, such as a user ID or a name, instead of the hash
+// of its data. Anyone may overwrite it: each Put raises the version, and of two values with the same version the one
+// created last wins.
+type KeyValue struct {
+	Key     string `json:"key"`
+	Version uint64 `json:"version"`
+	Value   Value  `json:"value"`
+}
+
+// NewKeyValueKey returns the key the value of the application key is stored under, which is the hash of the key in
+// the key-value namespace.
+func NewKeyValueKey(key string) *Key {
+	return NewKey(KeyValueNamespace + "/" + key)
+}
+
+// KeyValueFromValue decodes the key-value pair held by a value.
+func KeyValueFromValue(value Value) (KeyValue, error) {
+	var keyValue KeyValue
+	if value.ContentType != KeyValueContentType {
+		return keyValue, errors.New("the value is not a key-value pair")
+	}
+	err := json.Unmarshal(value.Data, &keyValue)
+	return keyValue, err
+}
+
+// GetKey returns the key the key-value pair is stored under.
+func (keyValue KeyValue) GetKey() *Key {
+	return NewKeyValueKey(keyValue.Key)
+}
+
+// ToValue encodes the key-value pair as a value that can be stored in the network.
+func (keyValue KeyValue) ToValue() (Value, error) {
+	data, err := json.Marshal(keyValue)
+	if err != nil {
+		return Value{}, err
+	}
+	value := NewValue(data, KeyValueContentType)
+	value.Namespace = KeyValueNamespace
+	return value, nil
+}
+
+// Put stores the value under the application key, and keeps refreshing it until it is forgotten. The version of the
+// value is one higher than the version found in the network, so it replaces the earlier value at every replica.
+func (kademlia *KademliaImplementation) Put(key string, value Value, ttl time.Duration) (*StoreResult, error) {
+	keyValue := KeyValue{Key: key, Version: 1, Value: value}
+	current, err := kademlia.Get(key)
+	if err == nil {
+		keyValue.Version = current.Version + 1
+	}
+
+	stored, err := keyValue.ToValue()
+	if err != nil {
+		return nil, err
+	}
+	result, err := kademlia.storeUnderKey(keyValue.GetKey(), stored, ttl)
+	if result != nil {
+		result.Version = keyValue.Version
+	}
+	return result, err
+}
+
+// Get returns the latest version of the value stored under the application key.
+func (kademlia *KademliaImplementation) Get(key string) (*KeyValue, error) {
+	_, value, err := kademlia.LookupDataInNamespace(NewKeyValueKey(key), KeyValueNamespace)
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		return nil, errors.New("the key was not found")
+	}
+	keyValue, err := KeyValueFromValue(*value)
+	if err != nil {
+		return nil, err
+	}
+	return &keyValue, nil
+}
@@ -0,0 +1,96 @@
+package kademlia
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// NetworkKeyValueMock answers a FIND_DATA with the value last stored at the contact.
+type NetworkKeyValueMock struct {
+	NetworkMock
+	lock   sync.Mutex
+	values map[int]Value
+}
+
+func (network *NetworkKeyValueMock) SendStoreMessage(from *Contact, contact *Contact, key *Key, value Value, ttl time.Duration) error {
+	network.lock.Lock()
+	defer network.lock.Unlock()
+	if network.values == nil {
+		network.values = make(map[int]Value)
+	}
+	network.values[contact.Port] = value
+	return nil
+}
+
+func (network *NetworkKeyValueMock) SendFindDataMessage(from *Contact, contact *Contact, key *Key) ([]Contact, *Value, error) {
+	network.lock.Lock()
+	defer network.lock.Unlock()
+	value, ok := network.values[contact.Port]
+	if !ok {
+		return nil, nil, nil
+	}
+	return nil, &value, nil
+}
+
+func TestPutAndGet(t *testing.T) {
+	kademlia := createQuorumTestKademlia(&NetworkKeyValueMock{})
+
+	result, err := kademlia.Put("user/42", NewTextValue("first"), DefaultTTL)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), result.Version)
+	assert.True(t, result.Key.Equals(NewKeyValueKey("user/42")))
+
+	keyValue, err := kademlia.Get("user/42")
+	assert.NoError(t, err)
+	assert.Equal(t, "user/42", keyValue.Key)
+	assert.Equal(t, []byte("first"), keyValue.Value.Data)
+
+	kademlia.Forget(result.Key)
+}
+
+func TestPutOverwritesWithHigherVersion(t *testing.T) {
+	kademlia := createQuorumTestKademlia(&NetworkKeyValueMock{})
+
+	kademlia.Put("name", NewTextValue("first"), DefaultTTL)
+	result, err := kademlia.Put("name", NewTextValue("second"), DefaultTTL)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), result.Version)
+
+	keyValue, err := kademlia.Get("name")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), keyValue.Version)
+	assert.Equal(t, []byte("second"), keyValue.Value.Data)
+
+	kademlia.Forget(result.Key)
+}
+
+func TestGetMissingKey(t *testing.T) {
+	kademlia := createQuorumTestKademlia(&NetworkKeyValueMock{})
+
+	_, err := kademlia.Get("missing")
+	assert.Error(t, err)
+}
+
+func TestKeyValueValidator(t *testing.T) {
+	validators := ValidatorRegistry{}
+	older, _ := KeyValue{Key: "name", Version: 1, Value: NewTextValue("older")}.ToValue()
+	newerValue := NewTextValue("newer")
+	newer, _ := KeyValue{Key: "name", Version: 2, Value: newerValue}.ToValue()
+	// Of two values with the same version, the one created last wins
+	concurrentValue := NewTextValue("concurrent")
+	concurrentValue.CreationTime = newerValue.CreationTime.Add(time.Second)
+	concurrent, _ := KeyValue{Key: "name", Version: 2, Value: concurrentValue}.ToValue()
+	key := NewKeyValueKey("name")
+
+	assert.NoError(t, validators.Validate(key, older))
+	assert.Error(t, validators.Validate(NewKeyValueKey("other"), older))
+	assert.Error(t, validators.Validate(key, NewTextValue("name")))
+
+	assert.NoError(t, validators.ValidateUpdate(key, older, newer))
+	assert.Error(t, validators.ValidateUpdate(key, newer, older))
+	assert.NoError(t, validators.ValidateUpdate(key, newer, concurrent))
+	assert.Error(t, validators.ValidateUpdate(key, concurrent, newer))
+}
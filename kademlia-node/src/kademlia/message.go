@@ -1,6 +1,9 @@
 package kademlia
 
-import "errors"
+import (
+	"errors"
+	"time"
+)
 
 type MessageType string
 
@@ -16,11 +19,25 @@ const (
 	FOUND_DATA                         MessageType = "FOUND_DATA"
 	REFRESH_EXPIRATION_TIME            MessageType = "REFRESH_EXPIRATION_TIME"
 	EXPIRATION_TIME_HAS_BEEN_REFRESHED MessageType = "EXPIRATION_TIME_HAS_BEEN_REFRESHED"
+	DELETE                             MessageType = "DELETE"
+	DELETE_RESPONSE                    MessageType = "DELETE_RESPONSE"
+	ADD_PROVIDER                       MessageType = "ADD_PROVIDER"
+	ADD_PROVIDER_RESPONSE              MessageType = "ADD_PROVIDER_RESPONSE"
+	GET_PROVIDERS                      MessageType = "GET_PROVIDERS"
+	PROVIDERS                          MessageType = "PROVIDERS"
+	SUBSCRIBE                          MessageType = "SUBSCRIBE"
+	SUBSCRIBE_RESPONSE                 MessageType = "SUBSCRIBE_RESPONSE"
+	PUBLISH                            MessageType = "PUBLISH"
+	PUBLISH_RESPONSE                   MessageType = "PUBLISH_RESPONSE"
+	DELIVER                            MessageType = "DELIVER"
+	DELIVER_RESPONSE                   MessageType = "DELIVER_RESPONSE"
+	SYNC                               MessageType = "SYNC"
+	SYNC_RESPONSE                      MessageType = "SYNC_RESPONSE"
 )
 
 func (messageType MessageType) IsValid() error {
 	switch messageType {
-	case ERROR, PING, PONG, FIND_NODE, FIND_DATA, STORE, STORE_RESPONSE, FOUND_CONTACTS, FOUND_DATA, REFRESH_EXPIRATION_TIME, EXPIRATION_TIME_HAS_BEEN_REFRESHED: // Add new messageTypes to the case, so it is seen as a valid type
+	case ERROR, PING, PONG, FIND_NODE, FIND_DATA, STORE, STORE_RESPONSE, FOUND_CONTACTS, FOUND_DATA, REFRESH_EXPIRATION_TIME, EXPIRATION_TIME_HAS_BEEN_REFRESHED, DELETE, DELETE_RESPONSE, ADD_PROVIDER, ADD_PROVIDER_RESPONSE, GET_PROVIDERS, PROVIDERS, SUBSCRIBE, SUBSCRIBE_RESPONSE, PUBLISH, PUBLISH_RESPONSE, DELIVER, DELIVER_RESPONSE, SYNC, SYNC_RESPONSE: // Add new messageTypes to the case, so it is seen as a valid type
 		return nil
 	}
 	return errors.New("Invalid message type")
@@ -108,10 +125,11 @@ func NewFindDataMessage(from Contact, key *Key) FindData {
 type Store struct {
 	Message
 	Key   *Key
-	Value string
+	Value Value
+	TTL   time.Duration `json:"ttl"` // The requested time to live, the receiving node clamps it to its own bounds
 }
 
-func NewStoreMessage(from Contact, key *Key, value string) Store {
+func NewStoreMessage(from Contact, key *Key, value Value, ttl time.Duration) Store {
 	message := Message{
 		MessageType: STORE,
 		From:        from,
@@ -121,12 +139,14 @@ func NewStoreMessage(from Contact, key *Key, value string) Store {
 		message,
 		key,
 		value,
+		ttl,
 	}
 }
 
 type StoreResponse struct {
 	Message
-	StoreSuccess bool `json:"storeSuccess"`
+	StoreSuccess bool   `json:"storeSuccess"`
+	Reason       string `json:"reason,omitempty"` // Why the value was refused if StoreSuccess is false
 }
 
 func NewStoreResponseMessage(from Contact) StoreResponse {
@@ -135,12 +155,25 @@ func NewStoreResponseMessage(from Contact) StoreResponse {
 		From:        from,
 	}
 
-	// change this value in message_handler
-	storeSuccess := true
+	return StoreResponse{
+		message,
+		true,
+		"",
+	}
+
+}
+
+// NewStoreRefusedResponseMessage creates a response to a STORE that was not stored, for the given reason.
+func NewStoreRefusedResponseMessage(from Contact, reason string) StoreResponse {
+	message := Message{
+		MessageType: STORE_RESPONSE,
+		From:        from,
+	}
 
 	return StoreResponse{
 		message,
-		storeSuccess,
+		false,
+		reason,
 	}
 
 }
@@ -166,19 +199,21 @@ func NewFoundContactsMessage(from Contact, contacts []Contact) FoundContacts {
 type FoundData struct {
 	Message
 	Contacts []Contact `json:"contacts"`
-	Value    string    `json:"value"`
+	Value    *Value    `json:"value,omitempty"` // Nil unless the value was found, a found value can be empty
+
+	TTL time.Duration `json:"ttl,omitempty"` // What is left of the lifetime of the found value at the contact
 }
 
-func NewFoundDataMessage(from Contact, contacts []Contact, value string) FoundData {
+func NewFoundDataMessage(from Contact, contacts []Contact, value *Value) FoundData {
 	message := Message{
 		MessageType: FOUND_DATA,
 		From:        from,
 	}
 
 	return FoundData{
-		message,
-		contacts,
-		value,
+		Message:  message,
+		Contacts: contacts,
+		Value:    value,
 	}
 
 }
@@ -186,9 +221,10 @@ func NewFoundDataMessage(from Contact, contacts []Contact, value string) FoundDa
 type RefreshExpirationTime struct {
 	Message
 	Key *Key
+	TTL time.Duration `json:"ttl"`
 }
 
-func NewRefreshExpirationTimeMessage(from Contact, key *Key) RefreshExpirationTime {
+func NewRefreshExpirationTimeMessage(from Contact, key *Key, ttl time.Duration) RefreshExpirationTime {
 	message := Message{
 		MessageType: REFRESH_EXPIRATION_TIME,
 		From:        from,
@@ -197,6 +233,7 @@ func NewRefreshExpirationTimeMessage(from Contact, key *Key) RefreshExpirationTi
 	return RefreshExpirationTime{
 		Message: message,
 		Key:     key,
+		TTL:     ttl,
 	}
 }
 
@@ -214,3 +251,301 @@ func NewExpirationTimeHasBeenRefreshedMessage(from Contact) ExpirationTimeHasBee
 		Message: message,
 	}
 }
+
+type Delete struct {
+	Message
+	Key   *Key
+	Token string        `json:"token"`         // The delete token the publisher got when it stored the value
+	TTL   time.Duration `json:"ttl,omitempty"` // The time to live the value was published with, zero if it is unknown
+}
+
+func NewDeleteMessage(from Contact, key *Key, token string, ttl time.Duration) Delete {
+	message := Message{
+		MessageType: DELETE,
+		From:        from,
+	}
+
+	return Delete{
+		message,
+		key,
+		token,
+		ttl,
+	}
+}
+
+type DeleteResponse struct {
+	Message
+	DeleteSuccess bool   `json:"deleteSuccess"`
+	Reason        string `json:"reason,omitempty"` // Why the value was not deleted if DeleteSuccess is false
+}
+
+func NewDeleteResponseMessage(from Contact) DeleteResponse {
+	message := Message{
+		MessageType: DELETE_RESPONSE,
+		From:        from,
+	}
+
+	return DeleteResponse{
+		message,
+		true,
+		"",
+	}
+}
+
+// NewDeleteRefusedResponseMessage creates a response to a DELETE that did not delete the value, for the given reason.
+func NewDeleteRefusedResponseMessage(from Contact, reason string) DeleteResponse {
+	message := Message{
+		MessageType: DELETE_RESPONSE,
+		From:        from,
+	}
+
+	return DeleteResponse{
+		message,
+		false,
+		reason,
+	}
+}
+
+// AddProvider announces that the sender provides the value of the key, without sending the value.
+type AddProvider struct {
+	Message
+	Key *Key
+	TTL time.Duration `json:"ttl"` // How long the record should be kept, the receiving node clamps it to its own bounds
+}
+
+func NewAddProviderMessage(from Contact, key *Key, ttl time.Duration) AddProvider {
+	message := Message{
+		MessageType: ADD_PROVIDER,
+		From:        from,
+	}
+
+	return AddProvider{
+		message,
+		key,
+		ttl,
+	}
+}
+
+type AddProviderResponse struct {
+	Message
+	AddSuccess bool   `json:"addSuccess"`
+	Reason     string `json:"reason,omitempty"` // Why the provider was not recorded if AddSuccess is false
+}
+
+func NewAddProviderResponseMessage(from Contact) AddProviderResponse {
+	message := Message{
+		MessageType: ADD_PROVIDER_RESPONSE,
+		From:        from,
+	}
+
+	return AddProviderResponse{
+		message,
+		true,
+		"",
+	}
+}
+
+// NewAddProviderRefusedResponseMessage creates a response to an ADD_PROVIDER that was not recorded, for the given reason.
+func NewAddProviderRefusedResponseMessage(from Contact, reason string) AddProviderResponse {
+	message := Message{
+		MessageType: ADD_PROVIDER_RESPONSE,
+		From:        from,
+	}
+
+	return AddProviderResponse{
+		message,
+		false,
+		reason,
+	}
+}
+
+type GetProviders struct {
+	Message
+	Key *Key
+}
+
+func NewGetProvidersMessage(from Contact, key *Key) GetProviders {
+	message := Message{
+		MessageType: GET_PROVIDERS,
+		From:        from,
+	}
+
+	return GetProviders{
+		message,
+		key,
+	}
+}
+
+type Providers struct {
+	Message
+	Providers []Contact `json:"providers"`
+}
+
+func NewProvidersMessage(from Contact, providers []Contact) Providers {
+	message := Message{
+		MessageType: PROVIDERS,
+		From:        from,
+	}
+
+	return Providers{
+		message,
+		providers,
+	}
+}
+
+// Subscribe asks a rendezvous point of the topic to forward the messages published to it to the sender.
+type Subscribe struct {
+	Message
+	Topic string        `json:"topic"`
+	TTL   time.Duration `json:"ttl"` // How long the subscription should be kept, the receiving node clamps it to its own bounds
+}
+
+func NewSubscribeMessage(from Contact, topic string, ttl time.Duration) Subscribe {
+	message := Message{
+		MessageType: SUBSCRIBE,
+		From:        from,
+	}
+
+	return Subscribe{
+		message,
+		topic,
+		ttl,
+	}
+}
+
+type SubscribeResponse struct {
+	Message
+	SubscribeSuccess bool   `json:"subscribeSuccess"`
+	Reason           string `json:"reason,omitempty"` // Why the subscription was refused if SubscribeSuccess is false
+}
+
+func NewSubscribeResponseMessage(from Contact) SubscribeResponse {
+	message := Message{
+		MessageType: SUBSCRIBE_RESPONSE,
+		From:        from,
+	}
+
+	return SubscribeResponse{
+		message,
+		true,
+		"",
+	}
+}
+
+// NewSubscribeRefusedResponseMessage creates a response to a SUBSCRIBE that was refused, for the given reason.
+func NewSubscribeRefusedResponseMessage(from Contact, reason string) SubscribeResponse {
+	message := Message{
+		MessageType: SUBSCRIBE_RESPONSE,
+		From:        from,
+	}
+
+	return SubscribeResponse{
+		message,
+		false,
+		reason,
+	}
+}
+
+// Publish asks a rendezvous point of the topic of the message to forward it to the subscribers of the topic.
+type Publish struct {
+	Message
+	TopicMessage TopicMessage `json:"topicMessage"`
+}
+
+func NewPublishMessage(from Contact, topicMessage TopicMessage) Publish {
+	message := Message{
+		MessageType: PUBLISH,
+		From:        from,
+	}
+
+	return Publish{
+		message,
+		topicMessage,
+	}
+}
+
+type PublishResponse struct {
+	Message
+	Subscribers int `json:"subscribers"` // How many subscribers the message is forwarded to
+}
+
+func NewPublishResponseMessage(from Contact, subscribers int) PublishResponse {
+	message := Message{
+		MessageType: PUBLISH_RESPONSE,
+		From:        from,
+	}
+
+	return PublishResponse{
+		message,
+		subscribers,
+	}
+}
+
+// Deliver passes a message published to a topic from a rendezvous point to a subscriber.
+type Deliver struct {
+	Message
+	TopicMessage TopicMessage `json:"topicMessage"`
+}
+
+func NewDeliverMessage(from Contact, topicMessage TopicMessage) Deliver {
+	message := Message{
+		MessageType: DELIVER,
+		From:        from,
+	}
+
+	return Deliver{
+		message,
+		topicMessage,
+	}
+}
+
+type DeliverResponse struct {
+	Message
+}
+
+func NewDeliverResponseMessage(from Contact) DeliverResponse {
+	message := Message{
+		MessageType: DELIVER_RESPONSE,
+		From:        from,
+	}
+
+	return DeliverResponse{
+		message,
+	}
+}
+
+// Sync sends a summary of the keys the sender and the receiver should both hold, so the receiver can list the keys in
+// the ranges where they differ.
+type Sync struct {
+	Message
+	Summary SyncSummary `json:"summary"`
+}
+
+func NewSyncMessage(from Contact, summary SyncSummary) Sync {
+	message := Message{
+		MessageType: SYNC,
+		From:        from,
+	}
+
+	return Sync{
+		message,
+		summary,
+	}
+}
+
+type SyncResponse struct {
+	Message
+	Entries []SyncEntry `json:"entries"` // The keys of the receiver in the ranges whose hashes differ
+}
+
+func NewSyncResponseMessage(from Contact, entries []SyncEntry) SyncResponse {
+	message := Message{
+		MessageType: SYNC_RESPONSE,
+		From:        from,
+	}
+
+	return SyncResponse{
+		message,
+		entries,
+	}
+}
@@ -1,21 +1,27 @@
 package kademlia
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"math/rand"
 	"strconv"
+	"time"
 
 	"github.com/arianfiftyone/src/logger"
 )
 
+// MessageHandler handles a message received from the given IP address, which is the address the datagram came from
+// rather than the one the message claims.
 type MessageHandler interface {
-	HandleMessage(rawMessage []byte) ([]byte, error)
+	HandleMessage(rawMessage []byte, senderIp string) ([]byte, error)
 }
 
 type MessageHandlerImplementation struct {
 	kademliaNode KademliaNode
 }
 
-func (messageHandler *MessageHandlerImplementation) HandleMessage(rawMessage []byte) ([]byte, error) {
+func (messageHandler *MessageHandlerImplementation) HandleMessage(rawMessage []byte, senderIp string) ([]byte, error) {
 	var message Message
 
 	err := json.Unmarshal(rawMessage, &message)
@@ -72,10 +78,11 @@ func (messageHandler *MessageHandlerImplementation) HandleMessage(rawMessage []b
 
 		logger.Log(findData.From.Ip + " wants to find a value.")
 
-		data, err := messageHandler.kademliaNode.GetDataStore().Get(findData.Key)
+		// Peeked, so that reads do not keep a replica alive past the lifetime its publisher gave it
+		data, err := messageHandler.kademliaNode.GetDataStore().Peek(findData.Key)
 		if err != nil {
 			closestKNodesList := messageHandler.kademliaNode.GetRoutingTable().FindClosestContacts(findData.Key.GetKademliaIdRepresentationOfKey(), NumberOfClosestNodesToRetrieved)
-			bytes, err := json.Marshal(NewFoundDataMessage(messageHandler.kademliaNode.GetRoutingTable().Me, closestKNodesList, ""))
+			bytes, err := json.Marshal(NewFoundDataMessage(messageHandler.kademliaNode.GetRoutingTable().Me, closestKNodesList, nil))
 			if err != nil {
 				logger.Log("Error when marshaling `closetsKNodesList`: " + err.Error())
 				return nil, err
@@ -83,7 +90,11 @@ func (messageHandler *MessageHandlerImplementation) HandleMessage(rawMessage []b
 			return bytes, nil
 
 		} else {
-			bytes, err := json.Marshal(NewFoundDataMessage(messageHandler.kademliaNode.GetRoutingTable().Me, nil, data))
+			foundData := NewFoundDataMessage(messageHandler.kademliaNode.GetRoutingTable().Me, nil, &data)
+			if expirationTime, err := messageHandler.kademliaNode.GetDataStore().GetTime(findData.Key); err == nil {
+				foundData.TTL = time.Until(expirationTime)
+			}
+			bytes, err := json.Marshal(foundData)
 			if err != nil {
 				logger.Log("Error when marshaling `data`: " + err.Error())
 				return nil, err
@@ -97,11 +108,32 @@ func (messageHandler *MessageHandlerImplementation) HandleMessage(rawMessage []b
 
 		json.Unmarshal(rawMessage, &store)
 
-		messageHandler.kademliaNode.GetDataStore().Insert(store.Key, store.Value)
-
 		logger.Log(store.From.Ip + " wants to to store an object at the K(=" + strconv.Itoa(NumberOfClosestNodesToRetrieved) + ") nodes nearest to the hash of the data object in question")
 
 		newStoreResponse := NewStoreResponseMessage(messageHandler.kademliaNode.GetRoutingTable().Me)
+		if store.Key == nil {
+			store.Key = &Key{} // Refused below, since no value hashes to the zero key
+		}
+		ttl := messageHandler.kademliaNode.expirationTTL(store.Key, store.TTL)
+		validators := messageHandler.kademliaNode.GetValidators()
+		err := validators.Validate(store.Key, store.Value)
+		if err != nil {
+			messageHandler.kademliaNode.reportMisbehaviour(store.From, "sent a STORE with an invalid value: "+err.Error())
+		} else if stored, getErr := messageHandler.kademliaNode.GetDataStore().Peek(store.Key); getErr == nil {
+			err = validators.ValidateUpdate(store.Key, stored, store.Value)
+			// Only the publisher that stored the data first may delete it
+			if stored.DeleteTokenHash != nil && bytes.Equal(stored.Data, store.Value.Data) {
+				store.Value.DeleteTokenHash = stored.DeleteTokenHash
+			}
+		}
+		if err == nil {
+			err = messageHandler.kademliaNode.storeValue(senderIp, store.Key, store.Value, ttl)
+		}
+		if err != nil {
+			logger.Log("Refused to store the data object " + store.Key.GetHashString() + ": " + err.Error())
+			newStoreResponse = NewStoreRefusedResponseMessage(messageHandler.kademliaNode.GetRoutingTable().Me, err.Error())
+		}
+
 		bytes, err := json.Marshal(newStoreResponse)
 		if err != nil {
 			logger.Log("Error when marshaling `newStoreResponse`: " + err.Error())
@@ -115,7 +147,11 @@ func (messageHandler *MessageHandlerImplementation) HandleMessage(rawMessage []b
 
 		json.Unmarshal(rawMessage, &refreshExpirationTime)
 
-		err := messageHandler.kademliaNode.GetDataStore().RefreshExpirationTime(refreshExpirationTime.Key)
+		if refreshExpirationTime.Key == nil {
+			return nil, errors.New("the refresh has no key")
+		}
+		ttl := messageHandler.kademliaNode.expirationTTL(refreshExpirationTime.Key, refreshExpirationTime.TTL)
+		err := messageHandler.kademliaNode.GetDataStore().RefreshExpirationTime(refreshExpirationTime.Key, ttl)
 		if err != nil {
 			return nil, err
 		}
@@ -123,6 +159,164 @@ func (messageHandler *MessageHandlerImplementation) HandleMessage(rawMessage []b
 		bytes, err := json.Marshal(expirationTimeHasBeenRefreshed)
 		return bytes, nil
 
+	case DELETE:
+		var deleteMessage Delete
+
+		json.Unmarshal(rawMessage, &deleteMessage)
+
+		logger.Log(deleteMessage.From.Ip + " wants to delete an object")
+
+		deleteResponse := NewDeleteResponseMessage(messageHandler.kademliaNode.GetRoutingTable().Me)
+		if deleteMessage.Key == nil {
+			deleteMessage.Key = &Key{}
+		}
+		err := messageHandler.kademliaNode.deleteValue(deleteMessage.Key, deleteMessage.Token, deleteMessage.TTL)
+		if err != nil {
+			logger.Log("Refused to delete the data object " + deleteMessage.Key.GetHashString() + ": " + err.Error())
+			deleteResponse = NewDeleteRefusedResponseMessage(messageHandler.kademliaNode.GetRoutingTable().Me, err.Error())
+		}
+
+		bytes, err := json.Marshal(deleteResponse)
+		if err != nil {
+			logger.Log("Error when marshaling `deleteResponse`: " + err.Error())
+			return nil, err
+		}
+
+		return bytes, nil
+
+	case ADD_PROVIDER:
+		var addProvider AddProvider
+
+		json.Unmarshal(rawMessage, &addProvider)
+
+		logger.Log(addProvider.From.Ip + " announces that it provides an object")
+
+		if addProvider.Key == nil || addProvider.From.ID == nil {
+			return nil, errors.New("the provider record has no key or provider")
+		}
+		addProviderResponse := NewAddProviderResponseMessage(messageHandler.kademliaNode.GetRoutingTable().Me)
+		// Only the sender may announce itself, so the provider is reached at the address the message came from
+		err := errors.New("the provider is not the sender")
+		if addProvider.From.Ip == senderIp {
+			ttl := messageHandler.kademliaNode.clampTTL(addProvider.TTL)
+			err = messageHandler.kademliaNode.GetProviderStore().Add(Provider{
+				Key:        addProvider.Key,
+				Contact:    NewContact(addProvider.From.ID, senderIp, addProvider.From.Port),
+				TTL:        ttl,
+				Expiration: time.Now().Add(ttl),
+			})
+		}
+		if err != nil {
+			logger.Log("Refused to record the provider of " + addProvider.Key.GetHashString() + ": " + err.Error())
+			addProviderResponse = NewAddProviderRefusedResponseMessage(messageHandler.kademliaNode.GetRoutingTable().Me, err.Error())
+		}
+
+		bytes, err := json.Marshal(addProviderResponse)
+		if err != nil {
+			logger.Log("Error when marshaling `addProviderResponse`: " + err.Error())
+			return nil, err
+		}
+
+		return bytes, nil
+
+	case GET_PROVIDERS:
+		var getProviders GetProviders
+
+		json.Unmarshal(rawMessage, &getProviders)
+
+		logger.Log(getProviders.From.Ip + " wants to find the providers of an object")
+
+		if getProviders.Key == nil {
+			return nil, errors.New("the request for providers has no key")
+		}
+		providers := messageHandler.kademliaNode.GetProviderStore().GetProviders(getProviders.Key)
+		bytes, err := json.Marshal(NewProvidersMessage(messageHandler.kademliaNode.GetRoutingTable().Me, providers))
+		if err != nil {
+			logger.Log("Error when marshaling `providers`: " + err.Error())
+			return nil, err
+		}
+
+		return bytes, nil
+
+	case SUBSCRIBE:
+		var subscribe Subscribe
+
+		json.Unmarshal(rawMessage, &subscribe)
+
+		logger.Log(subscribe.From.Ip + " wants to subscribe to the topic " + subscribe.Topic)
+
+		if subscribe.From.ID == nil {
+			return nil, errors.New("the subscription has no subscriber")
+		}
+		subscribeResponse := NewSubscribeResponseMessage(messageHandler.kademliaNode.GetRoutingTable().Me)
+		// Only the sender may subscribe itself, so the messages are delivered to the address the SUBSCRIBE came from
+		err := errors.New("the subscriber is not the sender")
+		if subscribe.From.Ip == senderIp {
+			ttl := messageHandler.kademliaNode.clampTTL(subscribe.TTL)
+			err = messageHandler.kademliaNode.GetPubSub().AddSubscriber(subscribe.Topic, NewContact(subscribe.From.ID, senderIp, subscribe.From.Port), ttl)
+		}
+		if err != nil {
+			logger.Log("Refused the subscription to the topic " + subscribe.Topic + ": " + err.Error())
+			subscribeResponse = NewSubscribeRefusedResponseMessage(messageHandler.kademliaNode.GetRoutingTable().Me, err.Error())
+		}
+
+		bytes, err := json.Marshal(subscribeResponse)
+		if err != nil {
+			logger.Log("Error when marshaling `subscribeResponse`: " + err.Error())
+			return nil, err
+		}
+
+		return bytes, nil
+
+	case PUBLISH:
+		var publish Publish
+
+		json.Unmarshal(rawMessage, &publish)
+
+		logger.Log(publish.From.Ip + " publishes a message to the topic " + publish.TopicMessage.Topic)
+
+		subscribers := messageHandler.kademliaNode.forwardTopicMessage(publish.TopicMessage)
+		bytes, err := json.Marshal(NewPublishResponseMessage(messageHandler.kademliaNode.GetRoutingTable().Me, subscribers))
+		if err != nil {
+			logger.Log("Error when marshaling `publishResponse`: " + err.Error())
+			return nil, err
+		}
+
+		return bytes, nil
+
+	case DELIVER:
+		var deliver Deliver
+
+		json.Unmarshal(rawMessage, &deliver)
+
+		messageHandler.kademliaNode.GetPubSub().Deliver(deliver.TopicMessage)
+		bytes, err := json.Marshal(NewDeliverResponseMessage(messageHandler.kademliaNode.GetRoutingTable().Me))
+		if err != nil {
+			logger.Log("Error when marshaling `deliverResponse`: " + err.Error())
+			return nil, err
+		}
+
+		return bytes, nil
+
+	case SYNC:
+		var sync Sync
+
+		json.Unmarshal(rawMessage, &sync)
+
+		if sync.From.ID == nil {
+			return nil, errors.New("the summary has no sender")
+		}
+		entries := sharedEntries(messageHandler.kademliaNode, sync.From)
+		// A random start lists other entries in each response when more differ than fit in one
+		differing := differingEntries(entries, NewSyncSummary(entries), sync.Summary, rand.Int())
+		bytes, err := json.Marshal(NewSyncResponseMessage(messageHandler.kademliaNode.GetRoutingTable().Me, differing))
+		if err != nil {
+			logger.Log("Error when marshaling `syncResponse`: " + err.Error())
+			return nil, err
+		}
+
+		return bytes, nil
+
 	default:
 		errorMessage := NewErrorMessage(messageHandler.kademliaNode.GetRoutingTable().Me)
 		bytes, err := json.Marshal(errorMessage)
@@ -2,14 +2,19 @@ package kademlia
 
 import (
 	"encoding/json"
+	"fmt"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
 type KademliaNodeMock struct {
-	me        *Contact
-	DataStore *DataStore
+	me            *Contact
+	DataStore     DataStore
+	providerStore ProviderStore
+	pubSub        PubSub
 }
 
 func (kademliaNode *KademliaNodeMock) setNetwork(network Network) {
@@ -20,14 +25,65 @@ func (kademliaNode *KademliaNodeMock) GetRoutingTable() *RoutingTable {
 	return NewRoutingTable(*kademliaNode.me)
 }
 
-func (kademliaNode *KademliaNodeMock) GetDataStore() *DataStore {
+func (kademliaNode *KademliaNodeMock) GetDataStore() DataStore {
 	return kademliaNode.DataStore
 }
 
+func (kademliaNode *KademliaNodeMock) GetHashFunction() HashFunction {
+	return DefaultHashFunction
+}
+
+func (kademliaNode *KademliaNodeMock) GetValidators() *ValidatorRegistry {
+	return &ValidatorRegistry{}
+}
+
+func (kademliaNode *KademliaNodeMock) GetWriteQuorum() int {
+	return DefaultWriteQuorum
+}
+
+func (kademliaNode *KademliaNodeMock) GetPublisherRegistry() *PublisherRegistry {
+	return &PublisherRegistry{}
+}
+
+func (kademliaNode *KademliaNodeMock) GetProviderStore() *ProviderStore {
+	return &kademliaNode.providerStore
+}
+
+func (kademliaNode *KademliaNodeMock) GetPubSub() *PubSub {
+	return &kademliaNode.pubSub
+}
+
+func (kademliaNode *KademliaNodeMock) forwardTopicMessage(message TopicMessage) int {
+	return len(kademliaNode.pubSub.GetSubscribers(message.Topic))
+}
+
 func (kademliaNode *KademliaNodeMock) updateRoutingTable(contact Contact) {
 
 }
 
+func (kademliaNode *KademliaNodeMock) clampTTL(ttl time.Duration) time.Duration {
+	return ttl
+}
+
+func (kademliaNode *KademliaNodeMock) expirationTTL(key *Key, ttl time.Duration) time.Duration {
+	return ttl
+}
+
+func (kademliaNode *KademliaNodeMock) reportMisbehaviour(contact Contact, reason string) {
+
+}
+
+func (kademliaNode *KademliaNodeMock) storeValue(origin string, key *Key, value Value, ttl time.Duration) error {
+	kademliaNode.DataStore.Insert(key, value, ttl)
+	return nil
+}
+
+func (kademliaNode *KademliaNodeMock) deleteValue(key *Key, token string, ttl time.Duration) error {
+	return kademliaNode.DataStore.Delete(key)
+}
+
+func (kademliaNode *KademliaNodeMock) sweepTombstones() {}
+
 func TestPongMessage(t *testing.T) {
 	contact := NewContact(NewRandomKademliaID(), "127.0.0.1", 80)
 	messageHandler := &MessageHandlerImplementation{
@@ -41,7 +97,7 @@ func TestPongMessage(t *testing.T) {
 	if err != nil {
 		assert.Fail(t, err.Error())
 	}
-	response, err := messageHandler.HandleMessage(bytes)
+	response, err := messageHandler.HandleMessage(bytes, "127.0.0.1")
 	if err != nil {
 		assert.Fail(t, err.Error())
 	}
@@ -67,7 +123,7 @@ func TestPingMessage(t *testing.T) {
 	if err != nil {
 		assert.Fail(t, err.Error())
 	}
-	response, err := messageHandler.HandleMessage(bytes)
+	response, err := messageHandler.HandleMessage(bytes, "127.0.0.1")
 	if err != nil {
 		assert.Fail(t, err.Error())
 	}
@@ -94,7 +150,7 @@ func TestFindNodeMessage(t *testing.T) {
 	if err != nil {
 		assert.Fail(t, err.Error())
 	}
-	response, err := messageHandler.HandleMessage(bytes)
+	response, err := messageHandler.HandleMessage(bytes, "127.0.0.1")
 	if err != nil {
 		assert.Fail(t, err.Error())
 	}
@@ -112,7 +168,7 @@ func TestFindDataMessage(t *testing.T) {
 	messageHandler := &MessageHandlerImplementation{
 		kademliaNode: &KademliaNodeMock{
 			me:        &contact,
-			DataStore: &DataStore{},
+			DataStore: &InMemoryDataStore{},
 		},
 	}
 
@@ -122,7 +178,7 @@ func TestFindDataMessage(t *testing.T) {
 	if err != nil {
 		assert.Fail(t, err.Error())
 	}
-	response, err := messageHandler.HandleMessage(bytes)
+	response, err := messageHandler.HandleMessage(bytes, "127.0.0.1")
 	if err != nil {
 		assert.Fail(t, err.Error())
 	}
@@ -139,14 +195,15 @@ func TestFindDataMessageDataExists(t *testing.T) {
 	contact := NewContact(NewRandomKademliaID(), "127.0.0.1", 80)
 
 	target := NewRandomKademliaID()
-	dataStore := NewDataStore()
+	dataStore := NewInMemoryDataStore()
 	value := "test"
-	dataStore.Insert(GetKeyRepresentationOfKademliaId(target), value)
+	dataStore.Insert(GetKeyRepresentationOfKademliaId(target), NewTextValue(value), DefaultTTL)
+	expirationTime, _ := dataStore.GetTime(GetKeyRepresentationOfKademliaId(target))
 
 	messageHandler := &MessageHandlerImplementation{
 		kademliaNode: &KademliaNodeMock{
 			me:        &contact,
-			DataStore: &dataStore,
+			DataStore: dataStore,
 		},
 	}
 
@@ -155,7 +212,7 @@ func TestFindDataMessageDataExists(t *testing.T) {
 	if err != nil {
 		assert.Fail(t, err.Error())
 	}
-	response, err := messageHandler.HandleMessage(bytes)
+	response, err := messageHandler.HandleMessage(bytes, "127.0.0.1")
 	if err != nil {
 		assert.Fail(t, err.Error())
 	}
@@ -173,29 +230,35 @@ func TestFindDataMessageDataExists(t *testing.T) {
 
 	}
 
-	assert.Equal(t, value, data.Value)
+	if assert.NotNil(t, data.Value) {
+		assert.Equal(t, value, string(data.Value.Data))
+	}
+	assert.True(t, data.TTL > 0 && data.TTL <= DefaultTTL)
+
+	// Reading a replica does not extend its lifetime
+	readExpirationTime, _ := dataStore.GetTime(GetKeyRepresentationOfKademliaId(target))
+	assert.Equal(t, expirationTime, readExpirationTime)
 
 }
 
 func TestStoreMessage(t *testing.T) {
 	contact := NewContact(NewRandomKademliaID(), "127.0.0.1", 80)
-	dataStore := NewDataStore()
+	dataStore := NewInMemoryDataStore()
 	value := "test"
 
 	messageHandler := &MessageHandlerImplementation{
 		kademliaNode: &KademliaNodeMock{
 			me:        &contact,
-			DataStore: &dataStore,
+			DataStore: dataStore,
 		},
 	}
 
-	target := NewRandomKademliaID()
-	store := NewStoreMessage(NewContact(NewRandomKademliaID(), "127.0.0.1", 80), GetKeyRepresentationOfKademliaId(target), value)
+	store := NewStoreMessage(NewContact(NewRandomKademliaID(), "127.0.0.1", 80), NewKey(value), NewTextValue(value), DefaultTTL)
 	bytes, err := json.Marshal(store)
 	if err != nil {
 		assert.Fail(t, err.Error())
 	}
-	response, err := messageHandler.HandleMessage(bytes)
+	response, err := messageHandler.HandleMessage(bytes, "127.0.0.1")
 	if err != nil {
 		assert.Fail(t, err.Error())
 	}
@@ -210,24 +273,24 @@ func TestStoreMessage(t *testing.T) {
 
 func TestRefreshExpirationTimeMessage(t *testing.T) {
 	contact := NewContact(NewRandomKademliaID(), "127.0.0.1", 80)
-	dataStore := NewDataStore()
+	dataStore := NewInMemoryDataStore()
 	value := "test"
 	key := NewKey(value)
-	dataStore.Insert(key, value)
+	dataStore.Insert(key, NewTextValue(value), DefaultTTL)
 
 	messageHandler := &MessageHandlerImplementation{
 		kademliaNode: &KademliaNodeMock{
 			me:        &contact,
-			DataStore: &dataStore,
+			DataStore: dataStore,
 		},
 	}
 
-	refresh := NewRefreshExpirationTimeMessage(NewContact(NewRandomKademliaID(), "127.0.0.1", 80), key)
+	refresh := NewRefreshExpirationTimeMessage(NewContact(NewRandomKademliaID(), "127.0.0.1", 80), key, DefaultTTL)
 	bytes, err := json.Marshal(refresh)
 	if err != nil {
 		assert.Fail(t, err.Error())
 	}
-	response, err := messageHandler.HandleMessage(bytes)
+	response, err := messageHandler.HandleMessage(bytes, "127.0.0.1")
 	if err != nil {
 		assert.Fail(t, err.Error())
 	}
@@ -239,3 +302,95 @@ func TestRefreshExpirationTimeMessage(t *testing.T) {
 	assert.Equal(t, EXPIRATION_TIME_HAS_BEEN_REFRESHED, message.MessageType)
 
 }
+
+// TestConcurrentStoreAndFindDataMessages is meant to be run with -race.
+func TestConcurrentStoreAndFindDataMessages(t *testing.T) {
+	messageHandler := &MessageHandlerImplementation{
+		kademliaNode: NewKademliaNode("127.0.0.1", 3005, false),
+	}
+
+	var waitGroup sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		waitGroup.Add(2)
+		value := fmt.Sprint("test", i%5)
+		from := NewContact(NewRandomKademliaID(), "127.0.0.1", 80)
+
+		go func() {
+			defer waitGroup.Done()
+			bytes, _ := json.Marshal(NewStoreMessage(from, NewKey(value), NewTextValue(value), DefaultTTL))
+			_, err := messageHandler.HandleMessage(bytes, "127.0.0.1")
+			assert.NoError(t, err)
+		}()
+		go func() {
+			defer waitGroup.Done()
+			bytes, _ := json.Marshal(NewFindDataMessage(from, NewKey(value)))
+			_, err := messageHandler.HandleMessage(bytes, "127.0.0.1")
+			assert.NoError(t, err)
+		}()
+	}
+	waitGroup.Wait()
+
+	assert.Equal(t, 5, messageHandler.kademliaNode.GetDataStore().Stats().Keys)
+}
+
+func TestFindDataMessageEmptyValue(t *testing.T) {
+	contact := NewContact(NewRandomKademliaID(), "127.0.0.1", 80)
+	dataStore := NewInMemoryDataStore()
+	value := NewValue([]byte{}, BinaryContentType)
+	dataStore.Insert(value.GetKey(), value, DefaultTTL)
+
+	messageHandler := &MessageHandlerImplementation{
+		kademliaNode: &KademliaNodeMock{
+			me:        &contact,
+			DataStore: dataStore,
+		},
+	}
+
+	findData := NewFindDataMessage(NewContact(NewRandomKademliaID(), "127.0.0.1", 80), value.GetKey())
+	bytes, err := json.Marshal(findData)
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+	response, err := messageHandler.HandleMessage(bytes, "127.0.0.1")
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+
+	var data FoundData
+	errUnmarshal := json.Unmarshal(response, &data)
+	if errUnmarshal != nil {
+		assert.Fail(t, errUnmarshal.Error())
+	}
+
+	if assert.NotNil(t, data.Value) {
+		assert.Empty(t, data.Value.Data)
+		assert.Equal(t, 0, data.Value.Size)
+	}
+}
+
+func TestStoreMessageWithMismatchingKey(t *testing.T) {
+	kademliaNode := NewKademliaNode("127.0.0.1", 3006, false)
+	messageHandler := &MessageHandlerImplementation{
+		kademliaNode: kademliaNode,
+	}
+
+	from := NewContact(NewRandomKademliaID(), "127.0.0.1", 80)
+	store := NewStoreMessage(from, NewKey("value"), NewTextValue("poisoned"), DefaultTTL)
+	bytes, err := json.Marshal(store)
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+	response, err := messageHandler.HandleMessage(bytes, "127.0.0.1")
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+
+	var storeResponse StoreResponse
+	errUnmarshal := json.Unmarshal(response, &storeResponse)
+	if errUnmarshal != nil {
+		assert.Fail(t, errUnmarshal.Error())
+	}
+	assert.False(t, storeResponse.StoreSuccess)
+	assert.Empty(t, kademliaNode.GetDataStore().Items())
+	assert.Equal(t, 1, kademliaNode.GetMisbehaviourCount(from.ID))
+}
@@ -0,0 +1,35 @@
+package kademlia
+
+import (
+	"strconv"
+	"sync"
+
+	"github.com/arianfiftyone/src/logger"
+)
+
+// misbehaviourCounter counts how often each node has been caught misbehaving, for example by sending a value that
+// does not match its key. The zero value is ready to use.
+type misbehaviourCounter struct {
+	lock   sync.Mutex
+	counts map[KademliaID]int
+}
+
+// Report adds one to the misbehaviour count of the contact.
+func (counter *misbehaviourCounter) Report(contact Contact, reason string) {
+	counter.lock.Lock()
+	defer counter.lock.Unlock()
+
+	if counter.counts == nil {
+		counter.counts = make(map[KademliaID]int)
+	}
+	counter.counts[*contact.ID]++
+	logger.Log(contact.Ip + ":" + strconv.Itoa(contact.Port) + " misbehaved (" + strconv.Itoa(counter.counts[*contact.ID]) + " times): " + reason)
+}
+
+// GetCount returns how often the node with the given id has been reported.
+func (counter *misbehaviourCounter) GetCount(id *KademliaID) int {
+	counter.lock.Lock()
+	defer counter.lock.Unlock()
+
+	return counter.counts[*id]
+}
@@ -10,14 +10,26 @@ import (
 	"github.com/arianfiftyone/src/logger"
 )
 
+const (
+	MaxMessageSize = 65507 // The largest payload of a UDP datagram over IPv4, a message must fit in one datagram
+)
+
 type Network interface {
 	Listen() error
 	Send(ip string, port int, message []byte, timeOut time.Duration) ([]byte, error)
 	SendPingMessage(from *Contact, contact *Contact) error
 	SendFindContactMessage(from *Contact, contact *Contact, id *KademliaID) ([]Contact, error)
-	SendFindDataMessage(from *Contact, contact *Contact, key *Key) ([]Contact, string, error)
-	SendStoreMessage(from *Contact, contact *Contact, key *Key, value string) bool
-	SendRefreshExpirationTimeMessage(from *Contact, contact *Contact, key *Key) bool
+	SendFindDataMessage(from *Contact, contact *Contact, key *Key) ([]Contact, *Value, error)
+	SendFindDataMessageWithTTL(from *Contact, contact *Contact, key *Key) (*Value, time.Duration, error)
+	SendStoreMessage(from *Contact, contact *Contact, key *Key, value Value, ttl time.Duration) error
+	SendRefreshExpirationTimeMessage(from *Contact, contact *Contact, key *Key, ttl time.Duration) bool
+	SendDeleteMessage(from *Contact, contact *Contact, key *Key, token string, ttl time.Duration) error
+	SendAddProviderMessage(from *Contact, contact *Contact, key *Key, ttl time.Duration) error
+	SendGetProvidersMessage(from *Contact, contact *Contact, key *Key) ([]Contact, error)
+	SendSubscribeMessage(from *Contact, contact *Contact, topic string, ttl time.Duration) error
+	SendPublishMessage(from *Contact, contact *Contact, message TopicMessage) error
+	SendDeliverMessage(from *Contact, contact *Contact, message TopicMessage) error
+	SendSyncMessage(from *Contact, contact *Contact, summary SyncSummary) ([]SyncEntry, error)
 }
 
 type NetworkImplementation struct {
@@ -42,7 +54,7 @@ func (network *NetworkImplementation) Listen() error {
 	logger.Log("Server listening " + network.Ip + ":" + strconv.Itoa(network.Port))
 
 	for {
-		data := make([]byte, 1024)
+		data := make([]byte, MaxMessageSize)
 		len, remote, err := conn.ReadFromUDP(data[:])
 		if err != nil {
 			logger.Log("Failed to read from UDP: " + err.Error())
@@ -50,7 +62,7 @@ func (network *NetworkImplementation) Listen() error {
 		}
 
 		go func(myConn *net.UDPConn) {
-			response, err := network.MessageHandler.HandleMessage(data[:len])
+			response, err := network.MessageHandler.HandleMessage(data[:len], remote.IP.String())
 			if err != nil {
 				logger.Log("Failed to handle response message: " + err.Error())
 				return
@@ -73,6 +85,7 @@ func (network *NetworkImplementation) Send(ip string, port int, message []byte,
 		logger.Log("Failed to connect via UDP: " + err.Error())
 		return nil, err
 	}
+	defer conn.Close()
 
 	// Send a message to the server
 	_, err = conn.Write(message)
@@ -84,7 +97,7 @@ func (network *NetworkImplementation) Send(ip string, port int, message []byte,
 	responseChannel := make(chan []byte)
 	go func() {
 		// Read from the connection
-		data := make([]byte, 1024)
+		data := make([]byte, MaxMessageSize)
 		len, _, err := conn.ReadFromUDP(data[:])
 		if err != nil {
 			return
@@ -95,7 +108,7 @@ func (network *NetworkImplementation) Send(ip string, port int, message []byte,
 
 	select {
 	case response := <-responseChannel:
-		if _, err := network.MessageHandler.HandleMessage(response); err != nil {
+		if _, err := network.MessageHandler.HandleMessage(response, ip); err != nil {
 			return nil, err
 		} else {
 			return response, nil
@@ -171,69 +184,296 @@ func (network *NetworkImplementation) SendFindContactMessage(from *Contact, cont
 	return arrayOfContacts.Contacts, nil
 }
 
-func (network *NetworkImplementation) SendFindDataMessage(from *Contact, contact *Contact, key *Key) ([]Contact, string, error) {
+// SendFindDataMessage returns the value of the key if the contact has it, otherwise the value is nil and the
+// contacts closest to the key that the contact knows of are returned.
+func (network *NetworkImplementation) SendFindDataMessage(from *Contact, contact *Contact, key *Key) ([]Contact, *Value, error) {
+	data, err := network.findData(from, contact, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if data.Value == nil {
+		return data.Contacts, nil, nil
+	} else {
+		return nil, data.Value, nil
+	}
+
+}
+
+// SendFindDataMessageWithTTL returns the value of the key if the contact has it and what is left of its lifetime at
+// the contact, otherwise the value is nil.
+func (network *NetworkImplementation) SendFindDataMessageWithTTL(from *Contact, contact *Contact, key *Key) (*Value, time.Duration, error) {
+	data, err := network.findData(from, contact, key)
+	if err != nil || data.Value == nil {
+		return nil, 0, err
+	}
+	return data.Value, data.TTL, nil
+}
+
+func (network *NetworkImplementation) findData(from *Contact, contact *Contact, key *Key) (FoundData, error) {
+	var data FoundData
 	findData := NewFindDataMessage(*from, key)
 	bytes, err := json.Marshal(findData)
 	if err != nil {
-		return nil, "", err
+		return data, err
 	}
 
 	response, err := network.Send(contact.Ip, contact.Port, bytes, time.Second*3)
 	if err != nil {
 		logger.Log("Find data failed: " + err.Error())
-		return nil, "", err
+		return data, err
 	}
 
 	var message Message
 	errUnmarshal := json.Unmarshal(response, &message)
-	if errUnmarshal != nil || message.MessageType != FOUND_DATA {
+	if errUnmarshal != nil {
 		logger.Log("Find data failed: " + errUnmarshal.Error())
-		return nil, "", errUnmarshal
+		return data, errUnmarshal
+	}
+	if message.MessageType != FOUND_DATA {
+		logger.Log("Find data failed: unexpected message type " + string(message.MessageType))
+		return data, errors.New("unexpected message type")
 	}
 
-	var data FoundData
 	errUnmarshalFoundData := json.Unmarshal(response, &data)
 	if errUnmarshalFoundData != nil {
 		logger.Log("Error when unmarshaling 'foundData' message: " + errUnmarshalFoundData.Error())
-		return nil, "", errUnmarshalFoundData
+		return data, errUnmarshalFoundData
 	}
-
-	json.Unmarshal(response, &data)
-	if data.Value == "" {
-		return data.Contacts, "", nil
-	} else {
-		return nil, data.Value, nil
-	}
-
+	return data, nil
 }
 
-func (network *NetworkImplementation) SendStoreMessage(from *Contact, contact *Contact, key *Key, value string) bool {
-	store := NewStoreMessage(*from, key, value)
+// SendStoreMessage returns nil if the contact stored the value, and a *StoreRefusedError if it answered that it will not.
+func (network *NetworkImplementation) SendStoreMessage(from *Contact, contact *Contact, key *Key, value Value, ttl time.Duration) error {
+	store := NewStoreMessage(*from, key, value, ttl)
 	bytes, err := json.Marshal(store)
 	if err != nil {
 		logger.Log("Error when marshaling `store` message: " + err.Error())
-		return false
+		return err
 	}
 
 	response, err := network.Send(contact.Ip, contact.Port, bytes, time.Second*3)
 	if err != nil {
 		logger.Log("Store failed: " + err.Error())
-		return false
+		return err
 	}
 
 	var storeResponse StoreResponse
 	err = json.Unmarshal(response, &storeResponse)
 	if err != nil {
 		logger.Log("Error when unmarshaling `storeResponse` message: " + err.Error())
-		return false
+		return err
+	}
+	if !storeResponse.StoreSuccess {
+		logger.Log(contact.Ip + " refused to store the data object " + key.GetHashString() + ": " + storeResponse.Reason)
+		return &StoreRefusedError{Reason: storeResponse.Reason}
+	}
+
+	return nil
+
+}
+
+// SendDeleteMessage returns nil if the contact deleted the value, and a *DeleteRefusedError if it answered that it will not.
+func (network *NetworkImplementation) SendDeleteMessage(from *Contact, contact *Contact, key *Key, token string, ttl time.Duration) error {
+	bytes, err := json.Marshal(NewDeleteMessage(*from, key, token, ttl))
+	if err != nil {
+		logger.Log("Error when marshaling `delete` message: " + err.Error())
+		return err
+	}
+
+	response, err := network.Send(contact.Ip, contact.Port, bytes, time.Second*3)
+	if err != nil {
+		logger.Log("Delete failed: " + err.Error())
+		return err
+	}
+
+	var deleteResponse DeleteResponse
+	err = json.Unmarshal(response, &deleteResponse)
+	if err != nil {
+		logger.Log("Error when unmarshaling `deleteResponse` message: " + err.Error())
+		return err
+	}
+	if !deleteResponse.DeleteSuccess {
+		logger.Log(contact.Ip + " refused to delete the data object " + key.GetHashString() + ": " + deleteResponse.Reason)
+		return &DeleteRefusedError{Reason: deleteResponse.Reason}
+	}
+
+	return nil
+}
+
+// SendAddProviderMessage returns nil if the contact recorded the sender as a provider of the key, and a
+// *StoreRefusedError if it answered that it will not.
+func (network *NetworkImplementation) SendAddProviderMessage(from *Contact, contact *Contact, key *Key, ttl time.Duration) error {
+	bytes, err := json.Marshal(NewAddProviderMessage(*from, key, ttl))
+	if err != nil {
+		logger.Log("Error when marshaling `addProvider` message: " + err.Error())
+		return err
+	}
+
+	response, err := network.Send(contact.Ip, contact.Port, bytes, time.Second*3)
+	if err != nil {
+		logger.Log("Add provider failed: " + err.Error())
+		return err
+	}
+
+	var addProviderResponse AddProviderResponse
+	err = json.Unmarshal(response, &addProviderResponse)
+	if err != nil {
+		logger.Log("Error when unmarshaling `addProviderResponse` message: " + err.Error())
+		return err
+	}
+	if !addProviderResponse.AddSuccess {
+		logger.Log(contact.Ip + " refused to record the provider of " + key.GetHashString() + ": " + addProviderResponse.Reason)
+		return &StoreRefusedError{Reason: addProviderResponse.Reason}
+	}
+
+	return nil
+}
+
+// SendGetProvidersMessage returns the providers of the key the contact has records of.
+func (network *NetworkImplementation) SendGetProvidersMessage(from *Contact, contact *Contact, key *Key) ([]Contact, error) {
+	bytes, err := json.Marshal(NewGetProvidersMessage(*from, key))
+	if err != nil {
+		logger.Log("Error when marshaling `getProviders` message: " + err.Error())
+		return nil, err
+	}
+
+	response, err := network.Send(contact.Ip, contact.Port, bytes, time.Second*3)
+	if err != nil {
+		logger.Log("Get providers failed: " + err.Error())
+		return nil, err
+	}
+
+	var providers Providers
+	err = json.Unmarshal(response, &providers)
+	if err != nil {
+		logger.Log("Error when unmarshaling `providers` message: " + err.Error())
+		return nil, err
+	}
+	if providers.MessageType != PROVIDERS {
+		logger.Log("Get providers failed: unexpected message type " + string(providers.MessageType))
+		return nil, errors.New("unexpected message type")
+	}
+
+	return providers.Providers, nil
+}
+
+// SendSubscribeMessage returns nil if the contact subscribed the sender to the topic, and a *StoreRefusedError if it
+// answered that it will not.
+func (network *NetworkImplementation) SendSubscribeMessage(from *Contact, contact *Contact, topic string, ttl time.Duration) error {
+	bytes, err := json.Marshal(NewSubscribeMessage(*from, topic, ttl))
+	if err != nil {
+		logger.Log("Error when marshaling `subscribe` message: " + err.Error())
+		return err
+	}
+
+	response, err := network.Send(contact.Ip, contact.Port, bytes, time.Second*3)
+	if err != nil {
+		logger.Log("Subscribe failed: " + err.Error())
+		return err
+	}
+
+	var subscribeResponse SubscribeResponse
+	err = json.Unmarshal(response, &subscribeResponse)
+	if err != nil {
+		logger.Log("Error when unmarshaling `subscribeResponse` message: " + err.Error())
+		return err
+	}
+	if !subscribeResponse.SubscribeSuccess {
+		logger.Log(contact.Ip + " refused the subscription to the topic " + topic + ": " + subscribeResponse.Reason)
+		return &StoreRefusedError{Reason: subscribeResponse.Reason}
+	}
+
+	return nil
+}
+
+// SendPublishMessage returns nil if the contact accepted to forward the message to the subscribers of its topic.
+func (network *NetworkImplementation) SendPublishMessage(from *Contact, contact *Contact, message TopicMessage) error {
+	bytes, err := json.Marshal(NewPublishMessage(*from, message))
+	if err != nil {
+		logger.Log("Error when marshaling `publish` message: " + err.Error())
+		return err
+	}
+
+	response, err := network.Send(contact.Ip, contact.Port, bytes, time.Second*3)
+	if err != nil {
+		logger.Log("Publish failed: " + err.Error())
+		return err
+	}
+
+	var publishResponse PublishResponse
+	err = json.Unmarshal(response, &publishResponse)
+	if err != nil {
+		logger.Log("Error when unmarshaling `publishResponse` message: " + err.Error())
+		return err
 	}
+	if publishResponse.MessageType != PUBLISH_RESPONSE {
+		logger.Log("Publish failed: unexpected message type " + string(publishResponse.MessageType))
+		return errors.New("unexpected message type")
+	}
+
+	return nil
+}
 
-	return storeResponse.StoreSuccess
+// SendDeliverMessage returns nil if the subscriber received the message.
+func (network *NetworkImplementation) SendDeliverMessage(from *Contact, contact *Contact, message TopicMessage) error {
+	bytes, err := json.Marshal(NewDeliverMessage(*from, message))
+	if err != nil {
+		logger.Log("Error when marshaling `deliver` message: " + err.Error())
+		return err
+	}
+
+	response, err := network.Send(contact.Ip, contact.Port, bytes, time.Second*3)
+	if err != nil {
+		logger.Log("Deliver failed: " + err.Error())
+		return err
+	}
+
+	var deliverResponse DeliverResponse
+	err = json.Unmarshal(response, &deliverResponse)
+	if err != nil {
+		logger.Log("Error when unmarshaling `deliverResponse` message: " + err.Error())
+		return err
+	}
+	if deliverResponse.MessageType != DELIVER_RESPONSE {
+		logger.Log("Deliver failed: unexpected message type " + string(deliverResponse.MessageType))
+		return errors.New("unexpected message type")
+	}
+
+	return nil
+}
+
+// SendSyncMessage sends the summary of the keys both nodes should hold, and returns the keys the contact holds in the
+// ranges where the summaries differ.
+func (network *NetworkImplementation) SendSyncMessage(from *Contact, contact *Contact, summary SyncSummary) ([]SyncEntry, error) {
+	bytes, err := json.Marshal(NewSyncMessage(*from, summary))
+	if err != nil {
+		logger.Log("Error when marshaling `sync` message: " + err.Error())
+		return nil, err
+	}
+
+	response, err := network.Send(contact.Ip, contact.Port, bytes, time.Second*3)
+	if err != nil {
+		logger.Log("Sync failed: " + err.Error())
+		return nil, err
+	}
+
+	var syncResponse SyncResponse
+	err = json.Unmarshal(response, &syncResponse)
+	if err != nil {
+		logger.Log("Error when unmarshaling `syncResponse` message: " + err.Error())
+		return nil, err
+	}
+	if syncResponse.MessageType != SYNC_RESPONSE {
+		logger.Log("Sync failed: unexpected message type " + string(syncResponse.MessageType))
+		return nil, errors.New("unexpected message type")
+	}
 
+	return syncResponse.Entries, nil
 }
 
-func (network *NetworkImplementation) SendRefreshExpirationTimeMessage(from *Contact, contact *Contact, key *Key) bool {
-	refreshExpirationTime := NewRefreshExpirationTimeMessage(*from, key)
+func (network *NetworkImplementation) SendRefreshExpirationTimeMessage(from *Contact, contact *Contact, key *Key, ttl time.Duration) bool {
+	refreshExpirationTime := NewRefreshExpirationTimeMessage(*from, key, ttl)
 	bytes, err := json.Marshal(refreshExpirationTime)
 
 	if err != nil {
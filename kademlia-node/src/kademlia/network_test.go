@@ -30,7 +30,7 @@ func NewOkMessage(debugMessage string) OkMessage {
 
 type MockMessageHandler struct{}
 
-func (messageHandler *MockMessageHandler) HandleMessage(rawMessage []byte) ([]byte, error) {
+func (messageHandler *MockMessageHandler) HandleMessage(rawMessage []byte, senderIp string) ([]byte, error) {
 	var message Message
 	json.Unmarshal(rawMessage, &message)
 	fmt.Println(message.MessageType)
@@ -121,7 +121,7 @@ func TestClient(t *testing.T) {
 type MockMessageHandler2 struct {
 }
 
-func (mockMessageHandler *MockMessageHandler2) HandleMessage(rawMessage []byte) ([]byte, error) {
+func (mockMessageHandler *MockMessageHandler2) HandleMessage(rawMessage []byte, senderIp string) ([]byte, error) {
 	var findN FindNode
 
 	json.Unmarshal(rawMessage, &findN)
@@ -175,12 +175,14 @@ func TestSendNodeDataMessage(t *testing.T) {
 	value := "data"
 	key := NewKey(value)
 
-	bootstrap.KademliaNode.GetDataStore().Insert(key, value)
+	bootstrap.KademliaNode.GetDataStore().Insert(key, NewTextValue(value), DefaultTTL)
 	go bootstrap.Start()
 	time.Sleep(time.Second)
-	_, str, _ := bootstrap.Network.SendFindDataMessage(&bootstrap.KademliaNode.GetRoutingTable().Me, &bootstrap.KademliaNode.GetRoutingTable().Me, key)
+	_, data, _ := bootstrap.Network.SendFindDataMessage(&bootstrap.KademliaNode.GetRoutingTable().Me, &bootstrap.KademliaNode.GetRoutingTable().Me, key)
 
-	assert.Equal(t, str, value)
+	if assert.NotNil(t, data) {
+		assert.Equal(t, value, string(data.Data))
+	}
 }
 
 func TestSendNodeDataMessageNoData(t *testing.T) {
@@ -206,7 +208,7 @@ func TestSendNodeDataMessageNoData(t *testing.T) {
 
 type MockSlowMessageHandler struct{}
 
-func (messageHandler *MockSlowMessageHandler) HandleMessage(rawMessage []byte) ([]byte, error) {
+func (messageHandler *MockSlowMessageHandler) HandleMessage(rawMessage []byte, senderIp string) ([]byte, error) {
 	var message Message
 	json.Unmarshal(rawMessage, &message)
 	fmt.Println(message.MessageType)
@@ -241,7 +243,7 @@ func TestTimeout(t *testing.T) {
 
 type MockMessageHandlerConcurrentSend struct{}
 
-func (messageHandler *MockMessageHandlerConcurrentSend) HandleMessage(rawMessage []byte) ([]byte, error) {
+func (messageHandler *MockMessageHandlerConcurrentSend) HandleMessage(rawMessage []byte, senderIp string) ([]byte, error) {
 	var message Message
 	json.Unmarshal(rawMessage, &message)
 	if message.MessageType == OK_MESSAGE {
@@ -278,7 +280,7 @@ func (network *NetworkImplementation) sendOkMessage(t *testing.T, startNumber in
 func TestConcurrentSends(t *testing.T) {
 	network := NetworkImplementation{
 		"localhost",
-		7000,
+		7040, // TestLookupContact2 keeps a node listening on 7000
 		&MockMessageHandlerConcurrentSend{},
 	}
 
@@ -299,7 +301,7 @@ func TestConcurrentSends(t *testing.T) {
 type MockMessageHandlerRefresh struct {
 }
 
-func (mockMessageHandler *MockMessageHandlerRefresh) HandleMessage(rawMessage []byte) ([]byte, error) {
+func (mockMessageHandler *MockMessageHandlerRefresh) HandleMessage(rawMessage []byte, senderIp string) ([]byte, error) {
 	var refreshExpirationTime RefreshExpirationTime
 
 	json.Unmarshal(rawMessage, &refreshExpirationTime)
@@ -335,6 +337,124 @@ func TestSendRefreshMessage(t *testing.T) {
 	time.Sleep(time.Second)
 
 	from := NewContact(GenerateNewKademliaID("FFFFFFFF00000000000000000000000000000000"), mockNetwork.Ip, mockNetwork.Port)
-	response := mockNetwork.SendRefreshExpirationTimeMessage(&from, &mockContact, key)
+	response := mockNetwork.SendRefreshExpirationTimeMessage(&from, &mockContact, key, DefaultTTL)
 	assert.True(t, response)
 }
+
+func TestSendNodeDataMessageBinaryValue(t *testing.T) {
+
+	bootstrap := CreateMockedKademlia(GenerateNewKademliaID("FFFFFFFF00000000000000000000000000000000"), "127.0.0.1", 7030)
+
+	// Larger than the read buffer used to be, and not valid UTF-8
+	data := make([]byte, 20000)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	value := NewValue(data, BinaryContentType)
+
+	bootstrap.KademliaNode.GetDataStore().Insert(value.GetKey(), value, DefaultTTL)
+	go bootstrap.Start()
+	time.Sleep(time.Second)
+	_, foundValue, err := bootstrap.Network.SendFindDataMessage(&bootstrap.KademliaNode.GetRoutingTable().Me, &bootstrap.KademliaNode.GetRoutingTable().Me, value.GetKey())
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+
+	if assert.NotNil(t, foundValue) {
+		assert.Equal(t, data, foundValue.Data)
+		assert.Equal(t, BinaryContentType, foundValue.ContentType)
+		assert.Equal(t, len(data), foundValue.Size)
+	}
+}
+
+func TestSendDeleteMessage(t *testing.T) {
+	bootstrap := CreateMockedKademlia(GenerateNewKademliaID("FFFFFFFF00000000000000000000000000000000"), "127.0.0.1", 7050)
+	me := bootstrap.KademliaNode.GetRoutingTable().Me
+
+	value := NewTextValue("value")
+	value.DeleteTokenHash = HashDeleteToken("token")
+	bootstrap.KademliaNode.GetDataStore().Insert(value.GetKey(), value, DefaultTTL)
+	go bootstrap.Start()
+	time.Sleep(time.Second)
+
+	var refused *DeleteRefusedError
+	err := bootstrap.Network.SendDeleteMessage(&me, &me, value.GetKey(), "wrong token", DefaultTTL)
+	assert.ErrorAs(t, err, &refused)
+
+	err = bootstrap.Network.SendDeleteMessage(&me, &me, value.GetKey(), "token", DefaultTTL)
+	assert.NoError(t, err)
+	_, foundValue, err := bootstrap.Network.SendFindDataMessage(&me, &me, value.GetKey())
+	assert.NoError(t, err)
+	assert.Nil(t, foundValue)
+
+	var storeRefused *StoreRefusedError
+	err = bootstrap.Network.SendStoreMessage(&me, &me, value.GetKey(), value, DefaultTTL)
+	assert.ErrorAs(t, err, &storeRefused)
+}
+
+func TestSendProviderMessages(t *testing.T) {
+	bootstrap := CreateMockedKademlia(GenerateNewKademliaID("FFFFFFFF00000000000000000000000000000000"), "127.0.0.1", 7060)
+	me := bootstrap.KademliaNode.GetRoutingTable().Me
+	provider := NewContact(GenerateNewKademliaID("0000000000000000000000000000000000000001"), "127.0.0.1", 7061)
+	key := NewKey("large resource")
+	go bootstrap.Start()
+	time.Sleep(time.Second)
+
+	providers, err := bootstrap.Network.SendGetProvidersMessage(&me, &me, key)
+	assert.NoError(t, err)
+	assert.Empty(t, providers)
+
+	err = bootstrap.Network.SendAddProviderMessage(&provider, &me, key, DefaultTTL)
+	assert.NoError(t, err)
+
+	providers, err = bootstrap.Network.SendGetProvidersMessage(&me, &me, key)
+	assert.NoError(t, err)
+	if assert.Len(t, providers, 1) {
+		assert.True(t, providers[0].ID.Equals(provider.ID))
+		assert.Equal(t, 7061, providers[0].Port)
+	}
+}
+
+func TestSendTopicMessages(t *testing.T) {
+	rendezvous := CreateMockedKademlia(GenerateNewKademliaID("FFFFFFFF00000000000000000000000000000000"), "127.0.0.1", 7070)
+	subscriber := CreateMockedKademlia(GenerateNewKademliaID("0000000000000000000000000000000000000001"), "127.0.0.1", 7071)
+	me := rendezvous.KademliaNode.GetRoutingTable().Me
+	subscriberContact := subscriber.KademliaNode.GetRoutingTable().Me
+	messages := subscriber.KademliaNode.GetPubSub().listen("news")
+	go rendezvous.Start()
+	go subscriber.Start()
+	time.Sleep(time.Second)
+
+	err := rendezvous.Network.SendSubscribeMessage(&subscriberContact, &me, "news", SubscriptionTTL)
+	assert.NoError(t, err)
+
+	message := TopicMessage{ID: "1", Topic: "news", Data: []byte("hello"), Publisher: me, Time: time.Now()}
+	err = rendezvous.Network.SendPublishMessage(&me, &me, message)
+	assert.NoError(t, err)
+
+	select {
+	case delivered := <-messages:
+		assert.Equal(t, []byte("hello"), delivered.Data)
+	case <-time.After(3 * time.Second):
+		assert.Fail(t, "the message was not delivered")
+	}
+}
+
+func TestSendSyncMessage(t *testing.T) {
+	holder := CreateMockedKademlia(GenerateNewKademliaID("FFFFFFFF00000000000000000000000000000000"), "127.0.0.1", 7080)
+	replica := CreateMockedKademlia(GenerateNewKademliaID("0000000000000000000000000000000000000001"), "127.0.0.1", 7081)
+	holderContact := holder.KademliaNode.GetRoutingTable().Me
+	value := NewTextValue("lost store")
+	holder.KademliaNode.GetDataStore().Insert(value.GetKey(), value, DefaultTTL)
+	go holder.Start()
+	time.Sleep(time.Second)
+
+	assert.Equal(t, 1, replica.synchronize(holderContact))
+	stored, err := replica.KademliaNode.GetDataStore().Get(value.GetKey())
+	assert.NoError(t, err)
+	assert.Equal(t, value.Data, stored.Data)
+
+	entries, err := replica.Network.SendSyncMessage(&replica.KademliaNode.GetRoutingTable().Me, &holderContact, NewSyncSummary(sharedEntries(replica.KademliaNode, holderContact)))
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+}
@@ -0,0 +1,42 @@
+package kademlia
+
+import (
+	"errors"
+)
+
+// Pin keeps the value of the key on this node until it is unpinned, even if every publisher disappears. A value this
+// node does not hold is looked up first. Pinned values are never evicted, and are republished to the k closest nodes
+// every replication interval.
+func (kademlia *KademliaImplementation) Pin(key *Key) error {
+	dataStore := kademlia.KademliaNode.GetDataStore()
+	if _, err := dataStore.GetTime(key); err != nil {
+		_, value, err := kademlia.LookupData(key)
+		if err != nil {
+			return err
+		}
+		if value == nil {
+			return errors.New("the value was not found")
+		}
+		err = dataStore.Insert(key, *value, kademlia.KademliaNode.clampTTL(0))
+		if err != nil {
+			return err
+		}
+	}
+	return dataStore.Pin(key)
+}
+
+// Unpin lets the value of the key expire on this node again.
+func (kademlia *KademliaImplementation) Unpin(key *Key) error {
+	return kademlia.KademliaNode.GetDataStore().Unpin(key)
+}
+
+// GetPins returns the keys pinned on this node.
+func (kademlia *KademliaImplementation) GetPins() []*Key {
+	keys := []*Key{}
+	for _, item := range kademlia.KademliaNode.GetDataStore().Items() {
+		if item.Pinned {
+			keys = append(keys, item.Key)
+		}
+	}
+	return keys
+}
@@ -0,0 +1,31 @@
+package kademlia
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPinLooksUpMissingValue(t *testing.T) {
+	value := NewTextValue("value")
+	network := &NetworkReplicaMock{values: map[int]*Value{1: &value, 2: &value, 3: &value}}
+	kademlia := createQuorumTestKademlia(network)
+
+	assert.NoError(t, kademlia.Pin(value.GetKey()))
+
+	pins := kademlia.GetPins()
+	if assert.Len(t, pins, 1) {
+		assert.True(t, pins[0].Equals(value.GetKey()))
+	}
+
+	assert.NoError(t, kademlia.Unpin(value.GetKey()))
+	assert.Empty(t, kademlia.GetPins())
+}
+
+func TestPinMissingValue(t *testing.T) {
+	network := &NetworkReplicaMock{}
+	kademlia := createQuorumTestKademlia(network)
+
+	assert.Error(t, kademlia.Pin(NewKey("value")))
+	assert.Empty(t, kademlia.GetPins())
+}
@@ -0,0 +1,191 @@
+package kademlia
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/arianfiftyone/src/logger"
+)
+
+const (
+	MaxProvidersPerKey         = bucketSize  // How many providers of one key a node keeps records of
+	providerReannounceInterval = time.Second // How often the node checks whether its own provider records are due
+)
+
+// Provider is the record of a contact that announced it provides the value of a key, which holds until it expires.
+// The provider announces it again before then, or the record is dropped.
+type Provider struct {
+	Key        *Key
+	Contact    Contact
+	TTL        time.Duration
+	Expiration time.Time
+}
+
+// ProviderStore keeps the provider records announced to a node, including those of the node itself. The zero value
+// is ready to use.
+type ProviderStore struct {
+	lock      sync.Mutex
+	providers map[[KeySize]byte][]Provider
+}
+
+// Add records the provider, replacing an earlier record of the same contact for the key. A new provider is refused if
+// the key already has MaxProvidersPerKey providers.
+func (store *ProviderStore) Add(provider Provider) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	if store.providers == nil {
+		store.providers = make(map[[KeySize]byte][]Provider)
+	}
+	providers := store.unexpired(provider.Key)
+	for i := range providers {
+		if providers[i].Contact.ID.Equals(provider.Contact.ID) {
+			providers[i] = provider
+			return nil
+		}
+	}
+	if len(providers) >= MaxProvidersPerKey {
+		return errors.New("the key has too many providers")
+	}
+	store.providers[provider.Key.Hash] = append(providers, provider)
+	return nil
+}
+
+// Remove drops the record of the contact for the key, and reports whether there was one.
+func (store *ProviderStore) Remove(key *Key, id *KademliaID) bool {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	providers := store.providers[key.Hash]
+	for i := range providers {
+		if providers[i].Contact.ID.Equals(id) {
+			store.providers[key.Hash] = append(providers[:i], providers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// GetProviders returns the contacts providing the key whose records have not expired.
+func (store *ProviderStore) GetProviders(key *Key) []Contact {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	contacts := []Contact{}
+	for _, provider := range store.unexpired(key) {
+		contacts = append(contacts, provider.Contact)
+	}
+	return contacts
+}
+
+// GetProvidedBy returns the unexpired records of the contact with the given ID, ordered by key.
+func (store *ProviderStore) GetProvidedBy(id *KademliaID) []Provider {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	provided := []Provider{}
+	for hash := range store.providers {
+		key := &Key{Hash: hash}
+		for _, provider := range store.unexpired(key) {
+			if provider.Contact.ID.Equals(id) {
+				provided = append(provided, provider)
+			}
+		}
+	}
+	sort.Slice(provided, func(i, j int) bool {
+		return provided[i].Key.GetHashString() < provided[j].Key.GetHashString()
+	})
+	return provided
+}
+
+// unexpired drops the expired records of the key and returns the others. The lock must be held by the caller.
+func (store *ProviderStore) unexpired(key *Key) []Provider {
+	now := time.Now()
+	providers := []Provider{}
+	for _, provider := range store.providers[key.Hash] {
+		if provider.Expiration.After(now) {
+			providers = append(providers, provider)
+		}
+	}
+	if len(providers) > 0 {
+		store.providers[key.Hash] = providers
+	} else {
+		delete(store.providers, key.Hash)
+	}
+	return providers
+}
+
+// Provide announces this node as a provider of the key to the k closest nodes to the key, without storing the value
+// in the network. The announcement is repeated each half time to live until StopProviding is called.
+func (kademlia *KademliaImplementation) Provide(key *Key, ttl time.Duration) (*StoreResult, error) {
+	ttl = kademlia.KademliaNode.clampTTL(ttl)
+	me := kademlia.KademliaNode.GetRoutingTable().Me
+	err := kademlia.KademliaNode.GetProviderStore().Add(Provider{Key: key, Contact: me, TTL: ttl, Expiration: time.Now().Add(ttl)})
+	if err != nil {
+		return nil, err
+	}
+
+	return kademlia.sendToClosest(key, "no node recorded the provider", func(contact Contact) error {
+		return kademlia.Network.SendAddProviderMessage(&me, &contact, key, ttl)
+	})
+}
+
+// StopProviding stops announcing this node as a provider of the key, so its records expire at the other nodes.
+func (kademlia *KademliaImplementation) StopProviding(key *Key) error {
+	if !kademlia.KademliaNode.GetProviderStore().Remove(key, kademlia.KademliaNode.GetRoutingTable().Me.ID) {
+		return errors.New("key not found")
+	}
+	return nil
+}
+
+// FindProviders asks the k closest nodes to the key for its providers, and returns every provider they and this node
+// know of.
+func (kademlia *KademliaImplementation) FindProviders(key *Key) ([]Contact, error) {
+	contacts, err := kademlia.LookupContact(key.GetKademliaIdRepresentationOfKey())
+	if err != nil {
+		return nil, err
+	}
+
+	me := kademlia.KademliaNode.GetRoutingTable().Me
+	found := make([][]Contact, len(contacts))
+	var waitGroup sync.WaitGroup
+	for i, contact := range contacts {
+		waitGroup.Add(1)
+		go func(i int, contact Contact) {
+			defer waitGroup.Done()
+			found[i], _ = kademlia.Network.SendGetProvidersMessage(&me, &contact, key)
+		}(i, contact)
+	}
+	waitGroup.Wait()
+
+	providers := kademlia.KademliaNode.GetProviderStore().GetProviders(key)
+	for _, contacts := range found {
+		for _, contact := range contacts {
+			if contact.ID != nil && !kademlia.FirstSetContainsAllContactsOfSecondSet(providers, []Contact{contact}) {
+				providers = append(providers, contact)
+			}
+		}
+	}
+	return providers, nil
+}
+
+// reannounceProviders announces this node again as a provider of every key whose record has passed half its time to
+// live, so that the records at the other nodes do not expire.
+func (kademlia *KademliaImplementation) reannounceProviders() {
+	for {
+		time.Sleep(providerReannounceInterval)
+
+		me := kademlia.KademliaNode.GetRoutingTable().Me
+		for _, provider := range kademlia.KademliaNode.GetProviderStore().GetProvidedBy(me.ID) {
+			if time.Until(provider.Expiration) > provider.TTL/2 {
+				continue
+			}
+			_, err := kademlia.Provide(provider.Key, provider.TTL)
+			if err != nil {
+				logger.Log("Failed to announce the provider of " + provider.Key.GetHashString() + " again: " + err.Error())
+			}
+		}
+	}
+}
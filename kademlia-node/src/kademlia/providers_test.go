@@ -0,0 +1,134 @@
+package kademlia
+
+import (
+	"encoding/json"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// NetworkProvidersMock keeps the provider records each contact has been sent, by port.
+type NetworkProvidersMock struct {
+	NetworkMock
+	lock      sync.Mutex
+	providers map[int][]Contact
+}
+
+func (network *NetworkProvidersMock) SendAddProviderMessage(from *Contact, contact *Contact, key *Key, ttl time.Duration) error {
+	network.lock.Lock()
+	defer network.lock.Unlock()
+	if network.providers == nil {
+		network.providers = make(map[int][]Contact)
+	}
+	network.providers[contact.Port] = append(network.providers[contact.Port], *from)
+	return nil
+}
+
+func (network *NetworkProvidersMock) SendGetProvidersMessage(from *Contact, contact *Contact, key *Key) ([]Contact, error) {
+	network.lock.Lock()
+	defer network.lock.Unlock()
+	return network.providers[contact.Port], nil
+}
+
+func newProvider(key *Key, id string, ttl time.Duration) Provider {
+	return Provider{
+		Key:        key,
+		Contact:    NewContact(GenerateNewKademliaID(id), "127.0.0.1", 1),
+		TTL:        ttl,
+		Expiration: time.Now().Add(ttl),
+	}
+}
+
+func TestProviderStoreReplacesAndExpiresRecords(t *testing.T) {
+	store := ProviderStore{}
+	key := NewKey("resource")
+
+	assert.NoError(t, store.Add(newProvider(key, "0000000000000000000000000000000000000001", DefaultTTL)))
+	assert.NoError(t, store.Add(newProvider(key, "0000000000000000000000000000000000000001", DefaultTTL)))
+	assert.NoError(t, store.Add(newProvider(key, "0000000000000000000000000000000000000002", 50*time.Millisecond)))
+	assert.Len(t, store.GetProviders(key), 2)
+
+	time.Sleep(100 * time.Millisecond)
+	providers := store.GetProviders(key)
+	if assert.Len(t, providers, 1) {
+		assert.Equal(t, "0000000000000000000000000000000000000001", providers[0].ID.String())
+	}
+
+	assert.True(t, store.Remove(key, providers[0].ID))
+	assert.False(t, store.Remove(key, providers[0].ID))
+	assert.Empty(t, store.GetProviders(key))
+}
+
+func TestProviderStoreLimitsProvidersPerKey(t *testing.T) {
+	store := ProviderStore{}
+	key := NewKey("resource")
+
+	for i := 0; i < MaxProvidersPerKey; i++ {
+		id := NewRandomKademliaID().String()
+		assert.NoError(t, store.Add(newProvider(key, id, DefaultTTL)), strconv.Itoa(i))
+	}
+	assert.Error(t, store.Add(newProvider(key, NewRandomKademliaID().String(), DefaultTTL)))
+}
+
+func TestProvideAndFindProviders(t *testing.T) {
+	network := &NetworkProvidersMock{}
+	kademlia := createQuorumTestKademlia(network)
+	key := NewKey("resource")
+
+	result, err := kademlia.Provide(key, DefaultTTL)
+	assert.NoError(t, err)
+	assert.Len(t, result.GetContacts(STORE_ACKNOWLEDGED), 3)
+
+	// Another provider announced itself to one of the closest nodes
+	other := NewContact(GenerateNewKademliaID("0000000000000000000000000000000000000009"), "127.0.0.1", 9)
+	closest := NewContact(GenerateNewKademliaID("0000000000000000000000000000000000000001"), "127.0.0.1", 1)
+	network.SendAddProviderMessage(&other, &closest, key, DefaultTTL)
+
+	providers, err := kademlia.FindProviders(key)
+	assert.NoError(t, err)
+	assert.Len(t, providers, 2)
+
+	assert.NoError(t, kademlia.StopProviding(key))
+	assert.Error(t, kademlia.StopProviding(key))
+	assert.Empty(t, kademlia.KademliaNode.GetProviderStore().GetProvidedBy(kademlia.KademliaNode.GetRoutingTable().Me.ID))
+}
+
+func TestProvideWithoutAnswers(t *testing.T) {
+	kademlia := createQuorumTestKademlia(&NetworkMock{})
+
+	result, err := kademlia.Provide(NewKey("resource"), DefaultTTL)
+	assert.Error(t, err)
+	assert.Len(t, result.GetContacts(STORE_TIMED_OUT), 3)
+}
+
+func TestAddProviderMessageRefusesOtherProvider(t *testing.T) {
+	kademliaNode := createTombstoneTestNode(3009)
+	messageHandler := &MessageHandlerImplementation{
+		kademliaNode: kademliaNode,
+	}
+	key := NewKey("value")
+	sendAddProvider := func(from Contact, senderIp string) AddProviderResponse {
+		bytes, err := json.Marshal(NewAddProviderMessage(from, key, DefaultTTL))
+		assert.NoError(t, err)
+		response, err := messageHandler.HandleMessage(bytes, senderIp)
+		assert.NoError(t, err)
+		var addProviderResponse AddProviderResponse
+		json.Unmarshal(response, &addProviderResponse)
+		return addProviderResponse
+	}
+
+	// A sender cannot announce another node as the provider
+	forged := NewContact(GenerateNewKademliaID("0000000000000000000000000000000000000001"), "10.0.0.1", 1)
+	assert.False(t, sendAddProvider(forged, "10.0.0.2").AddSuccess)
+	assert.Empty(t, kademliaNode.GetProviderStore().GetProviders(key))
+
+	assert.True(t, sendAddProvider(forged, "10.0.0.1").AddSuccess)
+	providers := kademliaNode.GetProviderStore().GetProviders(key)
+	if assert.Len(t, providers, 1) {
+		assert.Equal(t, "10.0.0.1", providers[0].Ip)
+		assert.Equal(t, 1, providers[0].Port)
+	}
+}
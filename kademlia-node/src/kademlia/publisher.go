@@ -0,0 +1,293 @@
+package kademlia
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/arianfiftyone/src/logger"
+)
+
+// publisherIdleInterval is how long the republish loop sleeps when there is nothing to republish.
+const publisherIdleInterval = time.Minute
+
+// Publication is a value this node has published and keeps alive by republishing it twice every time to live.
+type Publication struct {
+	Key             *Key          `json:"key"`
+	Value           Value         `json:"value"`
+	TTL             time.Duration `json:"ttl"`
+	DeleteToken     string        `json:"deleteToken,omitempty"`
+	Contacts        []Contact     `json:"contacts"` // The replicas the value is republished to
+	LastRepublished time.Time     `json:"lastRepublished"`
+	Replicas        int           `json:"replicas"` // How many contacts held the value after it was last republished
+}
+
+// publicationMetadata is what the registry file holds of a publication. Its value is saved to a file of its own, which
+// is written once when the value is published rather than every time the publication is republished.
+type publicationMetadata struct {
+	Key             *Key          `json:"key"`
+	TTL             time.Duration `json:"ttl"`
+	DeleteToken     string        `json:"deleteToken,omitempty"`
+	Contacts        []Contact     `json:"contacts"`
+	LastRepublished time.Time     `json:"lastRepublished"`
+	Replicas        int           `json:"replicas"`
+}
+
+// PublisherRegistry records the values this node maintains in the network. If it has a file, the publications are
+// saved to it after they are added or removed and once after every republish pass, and each value is saved next to it
+// in a directory of values, so that publishing continues after a restart. The zero value is ready to use and is kept
+// in memory.
+type PublisherRegistry struct {
+	lock         sync.Mutex
+	path         string
+	publications map[[KeySize]byte]*Publication
+	running      bool
+	changed      chan bool // Wakes the republish loop when a publication is added
+}
+
+// Load reads the publications saved in the file, which does not have to exist, and saves every later change to it.
+// A publication whose value is missing from the directory of values is skipped.
+func (registry *PublisherRegistry) Load(path string) error {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	registry.path = path
+	bytes, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var saved []publicationMetadata
+	err = json.Unmarshal(bytes, &saved)
+	if err != nil {
+		return err
+	}
+	registry.publications = make(map[[KeySize]byte]*Publication)
+	for _, metadata := range saved {
+		var value Value
+		bytes, err := os.ReadFile(registry.valuePath(metadata.Key))
+		if err == nil {
+			err = json.Unmarshal(bytes, &value)
+		}
+		if err != nil {
+			logger.Log("Failed to load the published value " + metadata.Key.GetHashString() + ": " + err.Error())
+			continue
+		}
+		registry.publications[metadata.Key.Hash] = &Publication{
+			Key:             metadata.Key,
+			Value:           value,
+			TTL:             metadata.TTL,
+			DeleteToken:     metadata.DeleteToken,
+			Contacts:        metadata.Contacts,
+			LastRepublished: metadata.LastRepublished,
+			Replicas:        metadata.Replicas,
+		}
+	}
+	return nil
+}
+
+// valuePath returns the file the value of the key is saved to, in the directory of values next to the registry file.
+func (registry *PublisherRegistry) valuePath(key *Key) string {
+	return filepath.Join(registry.path+".values", key.GetHashString())
+}
+
+// save writes the metadata of the publications to the file of the registry, if it has one. The lock must be held by
+// the caller.
+func (registry *PublisherRegistry) save() {
+	if registry.path == "" {
+		return
+	}
+	saved := []publicationMetadata{}
+	for _, publication := range registry.list() {
+		saved = append(saved, publicationMetadata{
+			Key:             publication.Key,
+			TTL:             publication.TTL,
+			DeleteToken:     publication.DeleteToken,
+			Contacts:        publication.Contacts,
+			LastRepublished: publication.LastRepublished,
+			Replicas:        publication.Replicas,
+		})
+	}
+	bytes, err := json.Marshal(saved)
+	if err == nil {
+		err = writeFileAtomically(registry.path, bytes)
+	}
+	if err != nil {
+		logger.Log("Failed to save the publisher registry: " + err.Error())
+	}
+}
+
+// saveValue writes the value of the publication to the directory of values, if the registry has a file. The lock must
+// be held by the caller.
+func (registry *PublisherRegistry) saveValue(publication Publication) {
+	if registry.path == "" {
+		return
+	}
+	bytes, err := json.Marshal(publication.Value)
+	if err == nil {
+		err = os.MkdirAll(registry.path+".values", 0700)
+	}
+	if err == nil {
+		err = writeFileAtomically(registry.valuePath(publication.Key), bytes)
+	}
+	if err != nil {
+		logger.Log("Failed to save the published value " + publication.Key.GetHashString() + ": " + err.Error())
+	}
+}
+
+// writeFileAtomically writes to a temporary file first and renames it, so a crash never leaves half a file behind. Only
+// the owner may read the file, since the registry holds the delete tokens of the publications.
+func writeFileAtomically(path string, bytes []byte) error {
+	err := os.WriteFile(path+".tmp", bytes, 0600)
+	if err == nil {
+		// The temporary file may be left over from a crash with a more permissive mode
+		err = os.Chmod(path+".tmp", 0600)
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// list returns a copy of every publication ordered by key. The lock must be held by the caller.
+func (registry *PublisherRegistry) list() []Publication {
+	publications := []Publication{}
+	for _, publication := range registry.publications {
+		publications = append(publications, *publication)
+	}
+	sort.Slice(publications, func(i, j int) bool {
+		return publications[i].Key.GetHashString() < publications[j].Key.GetHashString()
+	})
+	return publications
+}
+
+// Add records a published value, replacing an earlier publication of the same key.
+func (registry *PublisherRegistry) Add(publication Publication) {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	if registry.publications == nil {
+		registry.publications = make(map[[KeySize]byte]*Publication)
+	}
+	registry.publications[publication.Key.Hash] = &publication
+	registry.saveValue(publication)
+	registry.save()
+
+	if registry.changed != nil {
+		select {
+		case registry.changed <- true:
+		default:
+		}
+	}
+}
+
+// Remove stops maintaining the key, and reports whether it was maintained.
+func (registry *PublisherRegistry) Remove(key *Key) bool {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	_, ok := registry.publications[key.Hash]
+	if ok {
+		delete(registry.publications, key.Hash)
+		registry.save()
+		if registry.path != "" {
+			os.Remove(registry.valuePath(key))
+		}
+	}
+	return ok
+}
+
+// Get returns the publication of the key.
+func (registry *PublisherRegistry) Get(key *Key) (Publication, error) {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	publication, ok := registry.publications[key.Hash]
+	if !ok {
+		return Publication{}, errors.New("key not found")
+	}
+	return *publication, nil
+}
+
+// GetPublications returns every value the node maintains, ordered by key.
+func (registry *PublisherRegistry) GetPublications() []Publication {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	return registry.list()
+}
+
+// startRepublishing calls republish for every publication once half its time to live has passed since it was last
+// republished, in a single goroutine that is started at most once. republish returns how many replicas hold the value.
+func (registry *PublisherRegistry) startRepublishing(republish func(publication Publication) int) {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	if registry.running {
+		return
+	}
+	registry.running = true
+	registry.changed = make(chan bool, 1)
+	go registry.republishLoop(republish)
+}
+
+func (registry *PublisherRegistry) republishLoop(republish func(publication Publication) int) {
+	for {
+		due, wait := registry.nextDue()
+		for _, publication := range due {
+			replicas := republish(publication)
+			registry.markRepublished(publication, replicas)
+		}
+		if len(due) > 0 {
+			// Saved once for the whole pass rather than after every publication
+			registry.lock.Lock()
+			registry.save()
+			registry.lock.Unlock()
+			continue
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-registry.changed:
+		}
+	}
+}
+
+// nextDue returns the publications that should be republished now, and how long it is until the next one is due.
+func (registry *PublisherRegistry) nextDue() ([]Publication, time.Duration) {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	due := []Publication{}
+	wait := publisherIdleInterval
+	now := time.Now()
+	for _, publication := range registry.publications {
+		next := publication.LastRepublished.Add(publication.TTL / 2)
+		if !next.After(now) {
+			due = append(due, *publication)
+		} else if next.Sub(now) < wait {
+			wait = next.Sub(now)
+		}
+	}
+	return due, wait
+}
+
+// markRepublished records that the publication has just been republished, unless it has been removed or replaced since.
+// It is saved by the republish loop at the end of the pass.
+func (registry *PublisherRegistry) markRepublished(publication Publication, replicas int) {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	current, ok := registry.publications[publication.Key.Hash]
+	if !ok || !current.LastRepublished.Equal(publication.LastRepublished) {
+		return
+	}
+	current.LastRepublished = time.Now()
+	current.Replicas = replicas
+}
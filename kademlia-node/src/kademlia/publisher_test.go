@@ -0,0 +1,111 @@
+package kademlia
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublisherRegistryReplacesAndRemoves(t *testing.T) {
+	registry := PublisherRegistry{}
+	key := NewKey("value")
+
+	registry.Add(Publication{Key: key, TTL: DefaultTTL, Replicas: 1})
+	registry.Add(Publication{Key: key, TTL: DefaultTTL, Replicas: 3})
+
+	publication, err := registry.Get(key)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, publication.Replicas)
+	assert.Len(t, registry.GetPublications(), 1)
+
+	assert.True(t, registry.Remove(key))
+	assert.False(t, registry.Remove(key))
+	assert.Empty(t, registry.GetPublications())
+}
+
+func TestPublisherRegistrySurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "published.json")
+	value := NewTextValue("value")
+	contact := NewContact(GenerateNewKademliaID("0000000000000000000000000000000000000001"), "127.0.0.1", 1)
+	lastRepublished := time.Now().Round(0)
+
+	registry := PublisherRegistry{}
+	assert.NoError(t, registry.Load(path))
+	registry.Add(Publication{
+		Key:             value.GetKey(),
+		Value:           value,
+		TTL:             DefaultTTL,
+		DeleteToken:     "token",
+		Contacts:        []Contact{contact},
+		LastRepublished: lastRepublished,
+		Replicas:        1,
+	})
+
+	// Only the metadata is in the registry file, which is rewritten whenever a publication is republished
+	saved, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(saved), base64.StdEncoding.EncodeToString(value.Data))
+
+	// The registry holds the delete tokens, which only the owner may read
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	info, err = os.Stat(registry.valuePath(value.GetKey()))
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	restarted := PublisherRegistry{}
+	assert.NoError(t, restarted.Load(path))
+	publication, err := restarted.Get(value.GetKey())
+	assert.NoError(t, err)
+	assert.Equal(t, value.Data, publication.Value.Data)
+	assert.Equal(t, "token", publication.DeleteToken)
+	assert.True(t, publication.Contacts[0].ID.Equals(contact.ID))
+	assert.True(t, lastRepublished.Equal(publication.LastRepublished))
+	assert.Equal(t, 1, publication.Replicas)
+}
+
+func TestStoreRecordsPublication(t *testing.T) {
+	network := &NetworkStoreMock{}
+	kademlia := createQuorumTestKademlia(network)
+
+	result, err := kademlia.Store(NewTextValue("value"), DefaultTTL)
+	assert.NoError(t, err)
+
+	publications := kademlia.GetPublications()
+	if assert.Len(t, publications, 1) {
+		assert.True(t, publications[0].Key.Equals(result.Key))
+		assert.Equal(t, 3, publications[0].Replicas)
+		assert.Equal(t, result.DeleteToken, publications[0].DeleteToken)
+	}
+
+	assert.NoError(t, kademlia.Forget(result.Key))
+	assert.Empty(t, kademlia.GetPublications())
+	assert.Error(t, kademlia.Forget(result.Key))
+}
+
+func TestRepublishStoresMissingReplicas(t *testing.T) {
+	network := &NetworkStoreMock{}
+	kademlia := createQuorumTestKademlia(network)
+	ttl := 200 * time.Millisecond
+
+	result, err := kademlia.Store(NewTextValue("value"), ttl)
+	assert.NoError(t, err)
+	publication, _ := kademlia.KademliaNode.GetPublisherRegistry().Get(result.Key)
+
+	// The mocked replicas never refresh the value, so it is stored again
+	time.Sleep(ttl/2 + 100*time.Millisecond)
+	republished, err := kademlia.KademliaNode.GetPublisherRegistry().Get(result.Key)
+	assert.NoError(t, err)
+	assert.True(t, republished.LastRepublished.After(publication.LastRepublished))
+	assert.Equal(t, 3, republished.Replicas)
+
+	network.lock.Lock()
+	assert.GreaterOrEqual(t, len(network.stored), 6)
+	network.lock.Unlock()
+	kademlia.Forget(result.Key)
+}
@@ -0,0 +1,279 @@
+package kademlia
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/arianfiftyone/src/logger"
+)
+
+const (
+	SubscriptionTTL        = time.Minute // How long a rendezvous point keeps a subscription that is not renewed
+	MaxSubscribersPerTopic = 100         // How many subscribers of one topic a rendezvous point keeps
+	topicBufferSize        = 16          // How many undelivered messages a subscription holds before it drops new ones
+	seenMessageWindow      = time.Minute // How long a message is remembered, so that it is handled once
+)
+
+// TopicMessage is a message published to a topic. The k closest nodes to the key of the topic forward it to every
+// subscriber of the topic.
+type TopicMessage struct {
+	ID        string    `json:"id"` // Random, so a subscriber that gets the message from several rendezvous points handles it once
+	Topic     string    `json:"topic"`
+	Data      []byte    `json:"data"`
+	Publisher Contact   `json:"publisher"`
+	Time      time.Time `json:"time"`
+}
+
+// NewTopicKey returns the key of the topic, whose k closest nodes are the rendezvous points of the topic.
+func NewTopicKey(topic string) *Key {
+	return NewKey("topic/" + topic)
+}
+
+// Subscription receives the messages published to a topic until it is closed.
+type Subscription struct {
+	Topic     string
+	Messages  <-chan TopicMessage
+	close     func()
+	closeOnce sync.Once
+}
+
+// NewSubscription creates a subscription to the topic receiving messages from the channel, calling close once when it
+// is closed.
+func NewSubscription(topic string, messages <-chan TopicMessage, close func()) *Subscription {
+	return &Subscription{Topic: topic, Messages: messages, close: close}
+}
+
+// Close stops renewing the subscription and closes its channel of messages.
+func (subscription *Subscription) Close() {
+	subscription.closeOnce.Do(subscription.close)
+}
+
+type subscriber struct {
+	contact    Contact
+	expiration time.Time
+}
+
+// PubSub keeps the subscribers of the topics a node is a rendezvous point for, and the subscriptions of the node
+// itself. The zero value is ready to use.
+type PubSub struct {
+	lock        sync.Mutex
+	subscribers map[[KeySize]byte][]subscriber
+	listeners   map[string][]chan TopicMessage // The channels of the subscriptions of this node, by topic
+	seen        map[string]time.Time           // When each recently delivered message was delivered, by ID
+}
+
+// AddSubscriber makes this node forward the messages of the topic to the contact until the time to live has passed,
+// replacing an earlier subscription of the contact. A new subscriber is refused if the topic already has
+// MaxSubscribersPerTopic subscribers.
+func (pubSub *PubSub) AddSubscriber(topic string, contact Contact, ttl time.Duration) error {
+	pubSub.lock.Lock()
+	defer pubSub.lock.Unlock()
+
+	if pubSub.subscribers == nil {
+		pubSub.subscribers = make(map[[KeySize]byte][]subscriber)
+	}
+	key := NewTopicKey(topic)
+	subscribers := pubSub.unexpired(key)
+	for i := range subscribers {
+		if subscribers[i].contact.ID.Equals(contact.ID) {
+			subscribers[i].expiration = time.Now().Add(ttl)
+			return nil
+		}
+	}
+	if len(subscribers) >= MaxSubscribersPerTopic {
+		return errors.New("the topic has too many subscribers")
+	}
+	pubSub.subscribers[key.Hash] = append(subscribers, subscriber{contact: contact, expiration: time.Now().Add(ttl)})
+	return nil
+}
+
+// GetSubscribers returns the contacts subscribed to the topic whose subscriptions have not expired.
+func (pubSub *PubSub) GetSubscribers(topic string) []Contact {
+	pubSub.lock.Lock()
+	defer pubSub.lock.Unlock()
+
+	contacts := []Contact{}
+	for _, subscriber := range pubSub.unexpired(NewTopicKey(topic)) {
+		contacts = append(contacts, subscriber.contact)
+	}
+	return contacts
+}
+
+// unexpired drops the expired subscriptions of the key and returns the others. The lock must be held by the caller.
+func (pubSub *PubSub) unexpired(key *Key) []subscriber {
+	now := time.Now()
+	subscribers := []subscriber{}
+	for _, subscriber := range pubSub.subscribers[key.Hash] {
+		if subscriber.expiration.After(now) {
+			subscribers = append(subscribers, subscriber)
+		}
+	}
+	if len(subscribers) > 0 {
+		pubSub.subscribers[key.Hash] = subscribers
+	} else {
+		delete(pubSub.subscribers, key.Hash)
+	}
+	return subscribers
+}
+
+// listen returns a channel receiving the messages of the topic delivered to this node.
+func (pubSub *PubSub) listen(topic string) chan TopicMessage {
+	pubSub.lock.Lock()
+	defer pubSub.lock.Unlock()
+
+	if pubSub.listeners == nil {
+		pubSub.listeners = make(map[string][]chan TopicMessage)
+	}
+	messages := make(chan TopicMessage, topicBufferSize)
+	pubSub.listeners[topic] = append(pubSub.listeners[topic], messages)
+	return messages
+}
+
+// unlisten stops delivering the messages of the topic to the channel, and closes it.
+func (pubSub *PubSub) unlisten(topic string, messages chan TopicMessage) {
+	pubSub.lock.Lock()
+	defer pubSub.lock.Unlock()
+
+	listeners := pubSub.listeners[topic]
+	for i := range listeners {
+		if listeners[i] == messages {
+			pubSub.listeners[topic] = append(listeners[:i:i], listeners[i+1:]...)
+			close(messages)
+			break
+		}
+	}
+	if len(pubSub.listeners[topic]) == 0 {
+		delete(pubSub.listeners, topic)
+	}
+}
+
+// Deliver passes the message to every subscription of this node to its topic, unless it has been delivered already.
+// A subscription whose channel is full misses the message.
+func (pubSub *PubSub) Deliver(message TopicMessage) {
+	pubSub.lock.Lock()
+	defer pubSub.lock.Unlock()
+
+	if pubSub.seen == nil {
+		pubSub.seen = make(map[string]time.Time)
+	}
+	now := time.Now()
+	for id, delivered := range pubSub.seen {
+		if now.Sub(delivered) > seenMessageWindow {
+			delete(pubSub.seen, id)
+		}
+	}
+	if _, ok := pubSub.seen[message.ID]; ok {
+		return
+	}
+	pubSub.seen[message.ID] = now
+
+	for _, messages := range pubSub.listeners[message.Topic] {
+		select {
+		case messages <- message:
+		default:
+			logger.Log("Dropped a message of the topic " + message.Topic + ", since the subscription is not read")
+		}
+	}
+}
+
+// forwardTopicMessage sends the message to every subscriber of its topic in the background, and returns how many
+// subscribers it is sent to.
+func (kademliaNode *KademliaNodeImplementation) forwardTopicMessage(message TopicMessage) int {
+	subscribers := kademliaNode.pubSub.GetSubscribers(message.Topic)
+	me := kademliaNode.RoutingTable.Me
+	for _, contact := range subscribers {
+		go func(contact Contact) {
+			err := kademliaNode.Network.SendDeliverMessage(&me, &contact, message)
+			if err != nil {
+				logger.Log("Failed to deliver a message of the topic " + message.Topic + " to " + contact.Ip + ": " + err.Error())
+			}
+		}(contact)
+	}
+	return len(subscribers)
+}
+
+// Subscribe receives the messages published to the topic until the subscription is closed. The subscription is
+// announced to the k closest nodes to the key of the topic, and announced again each half SubscriptionTTL.
+func (kademlia *KademliaImplementation) Subscribe(topic string) (*Subscription, error) {
+	pubSub := kademlia.KademliaNode.GetPubSub()
+	messages := pubSub.listen(topic)
+
+	_, err := kademlia.announceSubscription(topic)
+	if err != nil {
+		pubSub.unlisten(topic, messages)
+		return nil, err
+	}
+
+	stop := make(chan bool)
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(SubscriptionTTL / 2):
+				_, err := kademlia.announceSubscription(topic)
+				if err != nil {
+					logger.Log("Failed to renew the subscription to the topic " + topic + ": " + err.Error())
+				}
+			}
+		}
+	}()
+
+	return NewSubscription(topic, messages, func() {
+		close(stop)
+		pubSub.unlisten(topic, messages)
+	}), nil
+}
+
+// announceSubscription sends a SUBSCRIBE to each of the k closest nodes to the key of the topic in parallel.
+func (kademlia *KademliaImplementation) announceSubscription(topic string) (*StoreResult, error) {
+	key := NewTopicKey(topic)
+	me := kademlia.KademliaNode.GetRoutingTable().Me
+	return kademlia.sendToClosest(key, "no rendezvous point recorded the subscription", func(contact Contact) error {
+		return kademlia.Network.SendSubscribeMessage(&me, &contact, topic, SubscriptionTTL)
+	})
+}
+
+// Publish sends the data to every subscriber of the topic, through the k closest nodes to the key of the topic. The
+// result lists which of them accepted the message.
+func (kademlia *KademliaImplementation) Publish(topic string, data []byte) (*StoreResult, error) {
+	id := make([]byte, 16)
+	_, err := rand.Read(id)
+	if err != nil {
+		return nil, err
+	}
+	me := kademlia.KademliaNode.GetRoutingTable().Me
+	message := TopicMessage{ID: hex.EncodeToString(id), Topic: topic, Data: data, Publisher: me, Time: time.Now()}
+
+	return kademlia.sendToClosest(NewTopicKey(topic), "no rendezvous point accepted the message", func(contact Contact) error {
+		return kademlia.Network.SendPublishMessage(&me, &contact, message)
+	})
+}
+
+// sendToClosest calls send with each of the k closest nodes to the key in parallel, and returns what every node
+// answered. An error with the given message is returned with the result if none of them acknowledged it.
+func (kademlia *KademliaImplementation) sendToClosest(key *Key, noneMessage string, send func(contact Contact) error) (*StoreResult, error) {
+	contacts, err := kademlia.LookupContact(key.GetKademliaIdRepresentationOfKey())
+	if err != nil {
+		return nil, err
+	}
+
+	result := &StoreResult{Key: key, Replicas: make([]StoreReplicaResult, len(contacts))}
+	var waitGroup sync.WaitGroup
+	for i, contact := range contacts {
+		waitGroup.Add(1)
+		go func(i int, contact Contact) {
+			defer waitGroup.Done()
+			result.Replicas[i] = newStoreReplicaResult(contact, send(contact))
+		}(i, contact)
+	}
+	waitGroup.Wait()
+
+	if len(result.GetContacts(STORE_ACKNOWLEDGED)) <= 0 {
+		return result, errors.New(noneMessage)
+	}
+	return result, nil
+}
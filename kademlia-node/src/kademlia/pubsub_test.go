@@ -0,0 +1,140 @@
+package kademlia
+
+import (
+	"encoding/json"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// NetworkPubSubMock records the contacts sent a SUBSCRIBE, and delivers each published message to the subscriber once
+// per rendezvous point, like the rendezvous points would.
+type NetworkPubSubMock struct {
+	NetworkMock
+	lock       sync.Mutex
+	subscribed []int
+	subscriber *PubSub
+}
+
+func (network *NetworkPubSubMock) SendSubscribeMessage(from *Contact, contact *Contact, topic string, ttl time.Duration) error {
+	network.lock.Lock()
+	defer network.lock.Unlock()
+	network.subscribed = append(network.subscribed, contact.Port)
+	return nil
+}
+
+func (network *NetworkPubSubMock) SendPublishMessage(from *Contact, contact *Contact, message TopicMessage) error {
+	network.subscriber.Deliver(message)
+	return nil
+}
+
+func TestPubSubExpiresSubscribers(t *testing.T) {
+	pubSub := PubSub{}
+	contact := NewContact(GenerateNewKademliaID("0000000000000000000000000000000000000001"), "127.0.0.1", 1)
+	other := NewContact(GenerateNewKademliaID("0000000000000000000000000000000000000002"), "127.0.0.1", 2)
+
+	assert.NoError(t, pubSub.AddSubscriber("news", contact, SubscriptionTTL))
+	assert.NoError(t, pubSub.AddSubscriber("news", contact, SubscriptionTTL))
+	assert.NoError(t, pubSub.AddSubscriber("news", other, 50*time.Millisecond))
+	assert.Len(t, pubSub.GetSubscribers("news"), 2)
+	assert.Empty(t, pubSub.GetSubscribers("weather"))
+
+	time.Sleep(100 * time.Millisecond)
+	subscribers := pubSub.GetSubscribers("news")
+	if assert.Len(t, subscribers, 1) {
+		assert.True(t, subscribers[0].ID.Equals(contact.ID))
+	}
+}
+
+func TestPubSubLimitsSubscribersPerTopic(t *testing.T) {
+	pubSub := PubSub{}
+
+	for i := 0; i < MaxSubscribersPerTopic; i++ {
+		contact := NewContact(NewRandomKademliaID(), "127.0.0.1", i)
+		assert.NoError(t, pubSub.AddSubscriber("news", contact, SubscriptionTTL), strconv.Itoa(i))
+	}
+	assert.Error(t, pubSub.AddSubscriber("news", NewContact(NewRandomKademliaID(), "127.0.0.1", 0), SubscriptionTTL))
+}
+
+func TestPubSubDeliversMessagesOnce(t *testing.T) {
+	pubSub := PubSub{}
+	messages := pubSub.listen("news")
+
+	pubSub.Deliver(TopicMessage{ID: "1", Topic: "news", Data: []byte("first")})
+	pubSub.Deliver(TopicMessage{ID: "1", Topic: "news", Data: []byte("first")})
+	pubSub.Deliver(TopicMessage{ID: "2", Topic: "weather", Data: []byte("other")})
+	pubSub.Deliver(TopicMessage{ID: "3", Topic: "news", Data: []byte("second")})
+
+	assert.Equal(t, []byte("first"), (<-messages).Data)
+	assert.Equal(t, []byte("second"), (<-messages).Data)
+	assert.Empty(t, messages)
+
+	pubSub.unlisten("news", messages)
+	_, ok := <-messages
+	assert.False(t, ok)
+}
+
+func TestSubscribeAndPublish(t *testing.T) {
+	network := &NetworkPubSubMock{}
+	kademlia := createQuorumTestKademlia(network)
+	network.subscriber = kademlia.KademliaNode.GetPubSub()
+
+	subscription, err := kademlia.Subscribe("news")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []int{1, 2, 3}, network.subscribed)
+
+	result, err := kademlia.Publish("news", []byte("hello"))
+	assert.NoError(t, err)
+	assert.Len(t, result.GetContacts(STORE_ACKNOWLEDGED), 3)
+	assert.True(t, result.Key.Equals(NewTopicKey("news")))
+
+	// Every rendezvous point delivers the message, but it is received once
+	message := <-subscription.Messages
+	assert.Equal(t, []byte("hello"), message.Data)
+	assert.Empty(t, subscription.Messages)
+
+	subscription.Close()
+	subscription.Close()
+	_, ok := <-subscription.Messages
+	assert.False(t, ok)
+}
+
+func TestSubscribeWithoutRendezvousPoints(t *testing.T) {
+	kademlia := createQuorumTestKademlia(&NetworkMock{})
+
+	_, err := kademlia.Subscribe("news")
+	assert.Error(t, err)
+	_, err = kademlia.Publish("news", []byte("hello"))
+	assert.Error(t, err)
+}
+
+func TestSubscribeMessageRefusesOtherSubscriber(t *testing.T) {
+	kademliaNode := createTombstoneTestNode(3009)
+	messageHandler := &MessageHandlerImplementation{
+		kademliaNode: kademliaNode,
+	}
+	sendSubscribe := func(from Contact, senderIp string) SubscribeResponse {
+		bytes, err := json.Marshal(NewSubscribeMessage(from, "news", SubscriptionTTL))
+		assert.NoError(t, err)
+		response, err := messageHandler.HandleMessage(bytes, senderIp)
+		assert.NoError(t, err)
+		var subscribeResponse SubscribeResponse
+		json.Unmarshal(response, &subscribeResponse)
+		return subscribeResponse
+	}
+
+	// A sender cannot subscribe another node, which would then be flooded with the messages of the topic
+	forged := NewContact(GenerateNewKademliaID("0000000000000000000000000000000000000001"), "10.0.0.1", 1)
+	assert.False(t, sendSubscribe(forged, "10.0.0.2").SubscribeSuccess)
+	assert.Empty(t, kademliaNode.GetPubSub().GetSubscribers("news"))
+
+	assert.True(t, sendSubscribe(forged, "10.0.0.1").SubscribeSuccess)
+	subscribers := kademliaNode.GetPubSub().GetSubscribers("news")
+	if assert.Len(t, subscribers, 1) {
+		assert.Equal(t, "10.0.0.1", subscribers[0].Ip)
+		assert.Equal(t, 1, subscribers[0].Port)
+	}
+}
@@ -0,0 +1,171 @@
+package kademlia
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/arianfiftyone/src/logger"
+)
+
+// DefaultReadQuorum is a majority of the k closest replicas.
+const DefaultReadQuorum = NumberOfClosestNodesToRetrieved/2 + 1
+
+// ReplicaState is what a replica answered a quorum read with, compared to the value the read selected.
+type ReplicaState string
+
+const (
+	REPLICA_UP_TO_DATE  ReplicaState = "UP_TO_DATE"  // Holds the selected value
+	REPLICA_STALE       ReplicaState = "STALE"       // Holds another valid value, which the selected one replaces
+	REPLICA_MISSING     ReplicaState = "MISSING"     // Does not hold the key
+	REPLICA_INVALID     ReplicaState = "INVALID"     // Holds a value that its validator refuses
+	REPLICA_UNREACHABLE ReplicaState = "UNREACHABLE" // Did not answer
+	REPLICA_PENDING     ReplicaState = "PENDING"     // Had not answered yet when a quorum of replicas agreed
+)
+
+// ReplicaResult is the state of one of the k closest replicas of a key.
+type ReplicaResult struct {
+	Contact  Contact      `json:"contact"`
+	State    ReplicaState `json:"state"`
+	Repaired bool         `json:"repaired"` // The selected value has been stored at the replica again
+}
+
+// ReplicaReport describes how consistent the k closest replicas of a key are.
+type ReplicaReport struct {
+	Replicas []ReplicaResult `json:"replicas"`
+	Answered int             `json:"answered"`
+}
+
+// IsConsistent reports whether every replica answered with the selected value, not counting the pending ones.
+func (report ReplicaReport) IsConsistent() bool {
+	for _, replica := range report.Replicas {
+		if replica.State != REPLICA_UP_TO_DATE && replica.State != REPLICA_PENDING {
+			return false
+		}
+	}
+	return true
+}
+
+type replicaAnswer struct {
+	contact Contact
+	value   *Value
+	ttl     time.Duration
+	err     error
+}
+
+// LookupDataWithQuorum asks each of the k closest contacts to the key for its value, and fails unless at least quorum
+// of them answer. It returns as soon as quorum replicas answer with the same value, the replicas which have not
+// answered by then are reported as pending. Otherwise the value the validator of its namespace selects among the
+// answers is returned, together with a report of what every replica holds. With repair, the selected value is stored
+// again at the replicas that lack it or hold a stale version, which is read repair. A repaired replica gets what is
+// left of the lifetime of the value at the replicas which hold it, so that a repair does not extend it. The quorum must
+// be at least one, a quorum larger than k is lowered to k.
+func (kademlia *KademliaImplementation) LookupDataWithQuorum(key *Key, quorum int, repair bool) (*Value, ReplicaReport, error) {
+	report := ReplicaReport{Replicas: []ReplicaResult{}}
+	if quorum < 1 {
+		return nil, report, errors.New("the quorum must be at least one")
+	}
+	quorum = min(quorum, NumberOfClosestNodesToRetrieved)
+
+	contacts, err := kademlia.LookupContact(key.GetKademliaIdRepresentationOfKey())
+	if err != nil {
+		return nil, report, err
+	}
+	if quorum > len(contacts) {
+		return nil, report, errors.New("the quorum is larger than the number of replicas")
+	}
+
+	me := kademlia.KademliaNode.GetRoutingTable().Me
+	// Buffered, so that the replicas answering after the quorum agreed do not block
+	answers := make(chan replicaAnswer, len(contacts))
+	for _, contact := range contacts {
+		go func(contact Contact) {
+			value, ttl, err := kademlia.Network.SendFindDataMessageWithTTL(&me, &contact, key)
+			answers <- replicaAnswer{contact, value, ttl, err}
+		}(contact)
+	}
+
+	validators := kademlia.KademliaNode.GetValidators()
+	values := []Value{}
+	replicaAnswers := []replicaAnswer{}
+	answered := make(map[KademliaID]bool)
+	for range contacts {
+		answer := <-answers
+		answered[*answer.contact.ID] = true
+		result := ReplicaResult{Contact: answer.contact, State: REPLICA_MISSING}
+		if answer.err != nil {
+			result.State = REPLICA_UNREACHABLE
+			answer.value = nil
+		} else {
+			report.Answered++
+			if answer.value != nil && validators.Validate(key, *answer.value) != nil {
+				result.State = REPLICA_INVALID
+				kademlia.KademliaNode.reportMisbehaviour(answer.contact, "answered a FIND_DATA with an invalid value")
+				answer.value = nil
+			} else if answer.value != nil && (len(values) == 0 || answer.value.Namespace == values[0].Namespace) {
+				values = append(values, *answer.value)
+			}
+		}
+		report.Replicas = append(report.Replicas, result)
+		replicaAnswers = append(replicaAnswers, answer)
+
+		if answer.value != nil && countMatching(values, *answer.value) >= quorum {
+			break
+		}
+	}
+	for _, contact := range contacts {
+		if !answered[*contact.ID] {
+			report.Replicas = append(report.Replicas, ReplicaResult{Contact: contact, State: REPLICA_PENDING})
+		}
+	}
+
+	if report.Answered < quorum {
+		return nil, report, errors.New("only " + strconv.Itoa(report.Answered) + " of the " + strconv.Itoa(quorum) + " replicas required answered")
+	}
+	if len(values) == 0 {
+		return nil, report, nil
+	}
+	best, err := validators.Select(key, values)
+	if err != nil {
+		return nil, report, err
+	}
+	selected := values[best]
+
+	var ttl time.Duration
+	for i, answer := range replicaAnswers {
+		if answer.value == nil {
+			continue
+		}
+		if bytes.Equal(answer.value.Data, selected.Data) {
+			report.Replicas[i].State = REPLICA_UP_TO_DATE
+			ttl = max(ttl, answer.ttl)
+		} else {
+			report.Replicas[i].State = REPLICA_STALE
+		}
+	}
+	if repair && ttl > 0 && kademlia.KademliaNode.clampTTL(ttl) <= ttl {
+		for i := range report.Replicas {
+			replica := &report.Replicas[i]
+			if replica.State == REPLICA_STALE || replica.State == REPLICA_MISSING {
+				replica.Repaired = kademlia.Network.SendStoreMessage(&me, &replica.Contact, key, selected, ttl) == nil
+			}
+		}
+	}
+	if !report.IsConsistent() {
+		logger.Log("The replicas of the data object " + key.GetHashString() + " disagree")
+	}
+
+	return &selected, report, nil
+}
+
+// countMatching returns how many of the values hold the same data as the value.
+func countMatching(values []Value, value Value) int {
+	count := 0
+	for _, other := range values {
+		if bytes.Equal(other.Data, value.Data) {
+			count++
+		}
+	}
+	return count
+}
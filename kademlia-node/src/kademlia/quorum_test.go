@@ -0,0 +1,138 @@
+package kademlia
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type NetworkReplicaMock struct {
+	NetworkStoreMock
+	values      map[int]*Value // The value each contact answers a FIND_DATA with, by port
+	ttl         time.Duration  // What is left of the lifetime of every value
+	unreachable map[int]bool
+	blocked     map[int]chan struct{} // The contacts which only answer once the channel is closed, by port
+}
+
+func (network *NetworkReplicaMock) SendFindDataMessage(from *Contact, contact *Contact, key *Key) ([]Contact, *Value, error) {
+	value, _, err := network.SendFindDataMessageWithTTL(from, contact, key)
+	return nil, value, err
+}
+
+func (network *NetworkReplicaMock) SendFindDataMessageWithTTL(from *Contact, contact *Contact, key *Key) (*Value, time.Duration, error) {
+	if release, ok := network.blocked[contact.Port]; ok {
+		<-release
+	}
+	if network.unreachable[contact.Port] {
+		return nil, 0, errors.New("timeout")
+	}
+	return network.values[contact.Port], network.ttl, nil
+}
+
+func createQuorumTestKademlia(network Network) KademliaImplementation {
+	kademlia := CreateMockedKademlia(GenerateNewKademliaID("0000000000000000000000000000000000000000"), "127.0.0.1", 0)
+	kademlia.Network = network
+	kademlia.KademliaNode.GetRoutingTable().AddContact(NewContact(GenerateNewKademliaID("0000000000000000000000000000000000000001"), "127.0.0.1", 1))
+	kademlia.KademliaNode.GetRoutingTable().AddContact(NewContact(GenerateNewKademliaID("0000000000000000000000000000000000000002"), "127.0.0.1", 2))
+	kademlia.KademliaNode.GetRoutingTable().AddContact(NewContact(GenerateNewKademliaID("0000000000000000000000000000000000000003"), "127.0.0.1", 3))
+	return kademlia
+}
+
+func getReplicaStates(report ReplicaReport) map[int]ReplicaState {
+	states := make(map[int]ReplicaState)
+	for _, replica := range report.Replicas {
+		states[replica.Contact.Port] = replica.State
+	}
+	return states
+}
+
+func TestLookupDataWithQuorumReportsMissingReplica(t *testing.T) {
+	value := NewTextValue("value")
+	network := &NetworkReplicaMock{values: map[int]*Value{1: &value, 2: &value}, ttl: DefaultTTL / 2}
+	kademlia := createQuorumTestKademlia(network)
+
+	found, report, err := kademlia.LookupDataWithQuorum(value.GetKey(), 3, true)
+
+	assert.NoError(t, err)
+	if assert.NotNil(t, found) {
+		assert.Equal(t, "value", string(found.Data))
+	}
+	assert.False(t, report.IsConsistent())
+	assert.Equal(t, map[int]ReplicaState{1: REPLICA_UP_TO_DATE, 2: REPLICA_UP_TO_DATE, 3: REPLICA_MISSING}, getReplicaStates(report))
+
+	// Read repair stores the value at the replica that lacks it, for what is left of its lifetime at the others
+	if assert.Len(t, network.stored, 1) {
+		assert.Equal(t, 3, network.stored[0].Port)
+		assert.Equal(t, DefaultTTL/2, network.ttls[0])
+	}
+}
+
+func TestLookupDataWithQuorumReturnsOnceQuorumAgrees(t *testing.T) {
+	value := NewTextValue("value")
+	release := make(chan struct{})
+	defer close(release)
+	network := &NetworkReplicaMock{values: map[int]*Value{1: &value, 2: &value}, ttl: DefaultTTL, blocked: map[int]chan struct{}{3: release}}
+	kademlia := createQuorumTestKademlia(network)
+
+	found, report, err := kademlia.LookupDataWithQuorum(value.GetKey(), 2, true)
+
+	assert.NoError(t, err)
+	if assert.NotNil(t, found) {
+		assert.Equal(t, "value", string(found.Data))
+	}
+	assert.True(t, report.IsConsistent())
+	assert.Equal(t, map[int]ReplicaState{1: REPLICA_UP_TO_DATE, 2: REPLICA_UP_TO_DATE, 3: REPLICA_PENDING}, getReplicaStates(report))
+	assert.Empty(t, network.stored)
+}
+
+func TestLookupDataWithQuorumSelectsNewestRecord(t *testing.T) {
+	_, privateKey, _ := ed25519.GenerateKey(nil)
+	older, _ := NewRecord(privateKey, nil, 1, NewTextValue("older value")).ToValue()
+	newer, _ := NewRecord(privateKey, nil, 2, NewTextValue("newer value")).ToValue()
+	invalid := NewTextValue("invalid value")
+	network := &NetworkReplicaMock{values: map[int]*Value{1: &older, 2: &newer, 3: &invalid}}
+	kademlia := createQuorumTestKademlia(network)
+
+	found, report, err := kademlia.LookupDataWithQuorum(NewRecordKey(privateKey.Public().(ed25519.PublicKey), nil), DefaultReadQuorum, false)
+
+	assert.NoError(t, err)
+	if assert.NotNil(t, found) {
+		assert.Equal(t, newer.Data, found.Data)
+	}
+	assert.Equal(t, map[int]ReplicaState{1: REPLICA_STALE, 2: REPLICA_UP_TO_DATE, 3: REPLICA_INVALID}, getReplicaStates(report))
+	assert.Empty(t, network.stored)
+}
+
+func TestLookupDataWithQuorumFailsWithoutQuorum(t *testing.T) {
+	value := NewTextValue("value")
+	network := &NetworkReplicaMock{values: map[int]*Value{1: &value}, unreachable: map[int]bool{2: true, 3: true}}
+	kademlia := createQuorumTestKademlia(network)
+
+	_, report, err := kademlia.LookupDataWithQuorum(value.GetKey(), 2, true)
+
+	assert.Error(t, err)
+	assert.Equal(t, 1, report.Answered)
+	assert.Equal(t, REPLICA_UNREACHABLE, getReplicaStates(report)[2])
+	assert.Empty(t, network.stored)
+}
+
+func TestLookupDataWithQuorumBoundsTheQuorum(t *testing.T) {
+	value := NewTextValue("value")
+	network := &NetworkReplicaMock{values: map[int]*Value{1: &value, 2: &value, 3: &value}, ttl: DefaultTTL}
+	kademlia := createQuorumTestKademlia(network)
+
+	_, _, err := kademlia.LookupDataWithQuorum(value.GetKey(), 0, false)
+	assert.Error(t, err)
+	_, _, err = kademlia.LookupDataWithQuorum(value.GetKey(), -1, false)
+	assert.Error(t, err)
+
+	found, report, err := kademlia.LookupDataWithQuorum(value.GetKey(), NumberOfClosestNodesToRetrieved+1, false)
+	assert.NoError(t, err)
+	if assert.NotNil(t, found) {
+		assert.Equal(t, "value", string(found.Data))
+	}
+	assert.Equal(t, NumberOfClosestNodesToRetrieved, report.Answered)
+}
@@ -0,0 +1,27 @@
+package kademlia
+
+import (
+	"sync"
+	"time"
+)
+
+// rateLimiter spaces out calls to Wait so that they return at most once per interval.
+// The zero value is ready to use.
+type rateLimiter struct {
+	lock sync.Mutex
+	next time.Time
+}
+
+// Wait blocks until at least interval has passed since the previous call returned.
+func (limiter *rateLimiter) Wait(interval time.Duration) {
+	limiter.lock.Lock()
+	now := time.Now()
+	if limiter.next.Before(now) {
+		limiter.next = now
+	}
+	delay := limiter.next.Sub(now)
+	limiter.next = limiter.next.Add(interval)
+	limiter.lock.Unlock()
+
+	time.Sleep(delay)
+}
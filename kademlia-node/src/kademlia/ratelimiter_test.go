@@ -0,0 +1,20 @@
+package kademlia
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiterSpacesOutCalls(t *testing.T) {
+	limiter := rateLimiter{}
+	interval := time.Millisecond * 20
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		limiter.Wait(interval)
+	}
+
+	assert.GreaterOrEqual(t, time.Since(start), interval*4)
+}
@@ -0,0 +1,94 @@
+package kademlia
+
+import (
+	"crypto/ed25519"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+)
+
+// RecordContentType is the content type of a Value holding a mutable Record.
+const RecordContentType = "application/vnd.kademlia.record+json"
+
+// Record is a mutable value, modelled on BitTorrent BEP44. It is stored under the hash of the public key of its
+// publisher and a salt, so one publisher can have one record per salt. The publisher signs the salt, the sequence
+// number and the value, and raises the sequence number each time the value is updated.
+type Record struct {
+	PublicKey ed25519.PublicKey `json:"publicKey"`
+	Salt      []byte            `json:"salt,omitempty"`
+	Sequence  uint64            `json:"sequence"`
+	Value     Value             `json:"value"`
+	Signature []byte            `json:"signature"`
+}
+
+// NewRecordKey returns the key the record of the public key and salt is stored under.
+func NewRecordKey(publicKey ed25519.PublicKey, salt []byte) *Key {
+	return NewNamespacedRecordKey(RecordNamespace, publicKey, salt)
+}
+
+// NewNamespacedRecordKey returns the key of the records of the public key and salt in a namespace validated by a
+// RecordValidator, which is the hash of the public key and salt prefixed with the namespace.
+func NewNamespacedRecordKey(namespace string, publicKey ed25519.PublicKey, salt []byte) *Key {
+	key, _ := NewKeyWithHashFunction(namespacedName(namespace, append(append([]byte{}, publicKey...), salt...)), DefaultHashFunction)
+	return key
+}
+
+// NewRecord creates a record holding the value, signed with the private key.
+func NewRecord(privateKey ed25519.PrivateKey, salt []byte, sequence uint64, value Value) Record {
+	record := Record{
+		PublicKey: privateKey.Public().(ed25519.PublicKey),
+		Salt:      salt,
+		Sequence:  sequence,
+		Value:     value,
+	}
+	record.Signature = ed25519.Sign(privateKey, record.signedBytes())
+	return record
+}
+
+// RecordFromValue decodes the record held by a value.
+func RecordFromValue(value Value) (Record, error) {
+	var record Record
+	if value.ContentType != RecordContentType {
+		return record, errors.New("the value is not a record")
+	}
+	err := json.Unmarshal(value.Data, &record)
+	return record, err
+}
+
+// GetKey returns the key the record is stored under.
+func (record Record) GetKey() *Key {
+	return NewRecordKey(record.PublicKey, record.Salt)
+}
+
+// ToValue encodes the record as a value that can be stored in the network.
+func (record Record) ToValue() (Value, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return Value{}, err
+	}
+	value := NewValue(data, RecordContentType)
+	value.Namespace = RecordNamespace
+	return value, nil
+}
+
+// Verify checks that the record is signed by the owner of its public key.
+func (record Record) Verify() error {
+	if len(record.PublicKey) != ed25519.PublicKeySize {
+		return errors.New("the record has an invalid public key")
+	}
+	if !ed25519.Verify(record.PublicKey, record.signedBytes(), record.Signature) {
+		return errors.New("the record has an invalid signature")
+	}
+	return nil
+}
+
+// signedBytes returns what the publisher signs: the length prefixed salt, the sequence number, the length prefixed
+// content type and the data of the value.
+func (record Record) signedBytes() []byte {
+	signed := binary.AppendUvarint(nil, uint64(len(record.Salt)))
+	signed = append(signed, record.Salt...)
+	signed = binary.BigEndian.AppendUint64(signed, record.Sequence)
+	signed = binary.AppendUvarint(signed, uint64(len(record.Value.ContentType)))
+	signed = append(signed, record.Value.ContentType...)
+	return append(signed, record.Value.Data...)
+}
@@ -0,0 +1,159 @@
+package kademlia
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type NetworkRecordMock struct {
+	NetworkMock
+	records map[int]Record // The record each contact answers a FIND_DATA with, by port
+}
+
+func (network *NetworkRecordMock) SendFindDataMessage(from *Contact, contact *Contact, key *Key) ([]Contact, *Value, error) {
+	record, ok := network.records[contact.Port]
+	if !ok {
+		return nil, nil, nil
+	}
+	value, err := record.ToValue()
+	return nil, &value, err
+}
+
+func TestRecordVerify(t *testing.T) {
+	_, privateKey, _ := ed25519.GenerateKey(nil)
+	record := NewRecord(privateKey, []byte("salt"), 1, NewTextValue("value"))
+
+	assert.NoError(t, record.Verify())
+	assert.Equal(t, NewRecordKey(privateKey.Public().(ed25519.PublicKey), []byte("salt")), record.GetKey())
+
+	tampered := record
+	tampered.Value = NewTextValue("other value")
+	assert.Error(t, tampered.Verify())
+
+	tampered = record
+	tampered.Sequence = 2
+	assert.Error(t, tampered.Verify())
+
+	tampered = record
+	tampered.Signature = nil
+	assert.Error(t, tampered.Verify())
+}
+
+func TestStoreMessageWithStaleRecord(t *testing.T) {
+	kademliaNode := NewKademliaNode("127.0.0.1", 3007, false)
+	messageHandler := &MessageHandlerImplementation{
+		kademliaNode: kademliaNode,
+	}
+	_, privateKey, _ := ed25519.GenerateKey(nil)
+
+	sendStore := func(record Record) StoreResponse {
+		value, _ := record.ToValue()
+		store := NewStoreMessage(NewContact(NewRandomKademliaID(), "127.0.0.1", 80), record.GetKey(), value, DefaultTTL)
+		bytes, _ := json.Marshal(store)
+		response, err := messageHandler.HandleMessage(bytes, "127.0.0.1")
+		if err != nil {
+			assert.Fail(t, err.Error())
+		}
+		var storeResponse StoreResponse
+		json.Unmarshal(response, &storeResponse)
+		return storeResponse
+	}
+
+	assert.True(t, sendStore(NewRecord(privateKey, nil, 2, NewTextValue("value"))).StoreSuccess)
+	assert.False(t, sendStore(NewRecord(privateKey, nil, 1, NewTextValue("older value"))).StoreSuccess)
+	assert.True(t, sendStore(NewRecord(privateKey, nil, 3, NewTextValue("newer value"))).StoreSuccess)
+
+	value, err := kademliaNode.GetDataStore().Get(NewRecordKey(privateKey.Public().(ed25519.PublicKey), nil))
+	assert.NoError(t, err)
+	record, _ := RecordFromValue(value)
+	assert.Equal(t, uint64(3), record.Sequence)
+}
+
+func TestLookupDataReturnsNewestRecord(t *testing.T) {
+	kademlia := CreateMockedKademlia(GenerateNewKademliaID("0000000000000000000000000000000000000000"), "127.0.0.1", 0)
+	_, privateKey, _ := ed25519.GenerateKey(nil)
+	forged := NewRecord(privateKey, nil, 9, NewTextValue("forged value"))
+	forged.Value = NewTextValue("other value")
+	kademlia.Network = &NetworkRecordMock{records: map[int]Record{
+		1: NewRecord(privateKey, nil, 1, NewTextValue("old value")),
+		2: NewRecord(privateKey, nil, 5, NewTextValue("new value")),
+		3: forged,
+	}}
+
+	kademlia.KademliaNode.GetRoutingTable().AddContact(NewContact(GenerateNewKademliaID("0000000000000000000000000000000000000001"), "127.0.0.1", 1))
+	kademlia.KademliaNode.GetRoutingTable().AddContact(NewContact(GenerateNewKademliaID("0000000000000000000000000000000000000002"), "127.0.0.1", 2))
+	kademlia.KademliaNode.GetRoutingTable().AddContact(NewContact(GenerateNewKademliaID("0000000000000000000000000000000000000003"), "127.0.0.1", 3))
+
+	_, value, err := kademlia.LookupData(forged.GetKey())
+
+	assert.NoError(t, err)
+	if assert.NotNil(t, value) {
+		record, err := RecordFromValue(*value)
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(5), record.Sequence)
+		assert.Equal(t, "new value", string(record.Value.Data))
+	}
+}
+
+type NetworkValueMock struct {
+	NetworkMock
+	values map[int]Value // The value each contact answers a FIND_DATA with, by port
+}
+
+func (network *NetworkValueMock) SendFindDataMessage(from *Contact, contact *Contact, key *Key) ([]Contact, *Value, error) {
+	value, ok := network.values[contact.Port]
+	if !ok {
+		return nil, nil, nil
+	}
+	return nil, &value, nil
+}
+
+func TestLookupDataInNamespaceRejectsValueInOtherNamespace(t *testing.T) {
+	kademlia := CreateMockedKademlia(GenerateNewKademliaID("0000000000000000000000000000000000000000"), "127.0.0.1", 0)
+	_, privateKey, _ := ed25519.GenerateKey(nil)
+	record := NewRecord(privateKey, nil, 1, NewTextValue("value"))
+	stored, _ := record.ToValue()
+	network := &NetworkValueMock{values: map[int]Value{
+		1: NewTextValue("impostor"),
+		2: stored,
+	}}
+	kademlia.Network = network
+
+	kademlia.KademliaNode.GetRoutingTable().AddContact(NewContact(GenerateNewKademliaID("0000000000000000000000000000000000000001"), "127.0.0.1", 1))
+	kademlia.KademliaNode.GetRoutingTable().AddContact(NewContact(GenerateNewKademliaID("0000000000000000000000000000000000000002"), "127.0.0.1", 2))
+
+	_, value, err := kademlia.LookupDataInNamespace(record.GetKey(), RecordNamespace)
+	assert.NoError(t, err)
+	if assert.NotNil(t, value) {
+		assert.Equal(t, RecordNamespace, value.Namespace)
+	}
+
+	delete(network.values, 2)
+	_, value, err = kademlia.LookupDataInNamespace(record.GetKey(), RecordNamespace)
+	assert.NoError(t, err)
+	assert.Nil(t, value)
+}
+
+func TestRecordKeyIsPrefixedWithTheNamespace(t *testing.T) {
+	_, privateKey, _ := ed25519.GenerateKey(nil)
+	publicKey := privateKey.Public().(ed25519.PublicKey)
+
+	assert.Equal(t, NewKey(RecordNamespace+"/"+string(publicKey)+"salt"), NewRecordKey(publicKey, []byte("salt")))
+	assert.NotEqual(t, NewKey(string(publicKey)+"salt"), NewRecordKey(publicKey, []byte("salt")))
+}
+
+func TestPutRecordRefusesInvalidRecord(t *testing.T) {
+	kademlia := CreateMockedKademlia(GenerateNewKademliaID("0000000000000000000000000000000000000000"), "127.0.0.1", 0)
+	kademlia.Network = &NetworkStoreMock{}
+	_, privateKey, _ := ed25519.GenerateKey(nil)
+	record := NewRecord(privateKey, nil, 1, NewTextValue("value"))
+	record.Sequence = 2
+
+	_, err := kademlia.PutRecord(record, time.Minute)
+
+	assert.Error(t, err)
+}
@@ -0,0 +1,236 @@
+package kademlia
+
+import (
+	"errors"
+	"strconv"
+)
+
+const (
+	MaxDataShards   = 64 // The most data shards an object can be split into
+	MaxParityShards = 16 // The most parity shards that can be added to an object
+)
+
+// DefaultErasureCoding splits an object into 4 data shards and adds 2 parity shards, so it survives the loss of any
+// 2 shards at one and a half times the size of the object instead of k times.
+var DefaultErasureCoding = ErasureCoding{DataShards: 4, ParityShards: 2}
+
+// ErasureCoding is a Reed-Solomon code over GF(2^8) with DataShards data shards and ParityShards parity shards. The
+// code is systematic: the data shards are the object split into equal parts, and the object can be rebuilt from any
+// DataShards of the shards. The parity shards are computed with a Cauchy matrix, so that every choice of DataShards
+// shards can be inverted.
+type ErasureCoding struct {
+	DataShards   int `json:"dataShards"`
+	ParityShards int `json:"parityShards"`
+}
+
+// GF(2^8) with the polynomial x^8 + x^4 + x^3 + x^2 + 1, whose generator is 2
+var (
+	gfExp [510]byte
+	gfLog [256]byte
+)
+
+func init() {
+	x := 1
+	for i := 0; i < 255; i++ {
+		gfExp[i] = byte(x)
+		gfExp[i+255] = byte(x)
+		gfLog[x] = byte(i)
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= 0x11d
+		}
+	}
+}
+
+func gfMul(a byte, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+int(gfLog[b])]
+}
+
+// gfInv returns the multiplicative inverse of a, which must not be zero.
+func gfInv(a byte) byte {
+	return gfExp[255-int(gfLog[a])]
+}
+
+// Validate returns an error if the numbers of shards are out of bounds.
+func (coding ErasureCoding) Validate() error {
+	if coding.DataShards < 1 || coding.DataShards > MaxDataShards {
+		return errors.New("the number of data shards must be between 1 and " + strconv.Itoa(MaxDataShards))
+	}
+	if coding.ParityShards < 1 || coding.ParityShards > MaxParityShards {
+		return errors.New("the number of parity shards must be between 1 and " + strconv.Itoa(MaxParityShards))
+	}
+	return nil
+}
+
+// TotalShards returns the number of data and parity shards.
+func (coding ErasureCoding) TotalShards() int {
+	return coding.DataShards + coding.ParityShards
+}
+
+// ShardSize returns the size of each shard of an object of the given size.
+func (coding ErasureCoding) ShardSize(size int) int {
+	return max(1, (size+coding.DataShards-1)/coding.DataShards)
+}
+
+// encodingRow returns the coefficients shard i is computed with from the data shards. The rows of the data shards are
+// the identity, and parity shard j has the Cauchy row 1 / ((DataShards + j) + column).
+func (coding ErasureCoding) encodingRow(i int) []byte {
+	row := make([]byte, coding.DataShards)
+	if i < coding.DataShards {
+		row[i] = 1
+		return row
+	}
+	for column := range row {
+		row[column] = gfInv(byte(i) ^ byte(column))
+	}
+	return row
+}
+
+// Encode splits the data into the data shards, padding the last one with zeros, and computes the parity shards.
+func (coding ErasureCoding) Encode(data []byte) ([][]byte, error) {
+	err := coding.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	shardSize := coding.ShardSize(len(data))
+	padded := make([]byte, shardSize*coding.DataShards)
+	copy(padded, data)
+	shards := make([][]byte, coding.TotalShards())
+	for i := 0; i < coding.DataShards; i++ {
+		shards[i] = padded[i*shardSize : (i+1)*shardSize]
+	}
+	for i := coding.DataShards; i < coding.TotalShards(); i++ {
+		shards[i] = coding.combine(coding.encodingRow(i), shards[:coding.DataShards])
+	}
+	return shards, nil
+}
+
+// combine returns the sum of the shards multiplied by the coefficients.
+func (coding ErasureCoding) combine(coefficients []byte, shards [][]byte) []byte {
+	result := make([]byte, len(shards[0]))
+	for i, shard := range shards {
+		if coefficients[i] == 0 {
+			continue
+		}
+		for b := range shard {
+			result[b] ^= gfMul(coefficients[i], shard[b])
+		}
+	}
+	return result
+}
+
+// Reconstruct fills in the missing shards, which are nil, from the others. At least DataShards shards must be present,
+// and all of them must have the same size.
+func (coding ErasureCoding) Reconstruct(shards [][]byte) error {
+	err := coding.Validate()
+	if err != nil {
+		return err
+	}
+	if len(shards) != coding.TotalShards() {
+		return errors.New("the number of shards does not match the erasure coding")
+	}
+
+	present := []int{}
+	for i, shard := range shards {
+		if shard == nil {
+			continue
+		}
+		if len(present) > 0 && len(shard) != len(shards[present[0]]) {
+			return errors.New("the shards have different sizes")
+		}
+		present = append(present, i)
+	}
+	if len(present) < coding.DataShards {
+		return errors.New("only " + strconv.Itoa(len(present)) + " of the " + strconv.Itoa(coding.DataShards) + " shards needed are present")
+	}
+	if len(present) == len(shards) {
+		return nil
+	}
+
+	// The data shards are the inverse of the rows of the first DataShards present shards applied to those shards
+	present = present[:coding.DataShards]
+	matrix := make([][]byte, coding.DataShards)
+	for i, index := range present {
+		matrix[i] = coding.encodingRow(index)
+	}
+	inverse, err := invertMatrix(matrix)
+	if err != nil {
+		return err
+	}
+	sources := make([][]byte, coding.DataShards)
+	for i, index := range present {
+		sources[i] = shards[index]
+	}
+	for i := 0; i < coding.DataShards; i++ {
+		if shards[i] == nil {
+			shards[i] = coding.combine(inverse[i], sources)
+		}
+	}
+	for i := coding.DataShards; i < coding.TotalShards(); i++ {
+		if shards[i] == nil {
+			shards[i] = coding.combine(coding.encodingRow(i), shards[:coding.DataShards])
+		}
+	}
+	return nil
+}
+
+// Join concatenates the data shards and cuts off the padding, returning the object of the given size.
+func (coding ErasureCoding) Join(shards [][]byte, size int) ([]byte, error) {
+	data := []byte{}
+	for i := 0; i < coding.DataShards && i < len(shards); i++ {
+		if shards[i] == nil {
+			return nil, errors.New("data shard " + strconv.Itoa(i) + " is missing")
+		}
+		data = append(data, shards[i]...)
+	}
+	if len(data) < size {
+		return nil, errors.New("the shards are smaller than the object")
+	}
+	return data[:size], nil
+}
+
+// invertMatrix returns the inverse of the square matrix over GF(2^8), by Gauss-Jordan elimination.
+func invertMatrix(matrix [][]byte) ([][]byte, error) {
+	size := len(matrix)
+	work := make([][]byte, size)
+	for i := range matrix {
+		work[i] = make([]byte, 2*size)
+		copy(work[i], matrix[i])
+		work[i][size+i] = 1
+	}
+
+	for column := 0; column < size; column++ {
+		pivot := column
+		for pivot < size && work[pivot][column] == 0 {
+			pivot++
+		}
+		if pivot == size {
+			return nil, errors.New("the matrix is singular")
+		}
+		work[column], work[pivot] = work[pivot], work[column]
+
+		scale := gfInv(work[column][column])
+		for b := range work[column] {
+			work[column][b] = gfMul(work[column][b], scale)
+		}
+		for row := 0; row < size; row++ {
+			factor := work[row][column]
+			if row == column || factor == 0 {
+				continue
+			}
+			for b := range work[row] {
+				work[row][b] ^= gfMul(factor, work[column][b])
+			}
+		}
+	}
+
+	inverse := make([][]byte, size)
+	for i := range work {
+		inverse[i] = work[i][size:]
+	}
+	return inverse, nil
+}
@@ -0,0 +1,53 @@
+package kademlia
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErasureCodingRebuildsFromAnyDataShards(t *testing.T) {
+	coding := ErasureCoding{DataShards: 4, ParityShards: 2}
+	data := make([]byte, 1001)
+	rand.Read(data)
+	shards, err := coding.Encode(data)
+	assert.NoError(t, err)
+	assert.Len(t, shards, 6)
+	assert.Len(t, shards[0], 251)
+
+	for first := 0; first < len(shards); first++ {
+		for second := first + 1; second < len(shards); second++ {
+			damaged := append([][]byte{}, shards...)
+			damaged[first] = nil
+			damaged[second] = nil
+
+			assert.NoError(t, coding.Reconstruct(damaged))
+			assert.Equal(t, shards, damaged)
+			joined, err := coding.Join(damaged, len(data))
+			assert.NoError(t, err)
+			assert.Equal(t, data, joined)
+		}
+	}
+}
+
+func TestErasureCodingNeedsDataShards(t *testing.T) {
+	coding := ErasureCoding{DataShards: 4, ParityShards: 2}
+	shards, _ := coding.Encode([]byte("erasure coded"))
+	shards[0], shards[2], shards[5] = nil, nil, nil
+
+	assert.Error(t, coding.Reconstruct(shards))
+	assert.Error(t, coding.Reconstruct(shards[:5]))
+}
+
+func TestErasureCodingValidate(t *testing.T) {
+	assert.NoError(t, DefaultErasureCoding.Validate())
+	assert.NoError(t, ErasureCoding{DataShards: MaxDataShards, ParityShards: MaxParityShards}.Validate())
+	assert.Error(t, ErasureCoding{DataShards: 0, ParityShards: 2}.Validate())
+	assert.Error(t, ErasureCoding{DataShards: 4, ParityShards: 0}.Validate())
+	assert.Error(t, ErasureCoding{DataShards: MaxDataShards + 1, ParityShards: 2}.Validate())
+	assert.Error(t, ErasureCoding{DataShards: 4, ParityShards: MaxParityShards + 1}.Validate())
+
+	_, err := ErasureCoding{}.Encode([]byte("data"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,91 @@
+package kademlia
+
+import (
+	"sync"
+	"time"
+
+	"github.com/arianfiftyone/src/logger"
+)
+
+const (
+	// ReplicationInterval is how often a node republishes the keys in its data store. It must be
+	// lower than the time to live of the data store, otherwise the replicas expire between two rounds.
+	ReplicationInterval = time.Second * 3
+)
+
+// replicate republishes the data store to the k closest known contacts once every replication interval, and forgets
+// the tombstones that have expired since the last one.
+func (kademlia *KademliaImplementation) replicate() {
+	for {
+		<-time.After(ReplicationInterval)
+		kademlia.KademliaNode.sweepTombstones()
+		kademlia.replicateDataStore()
+	}
+}
+
+// replicationTTL returns the time to live a replica of the item is stored with, which is what is left of the lifetime
+// of the item, so that replicas never outlive the value the publisher stored. Pinned items are kept alive by this node,
+// so their replicas get the whole time to live. The item is not replicated if too little of its lifetime is left for
+// the receiver to keep it without rounding it up to its minimum time to live.
+func replicationTTL(kademliaNode KademliaNode, item DataStoreItem) (time.Duration, bool) {
+	if item.Pinned {
+		return item.TTL, true
+	}
+	ttl := time.Until(item.Expiration)
+	if ttl <= 0 || kademliaNode.clampTTL(ttl) > ttl {
+		return 0, false
+	}
+	return ttl, true
+}
+
+// replicateDataStore sends a STORE for every key in the data store to the k closest contacts in the routing table.
+// A key is skipped if a STORE for it was received during the last replication interval, since the node that
+// sent it is assumed to have stored it at the other k-1 nodes as well. Pinned keys are always republished. Shards are
+// never replicated, since the erasure coding of their value replaces the replicas.
+func (kademlia *KademliaImplementation) replicateDataStore() {
+	me := kademlia.KademliaNode.GetRoutingTable().Me
+
+	for _, item := range kademlia.KademliaNode.GetDataStore().Items() {
+		if item.Value.Namespace == ShardNamespace || (!item.Pinned && time.Since(item.StoreTime) < ReplicationInterval) {
+			continue
+		}
+		ttl, ok := replicationTTL(kademlia.KademliaNode, item)
+		if !ok {
+			continue
+		}
+
+		contacts := kademlia.getReplicationContacts(item.Key)
+		if len(contacts) <= 0 {
+			continue
+		}
+		logger.Log("Replicating the data object " + item.Key.GetHashString())
+
+		var waitGroup sync.WaitGroup
+		for _, contact := range contacts {
+			waitGroup.Add(1)
+			go func(contact Contact, item DataStoreItem) {
+				defer waitGroup.Done()
+				kademlia.Network.SendStoreMessage(&me, &contact, item.Key, item.Value, ttl)
+			}(contact, item)
+		}
+		waitGroup.Wait()
+	}
+}
+
+// getReplicationContacts returns the k closest contacts to the key in the routing table, excluding the node itself.
+func (kademlia *KademliaImplementation) getReplicationContacts(key *Key) []Contact {
+	me := kademlia.KademliaNode.GetRoutingTable().Me
+	closest := kademlia.KademliaNode.GetRoutingTable().FindClosestContacts(key.GetKademliaIdRepresentationOfKey(), NumberOfClosestNodesToRetrieved+1)
+
+	contacts := []Contact{}
+	for _, contact := range closest {
+		if contact.ID.Equals(me.ID) {
+			continue
+		}
+		if len(contacts) >= NumberOfClosestNodesToRetrieved {
+			break
+		}
+		contacts = append(contacts, contact)
+	}
+	return contacts
+}
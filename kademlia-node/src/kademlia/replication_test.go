@@ -0,0 +1,139 @@
+package kademlia
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type NetworkStoreMock struct {
+	NetworkMock
+	lock   sync.Mutex
+	stored []Contact
+	ttls   []time.Duration
+}
+
+func (network *NetworkStoreMock) SendStoreMessage(from *Contact, contact *Contact, key *Key, value Value, ttl time.Duration) error {
+	network.lock.Lock()
+	network.stored = append(network.stored, *contact)
+	network.ttls = append(network.ttls, ttl)
+	network.lock.Unlock()
+	return nil
+}
+
+func TestReplicateDataStore(t *testing.T) {
+	kademlia := CreateMockedKademlia(GenerateNewKademliaID("0000000000000000000000000000000000000000"), "127.0.0.1", 0)
+	network := &NetworkStoreMock{}
+	kademlia.Network = network
+
+	contact1 := NewContact(GenerateNewKademliaID("0000000000000000000000000000000000000001"), "127.0.0.1", 1)
+	contact2 := NewContact(GenerateNewKademliaID("0000000000000000000000000000000000000002"), "127.0.0.1", 2)
+	contact3 := NewContact(GenerateNewKademliaID("0000000000000000000000000000000000000003"), "127.0.0.1", 3)
+	contact4 := NewContact(GenerateNewKademliaID("FFFFFFFF00000000000000000000000000000000"), "127.0.0.1", 4)
+	kademlia.KademliaNode.GetRoutingTable().AddContact(kademlia.KademliaNode.GetRoutingTable().Me)
+	kademlia.KademliaNode.GetRoutingTable().AddContact(contact1)
+	kademlia.KademliaNode.GetRoutingTable().AddContact(contact2)
+	kademlia.KademliaNode.GetRoutingTable().AddContact(contact3)
+	kademlia.KademliaNode.GetRoutingTable().AddContact(contact4)
+
+	key := GetKeyRepresentationOfKademliaId(GenerateNewKademliaID("0000000000000000000000000000000000000000"))
+	dataStore := kademlia.KademliaNode.GetDataStore().(*InMemoryDataStore)
+	dataStore.Insert(key, NewTextValue("value"), DefaultTTL)
+	dataStore.entries[key.Hash].storeTime = time.Now().Add(-ReplicationInterval)
+
+	kademlia.replicateDataStore()
+
+	assert.Len(t, network.stored, NumberOfClosestNodesToRetrieved)
+	assert.True(t, kademlia.FirstSetContainsAllContactsOfSecondSet(network.stored, []Contact{contact1, contact2, contact3}))
+}
+
+func TestReplicateDataStoreSkipsRecentlyStoredKeys(t *testing.T) {
+	kademlia := CreateMockedKademlia(GenerateNewKademliaID("0000000000000000000000000000000000000000"), "127.0.0.1", 0)
+	network := &NetworkStoreMock{}
+	kademlia.Network = network
+
+	kademlia.KademliaNode.GetRoutingTable().AddContact(NewContact(GenerateNewKademliaID("0000000000000000000000000000000000000001"), "127.0.0.1", 1))
+
+	value := "value"
+	kademlia.KademliaNode.GetDataStore().Insert(NewKey(value), NewTextValue(value), DefaultTTL)
+
+	kademlia.replicateDataStore()
+
+	assert.Empty(t, network.stored)
+}
+
+func TestReplicateDataStoreRepublishesPinnedKeys(t *testing.T) {
+	kademlia := CreateMockedKademlia(GenerateNewKademliaID("0000000000000000000000000000000000000000"), "127.0.0.1", 0)
+	network := &NetworkStoreMock{}
+	kademlia.Network = network
+
+	kademlia.KademliaNode.GetRoutingTable().AddContact(NewContact(GenerateNewKademliaID("0000000000000000000000000000000000000001"), "127.0.0.1", 1))
+
+	value := "value"
+	kademlia.KademliaNode.GetDataStore().Insert(NewKey(value), NewTextValue(value), DefaultTTL)
+	kademlia.KademliaNode.GetDataStore().Pin(NewKey(value))
+
+	kademlia.replicateDataStore()
+
+	assert.Len(t, network.stored, 1)
+}
+
+func TestReplicateDataStoreSkipsShards(t *testing.T) {
+	kademlia := CreateMockedKademlia(GenerateNewKademliaID("0000000000000000000000000000000000000000"), "127.0.0.1", 0)
+	network := &NetworkStoreMock{}
+	kademlia.Network = network
+
+	kademlia.KademliaNode.GetRoutingTable().AddContact(NewContact(GenerateNewKademliaID("0000000000000000000000000000000000000001"), "127.0.0.1", 1))
+
+	object := NewKey("object")
+	value, _ := Shard{Object: object.GetHashString(), Coding: DefaultErasureCoding, Size: 4, Data: []byte{1}}.ToValue()
+	key := NewShardKey(object, 0)
+	dataStore := kademlia.KademliaNode.GetDataStore().(*InMemoryDataStore)
+	dataStore.Insert(key, value, DefaultTTL)
+	dataStore.entries[key.Hash].storeTime = time.Now().Add(-ReplicationInterval)
+
+	kademlia.replicateDataStore()
+
+	assert.Empty(t, network.stored)
+}
+
+func TestReplicateDataStoreSendsRemainingTTL(t *testing.T) {
+	kademlia := CreateMockedKademlia(GenerateNewKademliaID("0000000000000000000000000000000000000000"), "127.0.0.1", 0)
+	network := &NetworkStoreMock{}
+	kademlia.Network = network
+
+	kademlia.KademliaNode.GetRoutingTable().AddContact(NewContact(GenerateNewKademliaID("0000000000000000000000000000000000000001"), "127.0.0.1", 1))
+
+	key := NewKey("value")
+	dataStore := kademlia.KademliaNode.GetDataStore().(*InMemoryDataStore)
+	dataStore.Insert(key, NewTextValue("value"), DefaultTTL)
+	dataStore.entries[key.Hash].storeTime = time.Now().Add(-ReplicationInterval)
+	dataStore.entries[key.Hash].expirationTime = time.Now().Add(DefaultTTL / 2)
+
+	kademlia.replicateDataStore()
+
+	assert.Len(t, network.ttls, 1)
+	assert.LessOrEqual(t, network.ttls[0], DefaultTTL/2)
+	assert.Greater(t, network.ttls[0], DefaultTTL/2-time.Second)
+}
+
+func TestReplicateDataStoreSkipsKeysBelowTheMinimumTTL(t *testing.T) {
+	kademlia := CreateMockedKademlia(GenerateNewKademliaID("0000000000000000000000000000000000000000"), "127.0.0.1", 0)
+	kademlia.KademliaNode.(*KademliaNodeImplementation).minTTL = time.Second
+	network := &NetworkStoreMock{}
+	kademlia.Network = network
+
+	kademlia.KademliaNode.GetRoutingTable().AddContact(NewContact(GenerateNewKademliaID("0000000000000000000000000000000000000001"), "127.0.0.1", 1))
+
+	key := NewKey("value")
+	dataStore := kademlia.KademliaNode.GetDataStore().(*InMemoryDataStore)
+	dataStore.Insert(key, NewTextValue("value"), DefaultTTL)
+	dataStore.entries[key.Hash].storeTime = time.Now().Add(-ReplicationInterval)
+	dataStore.entries[key.Hash].expirationTime = time.Now().Add(time.Second / 2)
+
+	kademlia.replicateDataStore()
+
+	assert.Empty(t, network.stored)
+}
@@ -0,0 +1,230 @@
+package kademlia
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultMaxStoredBytes = 64 * 1024 * 1024 // Total size of the values a node stores on behalf of others
+	DefaultMaxStoredKeys  = 100000
+	DefaultMaxOriginShare = 0.5 // Fraction of the capacity a single origin may occupy
+	DefaultEvictionPolicy = EvictFurthestFirst
+)
+
+// EvictionPolicy decides which stored value is removed to make room for a new one once the data store is full.
+type EvictionPolicy int
+
+const (
+	EvictNothing       EvictionPolicy = iota // Refuse new values once the data store is full
+	EvictFurthestFirst                       // Remove the values whose keys are furthest from the node, if further than the new key
+	EvictOldestFirst                         // Remove the values whose latest STORE was received first
+)
+
+// StorageQuota limits how much a node stores for other nodes. A limit of zero means there is no limit.
+type StorageQuota struct {
+	MaxBytes       int
+	MaxKeys        int
+	MaxOriginShare float64 // The fraction of MaxBytes and MaxKeys the values stored by a single origin may occupy
+	Eviction       EvictionPolicy
+}
+
+// DefaultStorageQuota returns the quota a node enforces unless it is configured with another one.
+func DefaultStorageQuota() StorageQuota {
+	return StorageQuota{
+		MaxBytes:       DefaultMaxStoredBytes,
+		MaxKeys:        DefaultMaxStoredKeys,
+		MaxOriginShare: DefaultMaxOriginShare,
+		Eviction:       DefaultEvictionPolicy,
+	}
+}
+
+// storageAccounting remembers which origin stored each key and how much each origin stores, the zero value is ready to
+// use. An origin is the IP address a value was received from rather than the id the sender claims, since a sender can
+// claim any id. The usage of an origin can include values that have expired since, so it is recounted once the origin
+// reaches its share.
+type storageAccounting struct {
+	lock    sync.Mutex
+	origins map[[KeySize]byte]string
+	usage   map[string]*originUsage
+}
+
+// originUsage is the storage used by the values of a single origin.
+type originUsage struct {
+	bytes int
+	keys  map[[KeySize]byte]int // The size of the value stored under each key.
+}
+
+func (accounting *storageAccounting) add(origin string, key *Key, size int) {
+	if accounting.origins == nil {
+		accounting.origins = make(map[[KeySize]byte]string)
+		accounting.usage = make(map[string]*originUsage)
+	}
+	usage, ok := accounting.usage[origin]
+	if !ok {
+		usage = &originUsage{keys: make(map[[KeySize]byte]int)}
+		accounting.usage[origin] = usage
+	}
+	accounting.origins[key.Hash] = origin
+	usage.keys[key.Hash] = size
+	usage.bytes += size
+}
+
+func (accounting *storageAccounting) remove(hash [KeySize]byte) {
+	origin, ok := accounting.origins[hash]
+	if !ok {
+		return
+	}
+	usage := accounting.usage[origin]
+	usage.bytes -= usage.keys[hash]
+	delete(usage.keys, hash)
+	delete(accounting.origins, hash)
+	if len(usage.keys) <= 0 {
+		delete(accounting.usage, origin)
+	}
+}
+
+// usageOf returns the storage used by the origin, after forgetting the keys which are no longer in the data store if recount is set.
+func (accounting *storageAccounting) usageOf(origin string, dataStore DataStore, recount bool) originUsage {
+	usage, ok := accounting.usage[origin]
+	if !ok {
+		return originUsage{}
+	}
+	if recount {
+		for hash := range usage.keys {
+			_, err := dataStore.GetTime(&Key{Hash: hash})
+			if err != nil {
+				accounting.remove(hash)
+			}
+		}
+	}
+	return originUsage{bytes: usage.bytes, keys: usage.keys}
+}
+
+// WithStorageQuota makes the node enforce the given quota on incoming STOREs instead of the default one.
+func WithStorageQuota(quota StorageQuota) KademliaNodeOption {
+	return func(kademliaNode *KademliaNodeImplementation) {
+		kademliaNode.storageQuota = quota
+	}
+}
+
+// storeValue inserts a value received from the origin into the data store if the storage quota allows it, evicting
+// other values according to the eviction policy when the data store is full. A value replacing one that is already
+// stored only needs the space it grows by. The returned error is the reason of a refusal.
+func (kademliaNode *KademliaNodeImplementation) storeValue(origin string, key *Key, value Value, ttl time.Duration) error {
+	if kademliaNode.tombstones.Refuses(key, value) {
+		return errors.New("the value has been deleted by its publisher")
+	}
+
+	accounting := &kademliaNode.storageAccounting
+	accounting.lock.Lock()
+	defer accounting.lock.Unlock()
+
+	quota := kademliaNode.storageQuota
+	dataStore := kademliaNode.GetDataStore()
+
+	storedBytes, storedKeys := 0, 0
+	if stored, err := dataStore.Peek(key); err == nil {
+		storedBytes, storedKeys = len(stored.Data), 1
+		// A replica of the same data carries what is left of the lifetime at its sender, so it must not cut short a
+		// later expiration time either
+		expirationTime, err := dataStore.GetTime(key)
+		if err == nil && bytes.Equal(stored.Data, value.Data) {
+			ttl = max(ttl, time.Until(expirationTime))
+		}
+	}
+
+	if quota.MaxBytes > 0 && len(value.Data) > quota.MaxBytes {
+		return errors.New("the value is larger than the storage capacity")
+	}
+
+	exceedsShare := func(usage originUsage) bool {
+		// The value replaces the one the origin may already store under the key
+		usedBytes, usedKeys := usage.bytes, len(usage.keys)
+		if size, ok := usage.keys[key.Hash]; ok {
+			usedBytes, usedKeys = usedBytes-size, usedKeys-1
+		}
+		return (quota.MaxBytes > 0 && float64(usedBytes+len(value.Data)) > quota.MaxOriginShare*float64(quota.MaxBytes)) ||
+			(quota.MaxKeys > 0 && float64(usedKeys+1) > quota.MaxOriginShare*float64(quota.MaxKeys))
+	}
+	if quota.MaxOriginShare > 0 {
+		if exceedsShare(accounting.usageOf(origin, dataStore, false)) && exceedsShare(accounting.usageOf(origin, dataStore, true)) {
+			return errors.New("the origin has used up its share of the storage capacity")
+		}
+	}
+
+	stats := dataStore.Stats()
+	usedBytes, usedKeys := stats.Bytes-storedBytes, stats.Keys-storedKeys
+	isFull := func() bool {
+		return (quota.MaxBytes > 0 && usedBytes+len(value.Data) > quota.MaxBytes) ||
+			(quota.MaxKeys > 0 && usedKeys+1 > quota.MaxKeys)
+	}
+
+	if isFull() {
+		candidates, err := kademliaNode.evictionCandidates(quota.Eviction, key, dataStore.Items())
+		if err != nil {
+			return err
+		}
+
+		// Free the space before anything is evicted, so that nothing is evicted for a value that is refused anyway
+		evicted := 0
+		for isFull() && evicted < len(candidates) {
+			usedBytes -= len(candidates[evicted].Value.Data)
+			usedKeys--
+			evicted++
+		}
+		if isFull() {
+			return errors.New("the storage capacity is full of values closer to the node")
+		}
+
+		for _, candidate := range candidates[:evicted] {
+			dataStore.Delete(candidate.Key)
+			accounting.remove(candidate.Key.Hash)
+		}
+	}
+
+	err := dataStore.Insert(key, value, ttl)
+	if err != nil {
+		return errors.New("the value could not be stored: " + err.Error())
+	}
+	accounting.remove(key.Hash)
+	accounting.add(origin, key, len(value.Data))
+	return nil
+}
+
+// evictionCandidates returns the stored items the eviction policy allows to be removed for the key, in eviction order.
+func (kademliaNode *KademliaNodeImplementation) evictionCandidates(policy EvictionPolicy, key *Key, items []DataStoreItem) ([]DataStoreItem, error) {
+	me := kademliaNode.GetRoutingTable().Me
+	candidates := []DataStoreItem{}
+
+	switch policy {
+	case EvictFurthestFirst:
+		distance := me.ID.CalcDistance(key.GetKademliaIdRepresentationOfKey())
+		for _, item := range items {
+			if !item.Pinned && distance.Less(me.ID.CalcDistance(item.Key.GetKademliaIdRepresentationOfKey())) {
+				candidates = append(candidates, item)
+			}
+		}
+		sort.Slice(candidates, func(i, j int) bool {
+			return me.ID.CalcDistance(candidates[j].Key.GetKademliaIdRepresentationOfKey()).Less(me.ID.CalcDistance(candidates[i].Key.GetKademliaIdRepresentationOfKey()))
+		})
+
+	case EvictOldestFirst:
+		for _, item := range items {
+			if !item.Pinned && item.Key.Hash != key.Hash {
+				candidates = append(candidates, item)
+			}
+		}
+		sort.Slice(candidates, func(i, j int) bool {
+			return candidates[i].StoreTime.Before(candidates[j].StoreTime)
+		})
+
+	default:
+		return nil, errors.New("the storage capacity is full")
+	}
+
+	return candidates, nil
+}
@@ -0,0 +1,185 @@
+package kademlia
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type NetworkRefusingStoreMock struct {
+	NetworkStoreMock
+	refusingPort int
+}
+
+func (network *NetworkRefusingStoreMock) SendStoreMessage(from *Contact, contact *Contact, key *Key, value Value, ttl time.Duration) error {
+	if contact.Port == network.refusingPort {
+		return &StoreRefusedError{Reason: "the storage quota is reached"}
+	}
+	return network.NetworkStoreMock.SendStoreMessage(from, contact, key, value, ttl)
+}
+
+func createQuotaTestNode(quota StorageQuota) *KademliaNodeImplementation {
+	kademliaNode := NewKademliaNode("127.0.0.1", 3004, false, WithStorageQuota(quota))
+	kademliaNode.RoutingTable = NewRoutingTable(NewContact(GenerateNewKademliaID("0000000000000000000000000000000000000000"), "127.0.0.1", 3004))
+	return kademliaNode
+}
+
+func TestStoreValueRefusesWhenFull(t *testing.T) {
+	kademliaNode := createQuotaTestNode(StorageQuota{MaxKeys: 2, Eviction: EvictNothing})
+	origin := "10.0.0.1"
+
+	assert.NoError(t, kademliaNode.storeValue(origin, NewKey("value1"), NewTextValue("value1"), DefaultTTL))
+	assert.NoError(t, kademliaNode.storeValue(origin, NewKey("value2"), NewTextValue("value2"), DefaultTTL))
+	assert.Error(t, kademliaNode.storeValue(origin, NewKey("value3"), NewTextValue("value3"), DefaultTTL))
+
+	// Storing a value which is already stored does not need more space
+	assert.NoError(t, kademliaNode.storeValue(origin, NewKey("value1"), NewTextValue("value1"), DefaultTTL))
+	assert.Equal(t, DataStoreStats{Keys: 2, Bytes: 12}, kademliaNode.GetDataStore().Stats())
+}
+
+func TestStoreValueRefusesOriginOverItsShare(t *testing.T) {
+	kademliaNode := createQuotaTestNode(StorageQuota{MaxBytes: 20, MaxOriginShare: 0.5})
+	origin := "10.0.0.1"
+
+	assert.NoError(t, kademliaNode.storeValue(origin, NewKey("value1"), NewTextValue("value1"), DefaultTTL))
+	assert.Error(t, kademliaNode.storeValue(origin, NewKey("value2"), NewTextValue("value2"), DefaultTTL))
+	assert.NoError(t, kademliaNode.storeValue("10.0.0.2", NewKey("value2"), NewTextValue("value2"), DefaultTTL))
+}
+
+func TestStoreValueEvictsFurthestFirst(t *testing.T) {
+	kademliaNode := createQuotaTestNode(StorageQuota{MaxKeys: 2, Eviction: EvictFurthestFirst})
+	origin := "10.0.0.1"
+
+	closeKey := GetKeyRepresentationOfKademliaId(GenerateNewKademliaID("0000000000000000000000000000000000000001"))
+	middleKey := GetKeyRepresentationOfKademliaId(GenerateNewKademliaID("00000000000000000000000000000000000000FF"))
+	farKey := GetKeyRepresentationOfKademliaId(GenerateNewKademliaID("FFFFFFFF00000000000000000000000000000000"))
+
+	assert.NoError(t, kademliaNode.storeValue(origin, farKey, NewTextValue("far"), DefaultTTL))
+	assert.NoError(t, kademliaNode.storeValue(origin, middleKey, NewTextValue("middle"), DefaultTTL))
+	assert.NoError(t, kademliaNode.storeValue(origin, closeKey, NewTextValue("close"), DefaultTTL))
+
+	_, err := kademliaNode.GetDataStore().Get(farKey)
+	assert.Error(t, err)
+
+	// Nothing is evicted for a key that is further away than every stored key
+	assert.Error(t, kademliaNode.storeValue(origin, farKey, NewTextValue("far"), DefaultTTL))
+	assert.Equal(t, 2, kademliaNode.GetDataStore().Stats().Keys)
+}
+
+func TestStoreValueAccountsGrowthOfStoredValue(t *testing.T) {
+	kademliaNode := createQuotaTestNode(StorageQuota{MaxBytes: 20, Eviction: EvictNothing})
+	origin := "10.0.0.1"
+	key := NewKey("growing")
+
+	assert.NoError(t, kademliaNode.storeValue(origin, NewKey("other"), NewTextValue("0123456789"), DefaultTTL))
+	assert.NoError(t, kademliaNode.storeValue(origin, key, NewTextValue("01234"), DefaultTTL))
+	assert.NoError(t, kademliaNode.storeValue(origin, key, NewTextValue("0123456789"), DefaultTTL))
+	assert.Error(t, kademliaNode.storeValue(origin, key, NewTextValue("0123456789A"), DefaultTTL))
+
+	assert.Equal(t, DataStoreStats{Keys: 2, Bytes: 20}, kademliaNode.GetDataStore().Stats())
+	assert.Equal(t, 20, kademliaNode.storageAccounting.usage[origin].bytes)
+}
+
+func TestStoreMessageSharesTheQuotaOfTheSenderAddress(t *testing.T) {
+	kademliaNode := createQuotaTestNode(StorageQuota{MaxKeys: 4, MaxOriginShare: 0.5})
+	messageHandler := &MessageHandlerImplementation{
+		kademliaNode: kademliaNode,
+	}
+
+	// Neither a new id for every STORE nor the id of the receiving node gets a sender more than its share
+	senders := []Contact{
+		NewContact(NewRandomKademliaID(), "10.0.0.1", 80),
+		NewContact(NewRandomKademliaID(), "10.0.0.1", 80),
+		kademliaNode.GetRoutingTable().Me,
+	}
+	successes := []bool{}
+	for i, sender := range senders {
+		value := "value" + string(rune('0'+i))
+		bytes, err := json.Marshal(NewStoreMessage(sender, NewKey(value), NewTextValue(value), DefaultTTL))
+		if err != nil {
+			assert.Fail(t, err.Error())
+		}
+		response, err := messageHandler.HandleMessage(bytes, "10.0.0.1")
+		if err != nil {
+			assert.Fail(t, err.Error())
+		}
+		var storeResponse StoreResponse
+		json.Unmarshal(response, &storeResponse)
+		successes = append(successes, storeResponse.StoreSuccess)
+	}
+	assert.Equal(t, []bool{true, true, false}, successes)
+}
+
+func TestStoreMessageRefused(t *testing.T) {
+	kademliaNode := createQuotaTestNode(StorageQuota{MaxBytes: 2})
+	messageHandler := &MessageHandlerImplementation{
+		kademliaNode: kademliaNode,
+	}
+
+	value := "too large"
+	store := NewStoreMessage(NewContact(NewRandomKademliaID(), "127.0.0.1", 80), NewKey(value), NewTextValue(value), DefaultTTL)
+	bytes, err := json.Marshal(store)
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+	response, err := messageHandler.HandleMessage(bytes, "127.0.0.1")
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+	var storeResponse StoreResponse
+	errUnmarshal := json.Unmarshal(response, &storeResponse)
+	if errUnmarshal != nil {
+		assert.Fail(t, errUnmarshal.Error())
+	}
+	assert.False(t, storeResponse.StoreSuccess)
+	assert.NotEmpty(t, storeResponse.Reason)
+}
+
+func TestStoreAtContactsTriesAnotherCandidate(t *testing.T) {
+	kademlia := CreateMockedKademlia(GenerateNewKademliaID("0000000000000000000000000000000000000000"), "127.0.0.1", 0)
+	network := &NetworkRefusingStoreMock{refusingPort: 1}
+	kademlia.Network = network
+
+	contact1 := NewContact(GenerateNewKademliaID("0000000000000000000000000000000000000001"), "127.0.0.1", 1)
+	contact2 := NewContact(GenerateNewKademliaID("0000000000000000000000000000000000000002"), "127.0.0.1", 2)
+	contact3 := NewContact(GenerateNewKademliaID("0000000000000000000000000000000000000003"), "127.0.0.1", 3)
+	kademlia.KademliaNode.GetRoutingTable().AddContact(contact1)
+	kademlia.KademliaNode.GetRoutingTable().AddContact(contact2)
+	kademlia.KademliaNode.GetRoutingTable().AddContact(contact3)
+
+	key := GetKeyRepresentationOfKademliaId(GenerateNewKademliaID("0000000000000000000000000000000000000000"))
+	result := StoreResult{Key: key, Replicas: kademlia.storeAtContacts(key, NewTextValue("value"), DefaultTTL, []Contact{contact1, contact2})}
+
+	stored := result.GetContacts(STORE_ACKNOWLEDGED)
+	assert.Len(t, stored, 2)
+	assert.True(t, kademlia.FirstSetContainsAllContactsOfSecondSet(stored, []Contact{contact2, contact3}))
+	assert.Equal(t, []Contact{contact1}, result.GetContacts(STORE_REFUSED))
+}
+
+func TestStoreValueDoesNotEvictPinnedValues(t *testing.T) {
+	kademliaNode := createQuotaTestNode(StorageQuota{MaxKeys: 1, Eviction: EvictOldestFirst})
+	origin := "10.0.0.1"
+
+	assert.NoError(t, kademliaNode.storeValue(origin, NewKey("pinned"), NewTextValue("pinned"), DefaultTTL))
+	assert.NoError(t, kademliaNode.GetDataStore().Pin(NewKey("pinned")))
+
+	assert.Error(t, kademliaNode.storeValue(origin, NewKey("value"), NewTextValue("value"), DefaultTTL))
+	_, err := kademliaNode.GetDataStore().Get(NewKey("pinned"))
+	assert.NoError(t, err)
+}
+
+func TestStoreValueReplicaDoesNotShortenExpiration(t *testing.T) {
+	kademliaNode := createQuotaTestNode(DefaultStorageQuota())
+	origin := "10.0.0.1"
+	key := NewKey("value")
+
+	assert.NoError(t, kademliaNode.storeValue(origin, key, NewTextValue("value"), DefaultTTL))
+	expirationTime, _ := kademliaNode.GetDataStore().GetTime(key)
+
+	assert.NoError(t, kademliaNode.storeValue(origin, key, NewTextValue("value"), DefaultTTL/2))
+	replicatedTime, _ := kademliaNode.GetDataStore().GetTime(key)
+	assert.False(t, replicatedTime.Before(expirationTime))
+	assert.True(t, replicatedTime.Before(expirationTime.Add(time.Second)))
+}
@@ -0,0 +1,76 @@
+package kademlia
+
+import (
+	"errors"
+)
+
+// DefaultWriteQuorum is how many replicas must acknowledge a STORE unless a node is configured with another quorum.
+// One replica is enough, as before the write quorum could be configured.
+const DefaultWriteQuorum = 1
+
+// StoreState is what a contact answered a STORE with.
+type StoreState string
+
+const (
+	STORE_ACKNOWLEDGED StoreState = "ACKNOWLEDGED" // Stored the value
+	STORE_REFUSED      StoreState = "REFUSED"      // Answered that it will not store the value
+	STORE_TIMED_OUT    StoreState = "TIMED_OUT"    // Did not answer
+)
+
+// StoreRefusedError is returned by SendStoreMessage when the contact answered but refused to store the value.
+type StoreRefusedError struct {
+	Reason string
+}
+
+func (err *StoreRefusedError) Error() string {
+	return "refused to store the value: " + err.Reason
+}
+
+// DeleteRefusedError is returned by SendDeleteMessage when the contact answered but refused to delete the value.
+type DeleteRefusedError struct {
+	Reason string
+}
+
+func (err *DeleteRefusedError) Error() string {
+	return "refused to delete the value: " + err.Reason
+}
+
+// StoreReplicaResult is what one contact answered a STORE with.
+type StoreReplicaResult struct {
+	Contact Contact    `json:"contact"`
+	State   StoreState `json:"state"`
+	Reason  string     `json:"reason,omitempty"` // Why the contact refused or did not answer
+}
+
+func newStoreReplicaResult(contact Contact, err error) StoreReplicaResult {
+	var refused *StoreRefusedError
+	var deleteRefused *DeleteRefusedError
+	if err == nil {
+		return StoreReplicaResult{Contact: contact, State: STORE_ACKNOWLEDGED}
+	} else if errors.As(err, &refused) {
+		return StoreReplicaResult{Contact: contact, State: STORE_REFUSED, Reason: refused.Reason}
+	} else if errors.As(err, &deleteRefused) {
+		return StoreReplicaResult{Contact: contact, State: STORE_REFUSED, Reason: deleteRefused.Reason}
+	}
+	return StoreReplicaResult{Contact: contact, State: STORE_TIMED_OUT, Reason: err.Error()}
+}
+
+// StoreResult is the key a value has been stored under, together with what every contact asked to store it answered.
+// A DELETE is answered in the same way, with an empty delete token.
+type StoreResult struct {
+	Key         *Key                 `json:"-"`
+	DeleteToken string               `json:"deleteToken,omitempty"` // The secret the value can be deleted with
+	Version     uint64               `json:"version,omitempty"`     // The version of a value put under an application key
+	Replicas    []StoreReplicaResult `json:"replicas"`
+}
+
+// GetContacts returns the contacts that answered the STORE with the given state.
+func (result *StoreResult) GetContacts(state StoreState) []Contact {
+	contacts := []Contact{}
+	for _, replica := range result.Replicas {
+		if replica.State == state {
+			contacts = append(contacts, replica.Contact)
+		}
+	}
+	return contacts
+}
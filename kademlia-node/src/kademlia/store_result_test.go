@@ -0,0 +1,64 @@
+package kademlia
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type NetworkStoreAnswersMock struct {
+	NetworkStoreMock
+	answers map[int]error // What each contact answers a STORE with, by port
+}
+
+func (network *NetworkStoreAnswersMock) SendStoreMessage(from *Contact, contact *Contact, key *Key, value Value, ttl time.Duration) error {
+	if err, ok := network.answers[contact.Port]; ok {
+		return err
+	}
+	return network.NetworkStoreMock.SendStoreMessage(from, contact, key, value, ttl)
+}
+
+func getStoreStates(result *StoreResult) map[int]StoreState {
+	states := make(map[int]StoreState)
+	for _, replica := range result.Replicas {
+		states[replica.Contact.Port] = replica.State
+	}
+	return states
+}
+
+func TestStoreReportsEveryReplica(t *testing.T) {
+	network := &NetworkStoreAnswersMock{answers: map[int]error{
+		1: &StoreRefusedError{Reason: "the storage quota is reached"},
+		2: errors.New("timeout"),
+	}}
+	kademlia := createQuorumTestKademlia(network)
+
+	result, err := kademlia.Store(NewTextValue("value"), DefaultTTL)
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]StoreState{1: STORE_REFUSED, 2: STORE_TIMED_OUT, 3: STORE_ACKNOWLEDGED}, getStoreStates(result))
+	for _, replica := range result.Replicas {
+		if replica.State == STORE_REFUSED {
+			assert.Equal(t, "the storage quota is reached", replica.Reason)
+		}
+	}
+	kademlia.Forget(result.Key)
+}
+
+func TestStoreFailsWithoutWriteQuorum(t *testing.T) {
+	network := &NetworkStoreAnswersMock{answers: map[int]error{
+		1: &StoreRefusedError{Reason: "the storage quota is reached"},
+	}}
+	kademlia := createQuorumTestKademlia(network)
+	kademlia.KademliaNode.(*KademliaNodeImplementation).writeQuorum = 3
+
+	result, err := kademlia.Store(NewTextValue("value"), DefaultTTL)
+
+	assert.Error(t, err)
+	if assert.NotNil(t, result) {
+		assert.Len(t, result.GetContacts(STORE_ACKNOWLEDGED), 2)
+		assert.Len(t, result.GetContacts(STORE_REFUSED), 1)
+	}
+}
@@ -0,0 +1,145 @@
+package kademlia
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/arianfiftyone/src/logger"
+)
+
+const deleteTokenSize = 32
+
+// NewDeleteToken returns a random secret the publisher of a value proves with that it may delete the value. Only the
+// hash of the token is stored with the value, see HashDeleteToken.
+func NewDeleteToken() (string, error) {
+	token := make([]byte, deleteTokenSize)
+	_, err := rand.Read(token)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(token), nil
+}
+
+// HashDeleteToken returns the hash of the delete token, which is stored with the value the token deletes.
+func HashDeleteToken(token string) []byte {
+	hash := sha256.Sum256([]byte(token))
+	return hash[:]
+}
+
+// tombstoneSet remembers the keys whose values have been deleted until the values would have expired at every replica,
+// so that a replica that missed the DELETE cannot store the value again. The zero value is ready to use.
+type tombstoneSet struct {
+	lock       sync.Mutex
+	tombstones map[[KeySize]byte]tombstone
+}
+
+// tombstone refuses the values of a deleted key until it expires.
+type tombstone struct {
+	expiration time.Time
+	tokenHash  []byte // Only values with this delete token hash are refused, every value is if nil
+}
+
+// Add keeps a tombstone for the key until the given time. If the token hash is not nil, the tombstone only refuses the
+// values stored with it as their delete token hash.
+func (tombstones *tombstoneSet) Add(key *Key, tokenHash []byte, until time.Time) {
+	tombstones.lock.Lock()
+	defer tombstones.lock.Unlock()
+
+	if tombstones.tombstones == nil {
+		tombstones.tombstones = make(map[[KeySize]byte]tombstone)
+	}
+	tombstones.tombstones[key.Hash] = tombstone{expiration: until, tokenHash: tokenHash}
+}
+
+// Refuses reports whether the value of the key has been deleted and would not have expired yet.
+func (tombstones *tombstoneSet) Refuses(key *Key, value Value) bool {
+	tombstones.lock.Lock()
+	defer tombstones.lock.Unlock()
+
+	tombstone, ok := tombstones.tombstones[key.Hash]
+	if !ok {
+		return false
+	}
+	if time.Now().After(tombstone.expiration) {
+		delete(tombstones.tombstones, key.Hash)
+		return false
+	}
+	return tombstone.tokenHash == nil || subtle.ConstantTimeCompare(tombstone.tokenHash, value.DeleteTokenHash) == 1
+}
+
+// Sweep forgets the tombstones that have expired.
+func (tombstones *tombstoneSet) Sweep() {
+	tombstones.lock.Lock()
+	defer tombstones.lock.Unlock()
+
+	now := time.Now()
+	for hash, tombstone := range tombstones.tombstones {
+		if now.After(tombstone.expiration) {
+			delete(tombstones.tombstones, hash)
+		}
+	}
+}
+
+// deleteValue removes the value of the key from the data store if the token is the delete token of its publisher, and
+// leaves a tombstone until the value would have expired at every replica. A replica may have been stored or refreshed
+// with the time to live the value was published with just before the DELETE, and its expiration time may be later
+// than the one here, so the tombstone lasts for the later of the two. If the time to live is unknown, the tombstone
+// lasts for the longest time to live the node grants. The returned error is the reason of a refusal.
+//
+// A node that does not hold the value, because it has not arrived yet or has expired here, cannot check the token,
+// but still leaves a tombstone that only refuses the values whose delete token hash is the hash of the token. It is
+// the tombstone a node holding such a value leaves once it checks the token, and nobody without the token can leave one.
+func (kademliaNode *KademliaNodeImplementation) deleteValue(key *Key, token string, ttl time.Duration) error {
+	dataStore := kademliaNode.GetDataStore()
+	endOfLife := time.Now().Add(kademliaNode.tombstoneTTL(ttl))
+	stored, err := dataStore.Peek(key)
+	if err != nil {
+		kademliaNode.tombstones.Add(key, HashDeleteToken(token), endOfLife)
+		return err
+	}
+	if stored.DeleteTokenHash == nil {
+		return errors.New("the value was stored without a delete token")
+	}
+	if subtle.ConstantTimeCompare(stored.DeleteTokenHash, HashDeleteToken(token)) != 1 {
+		return errors.New("the delete token is wrong")
+	}
+
+	expirationTime, err := dataStore.GetTime(key)
+	if err != nil {
+		return err
+	}
+	if endOfLife.After(expirationTime) {
+		expirationTime = endOfLife
+	}
+	kademliaNode.tombstones.Add(key, nil, expirationTime)
+
+	accounting := &kademliaNode.storageAccounting
+	accounting.lock.Lock()
+	defer accounting.lock.Unlock()
+	accounting.remove(key.Hash)
+	logger.Log("Leaving a tombstone for the data object " + key.GetHashString())
+	return dataStore.Delete(key)
+}
+
+// tombstoneTTL returns how long a tombstone is kept for a value published with the time to live, which is the longest
+// time to live the node grants if it is unknown.
+func (kademliaNode *KademliaNodeImplementation) tombstoneTTL(ttl time.Duration) time.Duration {
+	if ttl <= 0 {
+		ttl = kademliaNode.maxTTL
+		if ttl <= 0 {
+			ttl = DefaultMaxTTL
+		}
+	}
+	return kademliaNode.clampTTL(ttl)
+}
+
+// sweepTombstones forgets the tombstones that have expired, so that they do not pile up for keys that are never
+// stored again.
+func (kademliaNode *KademliaNodeImplementation) sweepTombstones() {
+	kademliaNode.tombstones.Sweep()
+}
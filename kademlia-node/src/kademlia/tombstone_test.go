@@ -0,0 +1,146 @@
+package kademlia
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type NetworkDeleteMock struct {
+	NetworkStoreMock
+	answers map[int]error // What each contact answers a DELETE with, by port
+}
+
+func (network *NetworkDeleteMock) SendDeleteMessage(from *Contact, contact *Contact, key *Key, token string, ttl time.Duration) error {
+	return network.answers[contact.Port]
+}
+
+func createTombstoneTestNode(port int) *KademliaNodeImplementation {
+	kademliaNode := NewKademliaNode("127.0.0.1", port, false)
+	kademliaNode.RoutingTable = NewRoutingTable(NewContact(GenerateNewKademliaID("0000000000000000000000000000000000000000"), "127.0.0.1", port))
+	return kademliaNode
+}
+
+func newDeletableValue(text string, token string) Value {
+	value := NewTextValue(text)
+	value.DeleteTokenHash = HashDeleteToken(token)
+	return value
+}
+
+func TestDeleteValueLeavesTombstone(t *testing.T) {
+	kademliaNode := createTombstoneTestNode(3009)
+	origin := "10.0.0.1"
+	token, err := NewDeleteToken()
+	assert.NoError(t, err)
+	value := newDeletableValue("value", token)
+
+	assert.NoError(t, kademliaNode.storeValue(origin, value.GetKey(), value, DefaultTTL))
+	assert.Error(t, kademliaNode.deleteValue(value.GetKey(), "wrong token", DefaultTTL))
+	assert.Len(t, kademliaNode.GetDataStore().Items(), 1)
+
+	assert.NoError(t, kademliaNode.deleteValue(value.GetKey(), token, DefaultTTL))
+	assert.Empty(t, kademliaNode.GetDataStore().Items())
+
+	// Replication from a replica that missed the DELETE does not bring the value back
+	assert.Error(t, kademliaNode.storeValue(origin, value.GetKey(), value, DefaultTTL))
+	assert.Empty(t, kademliaNode.GetDataStore().Items())
+}
+
+func TestDeleteValueKeepsTombstoneForThePublishedLifetime(t *testing.T) {
+	kademliaNode := createTombstoneTestNode(3009)
+	value := newDeletableValue("value", "token")
+	other := newDeletableValue("other value", "token")
+
+	// The replica here expires sooner than one stored with the full time to live elsewhere
+	assert.NoError(t, kademliaNode.storeValue("10.0.0.1", value.GetKey(), value, time.Second))
+	assert.NoError(t, kademliaNode.deleteValue(value.GetKey(), "token", time.Minute))
+	assert.False(t, kademliaNode.tombstones.tombstones[value.GetKey().Hash].expiration.Before(time.Now().Add(time.Minute-time.Second)))
+
+	// Without the published time to live, the tombstone lasts as long as any value the node stores
+	assert.NoError(t, kademliaNode.storeValue("10.0.0.1", other.GetKey(), other, time.Second))
+	assert.NoError(t, kademliaNode.deleteValue(other.GetKey(), "token", 0))
+	assert.False(t, kademliaNode.tombstones.tombstones[other.GetKey().Hash].expiration.Before(time.Now().Add(DefaultMaxTTL-time.Second)))
+}
+
+func TestDeleteValueLeavesTombstoneForAbsentValue(t *testing.T) {
+	kademliaNode := createTombstoneTestNode(3009)
+	token, err := NewDeleteToken()
+	assert.NoError(t, err)
+	value := newDeletableValue("value", token)
+	other := newDeletableValue("value", "other token")
+
+	// The DELETE arrives before the value, which cannot be stored afterwards
+	assert.Error(t, kademliaNode.deleteValue(value.GetKey(), token, DefaultTTL))
+	assert.Error(t, kademliaNode.storeValue("10.0.0.1", value.GetKey(), value, DefaultTTL))
+
+	// Without the token, the tombstone refuses nothing else
+	assert.NoError(t, kademliaNode.storeValue("10.0.0.1", other.GetKey(), other, DefaultTTL))
+}
+
+func TestSweepForgetsExpiredTombstones(t *testing.T) {
+	kademliaNode := createTombstoneTestNode(3009)
+	expired := NewTextValue("expired")
+	kept := NewTextValue("kept")
+	kademliaNode.tombstones.Add(expired.GetKey(), nil, time.Now().Add(-time.Second))
+	kademliaNode.tombstones.Add(kept.GetKey(), nil, time.Now().Add(time.Minute))
+
+	kademliaNode.sweepTombstones()
+
+	assert.Len(t, kademliaNode.tombstones.tombstones, 1)
+	assert.True(t, kademliaNode.tombstones.Refuses(kept.GetKey(), kept))
+}
+
+func TestDeleteValueWithoutDeleteToken(t *testing.T) {
+	kademliaNode := createTombstoneTestNode(3009)
+	value := NewTextValue("value")
+
+	assert.NoError(t, kademliaNode.storeValue("10.0.0.2", value.GetKey(), value, DefaultTTL))
+	assert.Error(t, kademliaNode.deleteValue(value.GetKey(), "", DefaultTTL))
+	assert.Len(t, kademliaNode.GetDataStore().Items(), 1)
+}
+
+func TestStoreMessageKeepsDeleteTokenOfFirstPublisher(t *testing.T) {
+	kademliaNode := createTombstoneTestNode(3009)
+	messageHandler := &MessageHandlerImplementation{
+		kademliaNode: kademliaNode,
+	}
+	from := NewContact(NewRandomKademliaID(), "127.0.0.1", 80)
+
+	for _, token := range []string{"first token", "second token"} {
+		value := newDeletableValue("value", token)
+		bytes, err := json.Marshal(NewStoreMessage(from, value.GetKey(), value, DefaultTTL))
+		assert.NoError(t, err)
+		_, err = messageHandler.HandleMessage(bytes, "127.0.0.1")
+		assert.NoError(t, err)
+	}
+
+	assert.Error(t, kademliaNode.deleteValue(NewKey("value"), "second token", DefaultTTL))
+	assert.NoError(t, kademliaNode.deleteValue(NewKey("value"), "first token", DefaultTTL))
+}
+
+func TestDeleteReportsEveryReplica(t *testing.T) {
+	network := &NetworkDeleteMock{answers: map[int]error{
+		1: &DeleteRefusedError{Reason: "the delete token is wrong"},
+		2: errors.New("timeout"),
+	}}
+	kademlia := createQuorumTestKademlia(network)
+
+	result, err := kademlia.Delete(NewKey("value"), "token")
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]StoreState{1: STORE_REFUSED, 2: STORE_TIMED_OUT, 3: STORE_ACKNOWLEDGED}, getStoreStates(result))
+}
+
+func TestDeleteFailsIfNoReplicaDeleted(t *testing.T) {
+	refused := &DeleteRefusedError{Reason: "the delete token is wrong"}
+	network := &NetworkDeleteMock{answers: map[int]error{1: refused, 2: refused, 3: refused}}
+	kademlia := createQuorumTestKademlia(network)
+
+	result, err := kademlia.Delete(NewKey("value"), "token")
+
+	assert.Error(t, err)
+	assert.Len(t, result.GetContacts(STORE_REFUSED), 3)
+}
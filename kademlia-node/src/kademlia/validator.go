@@ -0,0 +1,284 @@
+package kademlia
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync"
+
+	"golang.org/x/exp/slices"
+)
+
+const (
+	DefaultNamespace  = ""       // Immutable values stored under the hash of their data
+	RecordNamespace   = "record" // Mutable records signed by their publisher
+	KeyValueNamespace = "kv"     // Values stored under a key chosen by the application
+	ShardNamespace    = "shard"  // Erasure coded shards of values, stored under keys derived from the hash of the value
+)
+
+// namespacedName returns the name whose hash is the key of a value in the namespace. Outside of the default namespace
+// the name is prefixed with the namespace, as NewKeyValueKey and NewShardKey do, so that a key is only valid in the
+// namespace it was made for and a writer cannot pick a more lenient validator by relabelling a value.
+func namespacedName(namespace string, name []byte) []byte {
+	if namespace == DefaultNamespace {
+		return name
+	}
+	return append([]byte(namespace+"/"), name...)
+}
+
+// NewContentKey returns the key a value stored under the hash of its data has in the namespace. In the default
+// namespace it is the hash of the data alone.
+func NewContentKey(namespace string, data []byte, hashFunction HashFunction) (*Key, error) {
+	return NewKeyWithHashFunction(namespacedName(namespace, data), hashFunction)
+}
+
+// Validator decides which values may be stored under a key in a namespace, and which of several valid values of the
+// same key is the best one. Validators are registered per namespace in a ValidatorRegistry.
+type Validator interface {
+	// Validate returns an error if the value may not be stored under the key.
+	Validate(key *Key, value Value) error
+	// Select returns the index of the best of the valid values of the key, the first one if several are as good.
+	Select(key *Key, values []Value) int
+}
+
+// ContentHashValidator accepts a value under the hash of its data in its namespace, see NewContentKey. Every valid
+// value of a key is the same.
+type ContentHashValidator struct{}
+
+func (validator ContentHashValidator) Validate(key *Key, value Value) error {
+	contentKey, err := NewContentKey(value.Namespace, value.Data, key.GetHashFunction())
+	if err != nil || !contentKey.Equals(key) {
+		return errors.New("the key is not the hash of the value")
+	}
+	return nil
+}
+
+func (validator ContentHashValidator) Select(key *Key, values []Value) int {
+	return 0
+}
+
+// RecordValidator accepts a Record signed by the publisher its key belongs to, and selects the record with the highest
+// sequence number. If Publishers is not empty, only records signed by one of them are accepted.
+type RecordValidator struct {
+	Publishers []ed25519.PublicKey
+}
+
+func (validator RecordValidator) Validate(key *Key, value Value) error {
+	record, err := RecordFromValue(value)
+	if err != nil {
+		return err
+	}
+	err = record.Verify()
+	if err != nil {
+		return err
+	}
+	if !NewNamespacedRecordKey(value.Namespace, record.PublicKey, record.Salt).Equals(key) {
+		return errors.New("the key is not the key of the record")
+	}
+	if len(validator.Publishers) > 0 && !slices.ContainsFunc(validator.Publishers, func(publisher ed25519.PublicKey) bool { return publisher.Equal(record.PublicKey) }) {
+		return errors.New("the record is not signed by an allowed publisher")
+	}
+	return nil
+}
+
+func (validator RecordValidator) Select(key *Key, values []Value) int {
+	best := 0
+	var bestSequence uint64
+	for i, value := range values {
+		record, err := RecordFromValue(value)
+		if err == nil && (i == 0 || record.Sequence > bestSequence) {
+			best = i
+			bestSequence = record.Sequence
+		}
+	}
+	return best
+}
+
+// KeyValueValidator accepts a KeyValue under the key of its application key, and selects the value with the highest
+// version. Of several values with the same version, the one created last is selected.
+type KeyValueValidator struct{}
+
+func (validator KeyValueValidator) Validate(key *Key, value Value) error {
+	keyValue, err := KeyValueFromValue(value)
+	if err != nil {
+		return err
+	}
+	if !NewKey(value.Namespace + "/" + keyValue.Key).Equals(key) {
+		return errors.New("the key is not the key of the key-value pair")
+	}
+	return nil
+}
+
+func (validator KeyValueValidator) Select(key *Key, values []Value) int {
+	best := 0
+	var bestKeyValue KeyValue
+	for i, value := range values {
+		keyValue, err := KeyValueFromValue(value)
+		if err != nil {
+			continue
+		}
+		if i == 0 || keyValue.Version > bestKeyValue.Version ||
+			(keyValue.Version == bestKeyValue.Version && keyValue.Value.CreationTime.After(bestKeyValue.Value.CreationTime)) {
+			best = i
+			bestKeyValue = keyValue
+		}
+	}
+	return best
+}
+
+// ShardValidator accepts a Shard under the key of its object and index, if it has the size the erasure coding gives
+// the shards of its object and the digest it lists for itself. Every valid value of a key is the same, since the
+// shards of an object are deterministic.
+type ShardValidator struct{}
+
+func (validator ShardValidator) Validate(key *Key, value Value) error {
+	shard, err := ShardFromValue(value)
+	if err != nil {
+		return err
+	}
+	err = shard.Coding.Validate()
+	if err != nil {
+		return err
+	}
+	if shard.Index < 0 || shard.Index >= shard.Coding.TotalShards() {
+		return errors.New("the index of the shard is out of range")
+	}
+	if shard.Size < 0 || len(shard.Data) != shard.Coding.ShardSize(shard.Size) {
+		return errors.New("the shard does not have the size of the shards of its object")
+	}
+	if len(shard.Digests) != shard.Coding.TotalShards() || !bytes.Equal(shardDigest(shard.Data), shard.Digests[shard.Index]) {
+		return errors.New("the shard does not have the digest it lists for itself")
+	}
+	shardKey, err := shard.GetKey()
+	if err != nil {
+		return err
+	}
+	if value.Namespace != ShardNamespace || !shardKey.Equals(key) {
+		return errors.New("the key is not the key of the shard")
+	}
+	return nil
+}
+
+func (validator ShardValidator) Select(key *Key, values []Value) int {
+	return 0
+}
+
+// JSONValidator accepts a value under the hash of its data if the data is valid JSON of at most MaxSize bytes.
+// There is no size limit if MaxSize is zero.
+type JSONValidator struct {
+	ContentHashValidator
+	MaxSize int
+}
+
+func (validator JSONValidator) Validate(key *Key, value Value) error {
+	if validator.MaxSize > 0 && len(value.Data) > validator.MaxSize {
+		return errors.New("the value is larger than " + strconv.Itoa(validator.MaxSize) + " bytes")
+	}
+	if !json.Valid(value.Data) {
+		return errors.New("the value is not valid JSON")
+	}
+	return validator.ContentHashValidator.Validate(key, value)
+}
+
+// NewestValidator validates values with another Validator, and selects the value created last.
+type NewestValidator struct {
+	Validator
+}
+
+func (validator NewestValidator) Select(key *Key, values []Value) int {
+	best := 0
+	for i, value := range values {
+		if value.CreationTime.After(values[best].CreationTime) {
+			best = i
+		}
+	}
+	return best
+}
+
+var defaultValidators = map[string]Validator{
+	DefaultNamespace:  ContentHashValidator{},
+	RecordNamespace:   RecordValidator{},
+	KeyValueNamespace: KeyValueValidator{},
+	ShardNamespace:    ShardValidator{},
+}
+
+// ValidatorRegistry maps each namespace to the Validator of its values. The default namespace and the record
+// namespace have validators unless others are registered for them. The zero value is ready to use.
+type ValidatorRegistry struct {
+	lock       sync.RWMutex
+	validators map[string]Validator
+}
+
+// Register makes the validator decide which values of the namespace are valid, replacing any earlier validator.
+func (registry *ValidatorRegistry) Register(namespace string, validator Validator) {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	if registry.validators == nil {
+		registry.validators = make(map[string]Validator)
+	}
+	registry.validators[namespace] = validator
+}
+
+// GetValidator returns the validator of the namespace.
+func (registry *ValidatorRegistry) GetValidator(namespace string) (Validator, error) {
+	registry.lock.RLock()
+	defer registry.lock.RUnlock()
+
+	validator, ok := registry.validators[namespace]
+	if !ok {
+		validator, ok = defaultValidators[namespace]
+	}
+	if !ok {
+		return nil, errors.New("no validator is registered for the namespace " + namespace)
+	}
+	return validator, nil
+}
+
+// Validate returns an error if the value may not be stored under the key.
+func (registry *ValidatorRegistry) Validate(key *Key, value Value) error {
+	validator, err := registry.GetValidator(value.Namespace)
+	if err != nil {
+		return err
+	}
+	return validator.Validate(key, value)
+}
+
+// ValidateUpdate returns an error if a valid value may not replace the value stored under the key. It may if it holds
+// the same data, if the stored value is not valid, such as a shard corrupted on disk, or if the validator of the
+// namespace selects it over the stored value. A valid value outside the
+// default namespace replaces a value in the default namespace, since its key is derived from its namespace and the
+// value in the default namespace can only have been made to hold the key, see namespacedName.
+func (registry *ValidatorRegistry) ValidateUpdate(key *Key, stored Value, value Value) error {
+	if stored.Namespace == DefaultNamespace && value.Namespace != DefaultNamespace {
+		return nil
+	}
+	if stored.Namespace != value.Namespace {
+		return errors.New("the key is already used in another namespace")
+	}
+	if bytes.Equal(stored.Data, value.Data) || registry.Validate(key, stored) != nil {
+		return nil
+	}
+	validator, err := registry.GetValidator(value.Namespace)
+	if err != nil {
+		return err
+	}
+	if validator.Select(key, []Value{stored, value}) != 1 {
+		return errors.New("the stored value is selected over the value")
+	}
+	return nil
+}
+
+// Select returns the index of the best of the valid values of the key, which must all be in the same namespace.
+func (registry *ValidatorRegistry) Select(key *Key, values []Value) (int, error) {
+	if len(values) == 0 {
+		return 0, errors.New("there are no values to select from")
+	}
+	validator, err := registry.GetValidator(values[0].Namespace)
+	if err != nil {
+		return 0, err
+	}
+	return validator.Select(key, values), nil
+}
@@ -0,0 +1,163 @@
+package kademlia
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateDefaultNamespaces(t *testing.T) {
+	registry := &ValidatorRegistry{}
+	_, privateKey, _ := ed25519.GenerateKey(nil)
+	record := NewRecord(privateKey, nil, 1, NewTextValue("value"))
+	value, _ := record.ToValue()
+
+	assert.NoError(t, registry.Validate(record.GetKey(), value))
+	assert.Error(t, registry.Validate(NewKey("value"), value))
+	assert.NoError(t, registry.Validate(NewKey("value"), NewTextValue("value")))
+	assert.Error(t, registry.Validate(record.GetKey(), NewTextValue("value")))
+
+	// A record under the key of another salt is refused
+	assert.Error(t, registry.Validate(NewRecordKey(record.PublicKey, []byte("salt")), value))
+}
+
+func TestValidateUnknownNamespace(t *testing.T) {
+	registry := &ValidatorRegistry{}
+	value := NewTextValue("value")
+	value.Namespace = "unknown"
+
+	assert.Error(t, registry.Validate(NewKey("value"), value))
+}
+
+func TestValidateUpdateOfRecords(t *testing.T) {
+	registry := &ValidatorRegistry{}
+	_, privateKey, _ := ed25519.GenerateKey(nil)
+	key := NewRecordKey(privateKey.Public().(ed25519.PublicKey), nil)
+	stored, _ := NewRecord(privateKey, nil, 2, NewTextValue("value")).ToValue()
+	older, _ := NewRecord(privateKey, nil, 1, NewTextValue("older value")).ToValue()
+	conflicting, _ := NewRecord(privateKey, nil, 2, NewTextValue("other value")).ToValue()
+	same := stored
+	newer, _ := NewRecord(privateKey, nil, 3, NewTextValue("newer value")).ToValue()
+
+	assert.Error(t, registry.ValidateUpdate(key, stored, older))
+	assert.Error(t, registry.ValidateUpdate(key, stored, conflicting))
+	assert.NoError(t, registry.ValidateUpdate(key, stored, same))
+	assert.NoError(t, registry.ValidateUpdate(key, stored, newer))
+	assert.Error(t, registry.ValidateUpdate(key, stored, NewTextValue("value")))
+
+	// Data in the default namespace hashing to the key of a record cannot keep the record from being stored
+	assert.NoError(t, registry.ValidateUpdate(key, NewTextValue("squatter"), stored))
+}
+
+func TestRecordValidatorPublishers(t *testing.T) {
+	allowedPublicKey, allowedPrivateKey, _ := ed25519.GenerateKey(nil)
+	_, otherPrivateKey, _ := ed25519.GenerateKey(nil)
+	registry := &ValidatorRegistry{}
+	registry.Register(RecordNamespace, RecordValidator{Publishers: []ed25519.PublicKey{allowedPublicKey}})
+
+	allowed := NewRecord(allowedPrivateKey, nil, 1, NewTextValue("value"))
+	allowedValue, _ := allowed.ToValue()
+	other := NewRecord(otherPrivateKey, nil, 1, NewTextValue("value"))
+	otherValue, _ := other.ToValue()
+
+	assert.NoError(t, registry.Validate(allowed.GetKey(), allowedValue))
+	assert.Error(t, registry.Validate(other.GetKey(), otherValue))
+}
+
+func TestJSONValidator(t *testing.T) {
+	registry := &ValidatorRegistry{}
+	registry.Register("json", JSONValidator{MaxSize: 16})
+
+	newJSONValue := func(data string) Value {
+		value := NewValue([]byte(data), "application/json")
+		value.Namespace = "json"
+		return value
+	}
+
+	newJSONKey := func(data string) *Key {
+		key, _ := NewContentKey("json", []byte(data), DefaultHashFunction)
+		return key
+	}
+
+	assert.NoError(t, registry.Validate(newJSONKey(`{"a": 1}`), newJSONValue(`{"a": 1}`)))
+	assert.Error(t, registry.Validate(newJSONKey(`{"a": 1`), newJSONValue(`{"a": 1`)))
+	assert.Error(t, registry.Validate(newJSONKey(`{"a": "0123456789"}`), newJSONValue(`{"a": "0123456789"}`)))
+	assert.Error(t, registry.Validate(newJSONKey(`{"b": 2}`), newJSONValue(`{"a": 1}`)))
+	// The key of the data in the default namespace is not valid in the json namespace
+	assert.Error(t, registry.Validate(NewKey(`{"a": 1}`), newJSONValue(`{"a": 1}`)))
+}
+
+func TestValidateRejectsValueRelabelledToAnotherNamespace(t *testing.T) {
+	registry := &ValidatorRegistry{}
+	allowedPublicKey, _, _ := ed25519.GenerateKey(nil)
+	registry.Register(RecordNamespace, RecordValidator{Publishers: []ed25519.PublicKey{allowedPublicKey}})
+	registry.Register("open", RecordValidator{})
+	registry.Register("openkv", KeyValueValidator{})
+	registry.Register("lenient", ContentHashValidator{})
+	_, privateKey, _ := ed25519.GenerateKey(nil)
+
+	relabel := func(value Value, namespace string) Value {
+		value.Namespace = namespace
+		return value
+	}
+	record := NewRecord(privateKey, nil, 1, NewTextValue("value"))
+	recordValue, _ := record.ToValue()
+	keyValueValue, _ := KeyValue{Key: "name", Version: 1, Value: NewTextValue("value")}.ToValue()
+
+	// A value claiming a more lenient namespace is not valid under the key derived for its own namespace
+	assert.Error(t, registry.Validate(record.GetKey(), recordValue))
+	assert.Error(t, registry.Validate(record.GetKey(), relabel(recordValue, "open")))
+	assert.Error(t, registry.Validate(NewKeyValueKey("name"), relabel(keyValueValue, "openkv")))
+	assert.Error(t, registry.Validate(NewKey("value"), relabel(NewTextValue("value"), "lenient")))
+
+	assert.NoError(t, registry.Validate(NewNamespacedRecordKey("open", privateKey.Public().(ed25519.PublicKey), nil), relabel(recordValue, "open")))
+	assert.NoError(t, registry.Validate(NewKey("openkv/name"), relabel(keyValueValue, "openkv")))
+}
+
+func TestNewestValidatorSelectsLastCreated(t *testing.T) {
+	registry := &ValidatorRegistry{}
+	registry.Register("newest", NewestValidator{RecordValidator{}})
+	_, privateKey, _ := ed25519.GenerateKey(nil)
+
+	newNewestValue := func(sequence uint64, creationTime time.Time) Value {
+		value, _ := NewRecord(privateKey, nil, sequence, NewTextValue("value")).ToValue()
+		value.Namespace = "newest"
+		value.CreationTime = creationTime
+		return value
+	}
+	now := time.Now()
+	values := []Value{newNewestValue(3, now.Add(-time.Minute)), newNewestValue(1, now), newNewestValue(2, now.Add(-time.Hour))}
+
+	best, err := registry.Select(NewRecordKey(privateKey.Public().(ed25519.PublicKey), nil), values)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, best)
+}
+
+func TestStoreMessageUsesRegisteredValidator(t *testing.T) {
+	kademliaNode := NewKademliaNode("127.0.0.1", 3008, false, WithValidator("json", JSONValidator{}))
+	messageHandler := &MessageHandlerImplementation{
+		kademliaNode: kademliaNode,
+	}
+
+	sendStore := func(data string) StoreResponse {
+		value := NewValue([]byte(data), "application/json")
+		value.Namespace = "json"
+		key, _ := NewContentKey("json", []byte(data), DefaultHashFunction)
+		store := NewStoreMessage(NewContact(NewRandomKademliaID(), "127.0.0.1", 80), key, value, DefaultTTL)
+		bytes, _ := json.Marshal(store)
+		response, err := messageHandler.HandleMessage(bytes, "127.0.0.1")
+		if err != nil {
+			assert.Fail(t, err.Error())
+		}
+		var storeResponse StoreResponse
+		json.Unmarshal(response, &storeResponse)
+		return storeResponse
+	}
+
+	assert.True(t, sendStore(`{"a": 1}`).StoreSuccess)
+	assert.False(t, sendStore(`not json`).StoreSuccess)
+}
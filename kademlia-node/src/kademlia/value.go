@@ -0,0 +1,43 @@
+package kademlia
+
+import (
+	"time"
+)
+
+const (
+	TextContentType   = "text/plain; charset=utf-8"
+	BinaryContentType = "application/octet-stream"
+)
+
+// Value is a blob of bytes stored in the network, which can be empty, together with metadata describing it.
+// The key of a value is the hash of its data only, so the metadata does not change where it is stored.
+type Value struct {
+	Data         []byte    `json:"data"`
+	ContentType  string    `json:"contentType,omitempty"`
+	Size         int       `json:"size"`
+	CreationTime time.Time `json:"creationTime"`
+	Namespace    string    `json:"namespace,omitempty"` // Chooses the Validator of the value, the default is the content hash
+
+	DeleteTokenHash []byte `json:"deleteTokenHash,omitempty"` // The hash of the token its publisher deletes the value with
+}
+
+// NewValue creates a value holding the data, created now, in the default namespace. An empty content type means the
+// type is unknown.
+func NewValue(data []byte, contentType string) Value {
+	return Value{
+		Data:         data,
+		ContentType:  contentType,
+		Size:         len(data),
+		CreationTime: time.Now(),
+	}
+}
+
+// NewTextValue creates a value holding the UTF-8 encoded text.
+func NewTextValue(text string) Value {
+	return NewValue([]byte(text), TextContentType)
+}
+
+// GetKey returns the key the value is stored under.
+func (value Value) GetKey() *Key {
+	return NewKey(string(value.Data))
+}
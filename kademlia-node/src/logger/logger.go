@@ -2,6 +2,7 @@ package logger
 
 import (
 	"errors"
+	"sync"
 )
 
 type Logger struct {
@@ -17,8 +18,12 @@ func newLogger() *Logger {
 }
 
 var logger *Logger
+var lock sync.Mutex // Logs are written from the goroutines of every request
 
 func Log(log string) {
+	lock.Lock()
+	defer lock.Unlock()
+
 	if logger == nil {
 		logger = newLogger()
 	}
@@ -27,6 +32,9 @@ func Log(log string) {
 }
 
 func ReadNewLog() (string, error) {
+	lock.Lock()
+	defer lock.Unlock()
+
 	if logger == nil {
 		logger = newLogger()
 	}
@@ -45,6 +53,9 @@ func ReadNewLog() (string, error) {
 }
 
 func GetOldLogs() []string {
+	lock.Lock()
+	defer lock.Unlock()
+
 	if logger == nil {
 		logger = newLogger()
 	}
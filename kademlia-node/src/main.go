@@ -6,6 +6,7 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -63,7 +64,30 @@ func main() {
 	}
 	ip := ips[0].String()
 
-	KademliaInstance := kademlia.NewKademlia(ip, port, isBootstrap, bootstrapIp, bootstrapPort)
+	// Values are kept in memory unless a directory to persist them in is given
+	var options []kademlia.KademliaNodeOption
+	DATA_DIRECTORY := os.Getenv("DATA_DIRECTORY")
+	if DATA_DIRECTORY != "" {
+		dataStore, err := kademlia.NewDiskDataStore(DATA_DIRECTORY)
+		if err != nil {
+			panic(err)
+		}
+		options = append(options, kademlia.WithDataStore(dataStore))
+		// The values this node publishes are saved with them, so they are republished after a restart
+		options = append(options, kademlia.WithPublisherRegistryFile(filepath.Join(DATA_DIRECTORY, "published.json")))
+	}
+
+	// Values are hashed with the default hash function unless another is given by its multicodec name
+	HASH_FUNCTION := os.Getenv("HASH_FUNCTION")
+	if HASH_FUNCTION != "" {
+		hashFunction, err := kademlia.ParseHashFunction(HASH_FUNCTION)
+		if err != nil {
+			panic(err)
+		}
+		options = append(options, kademlia.WithHashFunction(hashFunction))
+	}
+
+	KademliaInstance := kademlia.NewKademlia(ip, port, isBootstrap, bootstrapIp, bootstrapPort, options...)
 	if isBootstrap {
 		http.HandleFunc("/", health)
 		go http.ListenAndServe(":80", nil)